@@ -0,0 +1,119 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyedBarrier is a thread-safe interface for batching the goroutines
+// arriving for arbitrary strings, like a cyclic barrier per key.
+type KeyedBarrier interface {
+	// Blocks until n goroutines, including the caller, have arrived for the
+	// specified key, and then releases all of them together. The barrier of
+	// the key then starts over, so the next n arrivals form the next batch.
+	// Returns false if ctx is done first, in which case the caller no longer
+	// counts towards the batch, or is already done. Goroutines arriving for
+	// the same key should pass the same n: a batch is released as soon as it
+	// has as many arrivals as the latest of them asked for. An n <= 1 returns
+	// true right away.
+	Arrive(ctx context.Context, key string, n int) bool
+}
+
+// NewKeyedBarrier returns a new instance of KeyedBarrier which hashes keys to
+// a fixed set of shards, as NewHashed does with locks. `shards` specifies
+// number of shards, if shards <= 0, a single shard is used, as with NewHashed.
+// Keys only take up memory while goroutines are waiting for them.
+func NewKeyedBarrier(shards int) KeyedBarrier {
+	if shards <= 0 {
+		shards = 1
+	}
+	b := &keyedBarrier{shards: make([]barrierShard, shards)}
+	for i := range b.shards {
+		b.shards[i].keys = make(map[string]*barrierBatch)
+	}
+	return b
+}
+
+var _ KeyedBarrier = (*keyedBarrier)(nil)
+
+type keyedBarrier struct {
+	shards []barrierShard
+}
+
+type barrierShard struct {
+	// lock guards keys, which holds the batch being gathered for each key
+	// hashing to the shard which has waiting goroutines.
+	lock sync.Mutex
+	keys map[string]*barrierBatch
+}
+
+// barrierBatch is the goroutines gathered for one cycle of a key's barrier.
+type barrierBatch struct {
+	arrived int
+	// released is closed once the batch is complete, to wake its goroutines.
+	released chan struct{}
+}
+
+// Blocks until n goroutines have arrived for the specified key or ctx is done.
+func (b *keyedBarrier) Arrive(ctx context.Context, key string, n int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if n <= 1 {
+		return true
+	}
+	s := b.shard(key)
+	s.lock.Lock()
+	batch, ok := s.keys[key]
+	if !ok {
+		batch = &barrierBatch{released: make(chan struct{})}
+		s.keys[key] = batch
+	}
+	batch.arrived++
+	if batch.arrived >= n {
+		close(batch.released)
+		delete(s.keys, key)
+		s.lock.Unlock()
+		return true
+	}
+	s.lock.Unlock()
+
+	select {
+	case <-batch.released:
+		return true
+	case <-ctx.Done():
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-batch.released:
+		// The batch was completed while giving up, counting the caller.
+		return true
+	default:
+	}
+	batch.arrived--
+	if batch.arrived == 0 {
+		delete(s.keys, key)
+	}
+	return false
+}
+
+func (b *keyedBarrier) shard(key string) *barrierShard {
+	return &b.shards[hash(key)%uint32(len(b.shards))]
+}
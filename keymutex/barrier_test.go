@@ -0,0 +1,103 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func arriveAndCallback(ctx context.Context, b KeyedBarrier, key string, n int, callbackCh chan<- interface{}) {
+	callbackCh <- b.Arrive(ctx, key, n)
+}
+
+func Test_KeyedBarrier_ReleasesBatch(t *testing.T) {
+	// Arrange
+	b := NewKeyedBarrier(1)
+	key := "fakeid"
+	const n = 3
+	callbackCh := make(chan interface{}, n)
+	otherCh := make(chan interface{}, 1)
+
+	// Act
+	for i := 0; i < n-1; i++ {
+		go arriveAndCallback(context.Background(), b, key, n, callbackCh)
+	}
+	go arriveAndCallback(context.Background(), b, "otherid", 2, otherCh)
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	if !b.Arrive(context.Background(), key, n) {
+		t.Fatalf("Expected the last arrival to be released.")
+	}
+	for i := 0; i < n-1; i++ {
+		verifyCallbackHappens(t, callbackCh)
+	}
+	verifyCallbackDoesntHappens(t, otherCh)
+	if keys := len(b.(*keyedBarrier).shards[0].keys); keys != 1 {
+		t.Fatalf("Expected only the other key to be left, got %d keys.", keys)
+	}
+	b.Arrive(context.Background(), "otherid", 2)
+	verifyCallbackHappens(t, otherCh)
+}
+
+func Test_KeyedBarrier_Cycles(t *testing.T) {
+	// Arrange
+	b := NewKeyedBarrier(1)
+	key := "fakeid"
+	callbackCh := make(chan interface{}, 1)
+
+	// Act & Assert
+	for cycle := 0; cycle < 3; cycle++ {
+		go arriveAndCallback(context.Background(), b, key, 2, callbackCh)
+		verifyCallbackDoesntHappens(t, callbackCh)
+		if !b.Arrive(context.Background(), key, 2) {
+			t.Fatalf("Expected cycle %d to be released.", cycle)
+		}
+		verifyCallbackHappens(t, callbackCh)
+	}
+}
+
+func Test_KeyedBarrier_Cancel(t *testing.T) {
+	// Arrange
+	b := NewKeyedBarrier(1)
+	key := "fakeid"
+	ctx, cancel := context.WithCancel(context.Background())
+	callbackCh := make(chan interface{}, 1)
+	go arriveAndCallback(ctx, b, key, 2, callbackCh)
+	verifyCallbackDoesntHappens(t, callbackCh)
+
+	// Act
+	cancel()
+
+	// Assert
+	select {
+	case released := <-callbackCh:
+		if released.(bool) {
+			t.Fatalf("Expected the cancelled arrival to return false.")
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Expected the cancelled arrival to give up.")
+	}
+	if keys := len(b.(*keyedBarrier).shards[0].keys); keys != 0 {
+		t.Fatalf("Expected the cancelled arrival to leave no state, got %d keys.", keys)
+	}
+	if b.Arrive(expiredContext(), key, 2) {
+		t.Fatalf("Expected Arrive with a done context to return false.")
+	}
+}
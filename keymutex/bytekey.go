@@ -0,0 +1,52 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"unsafe"
+)
+
+// Acquires a lock associated with the specified key, without allocating a
+// string for it. Panics if the KeyMutex has been closed.
+func (km *hashedKeyMutex) LockKeyBytes(key []byte) {
+	km.LockKey(km.bytesKey(key))
+}
+
+// Releases the lock associated with the specified key.
+// Panics if the specified key is not locked.
+func (km *hashedKeyMutex) UnlockKeyBytes(key []byte) error {
+	return km.UnlockKey(km.bytesKey(key))
+}
+
+// bytesKey returns key as a string which is only valid until the calling
+// method returns. Locks copy the keys they keep, so unless an option might
+// retain the string, it shares the memory of key instead of copying it.
+func (km *hashedKeyMutex) bytesKey(key []byte) string {
+	if km.retainsKeys() {
+		return string(key)
+	}
+	return *(*string)(unsafe.Pointer(&key))
+}
+
+// retainsKeys reports whether the configured options may keep the keys passed
+// to the KeyMutex after the call which passed them returns, such as custom
+// hashers and the hooks keys are handed to.
+func (km *hashedKeyMutex) retainsKeys() bool {
+	return km.customHasher || km.normalize != nil || km.events != nil ||
+		km.orderHook != nil || km.classObserver != nil || km.tracer != nil ||
+		km.profileLabel != nil || km.dumpWaiters
+}
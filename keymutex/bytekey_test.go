@@ -0,0 +1,113 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ByteKeys(t *testing.T) {
+	for _, km := range []KeyMutex{NewHashed(4), NewHashedWithHasher(4, func(id string) uint32 { return uint32(len(id)) })} {
+		// Arrange
+		bkm := km.(ByteKeyMutex)
+		key := []byte("fakeid")
+		callbackCh := make(chan interface{})
+
+		// Act
+		bkm.LockKeyBytes(key)
+		copy(key, "reused")
+
+		// Assert
+		go lockAndCallback(km, "fakeid", callbackCh)
+		verifyCallbackDoesntHappens(t, callbackCh)
+		if err := bkm.UnlockKeyBytes([]byte("fakeid")); err != nil {
+			t.Fatalf("Expected UnlockKeyBytes to succeed, got %v.", err)
+		}
+		verifyCallbackHappens(t, callbackCh)
+		km.UnlockKey("fakeid")
+	}
+}
+
+// retainingEventHook keeps the keys it is passed as they are, rather than
+// copying them.
+type retainingEventHook struct {
+	acquired []string
+}
+
+func (h *retainingEventHook) OnAcquire(key string) { h.acquired = append(h.acquired, key) }
+func (h *retainingEventHook) OnRelease(key string) {}
+func (h *retainingEventHook) OnTimeout(key string) {}
+
+func Test_ByteKeys_RetainedKeysAreCopied(t *testing.T) {
+	// Arrange
+	hook := &retainingEventHook{}
+	km := NewHashedWithOptions(4, WithEventHook(hook)).(ByteKeyMutex)
+	key := []byte("abc")
+
+	// Act
+	km.LockKeyBytes(key)
+	copy(key, "Xbc")
+	km.UnlockKeyBytes([]byte("abc"))
+
+	// Assert
+	expected := []string{"abc"}
+	if !reflect.DeepEqual(hook.acquired, expected) {
+		t.Fatalf("Expected the hook to keep %v after the key's buffer was reused, got %v.", expected, hook.acquired)
+	}
+}
+
+func Test_ByteKeys_NoAllocations(t *testing.T) {
+	if detectReentry {
+		t.Skip("Debug builds record the holder of each lock, which allocates.")
+	}
+	// Arrange
+	km := NewHashed(4).(ByteKeyMutex)
+	key := []byte("fakeid")
+
+	// Act
+	allocs := testing.AllocsPerRun(100, func() {
+		km.LockKeyBytes(key)
+		km.UnlockKeyBytes(key)
+	})
+
+	// Assert
+	if allocs != 0 {
+		t.Fatalf("Expected locking byte keys not to allocate, got %v allocations.", allocs)
+	}
+}
+
+func BenchmarkLockKey_StringFromBytes(b *testing.B) {
+	km := NewHashed(4)
+	key := []byte("fakeid")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		id := string(key)
+		km.LockKey(id)
+		km.UnlockKey(id)
+	}
+}
+
+func BenchmarkLockKeyBytes(b *testing.B) {
+	km := NewHashed(4).(ByteKeyMutex)
+	key := []byte("fakeid")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		km.LockKeyBytes(key)
+		km.UnlockKeyBytes(key)
+	}
+}
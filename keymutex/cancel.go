@@ -0,0 +1,116 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"sync"
+)
+
+// waiterShard holds what a hashed KeyMutex records about the goroutines
+// waiting for the keys which hash to it. Waiters are partitioned like the
+// locks they wait for when the KeyMutex is created, so that recording waits
+// for keys on different locks never contends. Waiters don't hold the lock
+// they wait for, so each partition has a lock of its own, and the partitions
+// stay the same when the locks are resized, so that a wait registered before
+// a resize can still be found after it.
+type waiterShard struct {
+	lock sync.Mutex
+	// cancels holds the keys which goroutines are waiting for in a way which
+	// CancelWaiters can cancel.
+	cancels map[string]*keyWaiters
+	// goroutines holds the IDs of the goroutines waiting for each key, if
+	// they are recorded for DumpWaiters.
+	goroutines map[string]map[uint64]struct{}
+}
+
+// keyWaiters is the registration shared by the cancellable waits for a key.
+type keyWaiters struct {
+	// cancel is closed to abort the waits, by CancelWaiters or Close.
+	cancel chan struct{}
+	// refs counts the waits using cancel.
+	refs int
+}
+
+// waiterShard returns the partition recording the waiters for id.
+func (km *hashedKeyMutex) waiterShard(id string) *waiterShard {
+	return &km.waiters[km.hasher(id)%uint32(len(km.waiters))]
+}
+
+// Makes the goroutines currently waiting for the lock associated with the
+// specified ID in a context-aware method give up. Waiters for other keys
+// hashing to the same lock keep waiting.
+func (km *hashedKeyMutex) CancelWaiters(id string) {
+	id = km.normalized(id)
+	w := km.waiterShard(id)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if kw, ok := w.cancels[id]; ok {
+		close(kw.cancel)
+		delete(w.cancels, id)
+	}
+}
+
+// watchWaiters registers a cancellable wait for id, returning a channel which
+// is closed once the wait is cancelled by CancelWaiters or the KeyMutex is
+// closed. The caller must call unwatchWaiters with it once done waiting.
+func (km *hashedKeyMutex) watchWaiters(id string) <-chan struct{} {
+	w := km.waiterShard(id)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if km.isClosed() {
+		return km.closed
+	}
+	kw, ok := w.cancels[id]
+	if !ok {
+		if w.cancels == nil {
+			w.cancels = make(map[string]*keyWaiters)
+		}
+		kw = &keyWaiters{cancel: make(chan struct{})}
+		w.cancels[id] = kw
+	}
+	kw.refs++
+	return kw.cancel
+}
+
+// unwatchWaiters drops a registration taken by watchWaiters.
+func (km *hashedKeyMutex) unwatchWaiters(id string, cancel <-chan struct{}) {
+	w := km.waiterShard(id)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	// Once cancelled, the registration has already been dropped, and id may
+	// have been registered afresh.
+	if kw, ok := w.cancels[id]; ok && kw.cancel == cancel {
+		kw.refs--
+		if kw.refs == 0 {
+			delete(w.cancels, id)
+		}
+	}
+}
+
+// cancelAllWaiters cancels every registered wait. It is called once
+// km.closed is closed, after which no more waits are registered.
+func (km *hashedKeyMutex) cancelAllWaiters() {
+	for i := range km.waiters {
+		w := &km.waiters[i]
+		w.lock.Lock()
+		for id, kw := range w.cancels {
+			close(kw.cancel)
+			delete(w.cancels, id)
+		}
+		w.lock.Unlock()
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_CancelWaiters(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		errCh := make(chan error)
+		km.LockKey(key)
+		go func() {
+			errCh <- LockKeyWithContextErr(context.Background(), km, key)
+		}()
+		verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+
+		// Act
+		km.(WaiterCanceller).CancelWaiters(key)
+
+		// Assert
+		select {
+		case err := <-errCh:
+			if err != ErrWaitCancelled {
+				t.Fatalf("Expected the waiter to give up with ErrWaitCancelled, got %v.", err)
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for CancelWaiters to wake the waiter.")
+		}
+		if TryLockKey(km, key) {
+			t.Fatalf("Expected the holder to keep the lock.")
+		}
+		// Only the waits in progress are cancelled.
+		go func() {
+			errCh <- LockKeyWithContextErr(context.Background(), km, key)
+		}()
+		verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+		km.UnlockKey(key)
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Fatalf("Expected a later waiter to acquire the lock, got %v.", err)
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for a later waiter to acquire the lock.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_CancelWaiters_SharedLock(t *testing.T) {
+	for _, km := range []KeyMutex{NewHashed(1), NewFairHashed(1)} {
+		// Arrange
+		held, other := "a", "b"
+		heldCh := make(chan bool)
+		otherCh := make(chan bool)
+		km.LockKey(held)
+		go func() {
+			heldCh <- LockKeyWithContext(context.Background(), km, held)
+		}()
+		go func() {
+			otherCh <- LockKeyWithContext(context.Background(), km, other)
+		}()
+		verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(held) == 2 })
+
+		// Act
+		km.(WaiterCanceller).CancelWaiters(held)
+
+		// Assert
+		select {
+		case acquired := <-heldCh:
+			if acquired {
+				t.Fatalf("Expected the cancelled waiter not to acquire the lock.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for CancelWaiters to wake the waiter.")
+		}
+		select {
+		case <-otherCh:
+			t.Fatalf("Expected the waiter for another key to keep waiting.")
+		case <-time.After(50 * time.Millisecond):
+		}
+		km.UnlockKey(held)
+		select {
+		case acquired := <-otherCh:
+			if !acquired {
+				t.Fatalf("Expected the waiter for another key to acquire the lock.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for the waiter for another key.")
+		}
+		km.UnlockKey(other)
+	}
+}
+
+func Test_CancelWaiters_AfterResize(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	key := "fakeid"
+	errCh := make(chan error)
+	km.LockKey(key)
+	go func() {
+		errCh <- LockKeyWithContextErr(context.Background(), km, key)
+	}()
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+	km.(Resizer).Resize(16)
+
+	// Act
+	km.(WaiterCanceller).CancelWaiters(key)
+
+	// Assert
+	select {
+	case err := <-errCh:
+		if err != ErrWaitCancelled {
+			t.Fatalf("Expected the waiter to give up with ErrWaitCancelled, got %v.", err)
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for CancelWaiters to wake a waiter from before the resize.")
+	}
+	km.UnlockKey(key)
+}
+
+// benchmarkWatchWaiters has each goroutine repeatedly register and drop a
+// cancellable wait for a key of its own, with the waiters partitioned into
+// the given number of parts. Run it with e.g. -cpu=8 to compare a single
+// partition, as if waiters were kept in one map, with one per lock.
+func benchmarkWatchWaiters(b *testing.B, partitions int) {
+	km := newHashed(64, nil)
+	km.waiters = make([]waiterShard, partitions)
+	var next int32
+	b.RunParallel(func(pb *testing.PB) {
+		key := fmt.Sprint(atomic.AddInt32(&next, 1))
+		for pb.Next() {
+			km.unwatchWaiters(key, km.watchWaiters(key))
+		}
+	})
+}
+
+func BenchmarkWatchWaiters_SinglePartition(b *testing.B) {
+	benchmarkWatchWaiters(b, 1)
+}
+
+func BenchmarkWatchWaiters_PerLock(b *testing.B) {
+	benchmarkWatchWaiters(b, 64)
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyedCond is a thread-safe interface for waiting until another goroutine
+// signals an arbitrary string, like a condition variable per key.
+//
+// Unlike sync.Cond, waiting doesn't require holding a lock: a Signal for a key
+// nobody is waiting for is remembered, and wakes the next Wait for the key
+// right away, so a Signal racing with a Wait is never lost.
+type KeyedCond interface {
+	// Waits until the specified key is signalled, giving up once ctx is done.
+	// Returns true if the key was signalled, false if ctx was done first.
+	Wait(ctx context.Context, key string) bool
+
+	// Wakes the goroutine which has waited longest for the specified key. If
+	// none is waiting, the next Wait for the key returns immediately
+	// instead; each Signal wakes exactly one Wait.
+	Signal(key string)
+
+	// Wakes all goroutines currently waiting for the specified key. Unlike
+	// Signal, Broadcast is not remembered if none is waiting.
+	Broadcast(key string)
+}
+
+// NewKeyedCond returns a new instance of KeyedCond which hashes keys to a
+// fixed set of shards, as NewHashed does with locks. `shards` specifies
+// number of shards, if shards <= 0, a single shard is used, as with NewHashed.
+// Keys only take up memory while goroutines wait for them or signals for
+// them are pending.
+func NewKeyedCond(shards int) KeyedCond {
+	if shards <= 0 {
+		shards = 1
+	}
+	c := &keyedCond{shards: make([]condShard, shards)}
+	for i := range c.shards {
+		c.shards[i].keys = make(map[string]*condKey)
+	}
+	return c
+}
+
+var _ KeyedCond = (*keyedCond)(nil)
+
+type keyedCond struct {
+	shards []condShard
+}
+
+type condShard struct {
+	// lock guards keys, which holds the state of each key hashing to the
+	// shard which is waited for or signalled.
+	lock sync.Mutex
+	keys map[string]*condKey
+}
+
+type condKey struct {
+	// waiters holds a channel for each waiting goroutine, in the order they
+	// started waiting, which is closed to wake it.
+	waiters []chan struct{}
+	// pending counts the signals which found no waiter.
+	pending int
+}
+
+// Waits until the specified key is signalled or ctx is done.
+func (c *keyedCond) Wait(ctx context.Context, key string) bool {
+	s := c.shard(key)
+	s.lock.Lock()
+	k := s.key(key)
+	if k.pending > 0 {
+		k.pending--
+		s.release(key, k)
+		s.lock.Unlock()
+		return true
+	}
+	wake := make(chan struct{})
+	k.waiters = append(k.waiters, wake)
+	s.lock.Unlock()
+
+	select {
+	case <-wake:
+		return true
+	case <-ctx.Done():
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-wake:
+		// Woken while giving up, so the signal was meant for this wait.
+		return true
+	default:
+	}
+	for i, w := range k.waiters {
+		if w == wake {
+			k.waiters = append(k.waiters[:i], k.waiters[i+1:]...)
+			break
+		}
+	}
+	s.release(key, k)
+	return false
+}
+
+// Wakes one goroutine waiting for the specified key, or remembers the signal.
+func (c *keyedCond) Signal(key string) {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	k := s.key(key)
+	if len(k.waiters) == 0 {
+		k.pending++
+		return
+	}
+	close(k.waiters[0])
+	k.waiters = k.waiters[1:]
+	s.release(key, k)
+}
+
+// Wakes all goroutines waiting for the specified key.
+func (c *keyedCond) Broadcast(key string) {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	k, ok := s.keys[key]
+	if !ok {
+		return
+	}
+	for _, w := range k.waiters {
+		close(w)
+	}
+	k.waiters = nil
+	s.release(key, k)
+}
+
+func (c *keyedCond) shard(key string) *condShard {
+	return &c.shards[hash(key)%uint32(len(c.shards))]
+}
+
+// key returns the state of key, creating it if needed. s.lock must be held.
+func (s *condShard) key(key string) *condKey {
+	k, ok := s.keys[key]
+	if !ok {
+		k = &condKey{}
+		s.keys[key] = k
+	}
+	return k
+}
+
+// release drops the state of key once nothing refers to it. s.lock must be
+// held.
+func (s *condShard) release(key string, k *condKey) {
+	if len(k.waiters) == 0 && k.pending == 0 {
+		delete(s.keys, key)
+	}
+}
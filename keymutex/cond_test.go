@@ -0,0 +1,185 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitAndCallback(ctx context.Context, c KeyedCond, key string, callbackCh chan<- interface{}) {
+	callbackCh <- c.Wait(ctx, key)
+}
+
+func condWaiters(c KeyedCond, key string) int {
+	s := c.(*keyedCond).shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if k, ok := s.keys[key]; ok {
+		return len(k.waiters)
+	}
+	return 0
+}
+
+func Test_KeyedCond_Handoff(t *testing.T) {
+	// Arrange
+	c := NewKeyedCond(1)
+	key := "fakeid"
+	var lock sync.Mutex
+	var queue []int
+	const items = 100
+	received := make(chan int, items)
+
+	// Act
+	go func() {
+		for sent := 0; sent < items; {
+			lock.Lock()
+			if len(queue) == 0 {
+				lock.Unlock()
+				if !c.Wait(context.Background(), key) {
+					return
+				}
+				continue
+			}
+			item := queue[0]
+			queue = queue[1:]
+			lock.Unlock()
+			received <- item
+			sent++
+		}
+	}()
+	for i := 0; i < items; i++ {
+		lock.Lock()
+		queue = append(queue, i)
+		lock.Unlock()
+		c.Signal(key)
+	}
+
+	// Assert
+	for i := 0; i < items; i++ {
+		select {
+		case item := <-received:
+			if item != i {
+				t.Fatalf("Expected item %d, got %d.", i, item)
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for item %d, the consumer missed a signal.", i)
+		}
+	}
+}
+
+func Test_KeyedCond_SignalBeforeWait(t *testing.T) {
+	// Arrange
+	c := NewKeyedCond(1)
+	key := "fakeid"
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	// Act
+	c.Signal(key)
+
+	// Assert
+	if !c.Wait(ctx, key) {
+		t.Fatalf("Expected a Signal before the Wait to be remembered.")
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if c.Wait(ctx, key) {
+		t.Fatalf("Expected a Signal to wake only one Wait.")
+	}
+	if keys := len(c.(*keyedCond).shards[0].keys); keys != 0 {
+		t.Fatalf("Expected no key state to be left, got %d keys.", keys)
+	}
+}
+
+func Test_KeyedCond_Signal(t *testing.T) {
+	// Arrange
+	c := NewKeyedCond(1)
+	key := "fakeid"
+	firstCh := make(chan interface{}, 1)
+	secondCh := make(chan interface{}, 1)
+	otherCh := make(chan interface{}, 1)
+	go waitAndCallback(context.Background(), c, key, firstCh)
+	verifyEventually(t, func() bool { return condWaiters(c, key) == 1 })
+	go waitAndCallback(context.Background(), c, key, secondCh)
+	verifyEventually(t, func() bool { return condWaiters(c, key) == 2 })
+	go waitAndCallback(context.Background(), c, "otherid", otherCh)
+	verifyEventually(t, func() bool { return condWaiters(c, "otherid") == 1 })
+
+	// Act & Assert
+	c.Signal(key)
+	verifyCallbackHappens(t, firstCh)
+	verifyCallbackDoesntHappens(t, secondCh)
+	c.Signal(key)
+	verifyCallbackHappens(t, secondCh)
+	verifyCallbackDoesntHappens(t, otherCh)
+	c.Signal("otherid")
+	verifyCallbackHappens(t, otherCh)
+}
+
+func Test_KeyedCond_Broadcast(t *testing.T) {
+	// Arrange
+	c := NewKeyedCond(1)
+	key := "fakeid"
+	callbackCh := make(chan interface{}, 3)
+	for i := 0; i < 3; i++ {
+		go waitAndCallback(context.Background(), c, key, callbackCh)
+	}
+	verifyEventually(t, func() bool { return condWaiters(c, key) == 3 })
+
+	// Act
+	c.Broadcast(key)
+
+	// Assert
+	for i := 0; i < 3; i++ {
+		verifyCallbackHappens(t, callbackCh)
+	}
+	c.Broadcast(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if c.Wait(ctx, key) {
+		t.Fatalf("Expected a Broadcast without waiters not to be remembered.")
+	}
+}
+
+func Test_KeyedCond_Cancelled(t *testing.T) {
+	// Arrange
+	c := NewKeyedCond(1)
+	key := "fakeid"
+	ctx, cancel := context.WithCancel(context.Background())
+	callbackCh := make(chan interface{}, 1)
+	go waitAndCallback(ctx, c, key, callbackCh)
+	verifyEventually(t, func() bool { return condWaiters(c, key) == 1 })
+
+	// Act
+	cancel()
+
+	// Assert
+	select {
+	case signalled := <-callbackCh:
+		if signalled.(bool) {
+			t.Fatalf("Expected a cancelled Wait to report it was not signalled.")
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for the cancelled Wait to return.")
+	}
+	if keys := len(c.(*keyedCond).shards[0].keys); keys != 0 {
+		t.Fatalf("Expected no key state to be left, got %d keys.", keys)
+	}
+}
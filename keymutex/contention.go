@@ -0,0 +1,52 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"sync/atomic"
+)
+
+// WithContentionAttribution tells apart, for each wait which blocks, whether
+// the lock was held for the same key (true contention) or for another key
+// hashing to the same lock (false contention), and counts them separately in
+// the SameKeyContended and OtherKeyContended fields of Stats. Frequent false
+// contention suggests using more locks. It implies WithHeldKeyTracking, and
+// only waits which block pay for looking up the holder.
+//
+// The holder is looked up when the wait starts, so a wait which outlasts
+// holds of several keys is attributed to the first of them. A wait which
+// finds the lock released by the time it looks counts towards Contended
+// only.
+func WithContentionAttribution() Option {
+	return func(km *hashedKeyMutex) {
+		km.trackHeld = true
+		km.attributeContention = true
+	}
+}
+
+// attributeWait counts a wait for s on behalf of id, which was just found to
+// be held, by whether it is held for id.
+func (km *hashedKeyMutex) attributeWait(s *shard, id string) {
+	holder, ok := s.heldBy()
+	switch {
+	case !ok:
+	case holder.Key == id:
+		atomic.AddUint64(&s.sameKeyContended, 1)
+	default:
+		atomic.AddUint64(&s.otherKeyContended, 1)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ContentionAttribution(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(1, WithContentionAttribution())
+	stats := km.(StatsReporter)
+	sameCh := make(chan interface{}, 1)
+	otherCh := make(chan interface{}, 1)
+	keysCh := make(chan interface{}, 1)
+	km.LockKey("a")
+
+	// Act
+	go lockAndCallback(km, "a", sameCh)
+	verifyEventually(t, func() bool { return stats.Stats()[0].Waiters == 1 })
+	go lockAndCallback(km, "b", otherCh)
+	verifyEventually(t, func() bool { return stats.Stats()[0].Waiters == 2 })
+	go func() {
+		LockKeys(km, "c")
+		keysCh <- true
+	}()
+	verifyEventually(t, func() bool { return stats.Stats()[0].Waiters == 3 })
+
+	// Assert
+	got := stats.Stats()[0]
+	if got.Contended != 3 || got.SameKeyContended != 1 || got.OtherKeyContended != 2 {
+		t.Fatalf("Expected 3 contended waits, 1 for the same key and 2 for others, got %+v.", got)
+	}
+	km.UnlockKey("a")
+	for i := 0; i < 3; i++ {
+		select {
+		case <-sameCh:
+		case <-otherCh:
+		case <-keysCh:
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for waiter %d to acquire the lock.", i)
+		}
+		held := km.(HeldKeysReporter).HeldKeys()
+		if len(held) != 1 {
+			t.Fatalf("Expected one key to be held, got %v.", held)
+		}
+		km.UnlockKey(held[0].Key)
+	}
+}
+
+func Test_ContentionAttribution_Disabled(t *testing.T) {
+	// Arrange
+	km := NewHashed(1)
+	callbackCh := make(chan interface{}, 1)
+	km.LockKey("a")
+
+	// Act
+	go lockAndCallback(km, "b", callbackCh)
+	verifyEventually(t, func() bool { return km.(StatsReporter).Stats()[0].Waiters == 1 })
+
+	// Assert
+	got := km.(StatsReporter).Stats()[0]
+	if got.SameKeyContended != 0 || got.OtherKeyContended != 0 {
+		t.Fatalf("Expected contention not to be attributed by default, got %+v.", got)
+	}
+	km.UnlockKey("a")
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey("b")
+}
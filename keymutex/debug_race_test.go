@@ -0,0 +1,85 @@
+//go:build race || keymutex_debug
+// +build race keymutex_debug
+
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_Debug_ReentrantLockPanics(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	key := "fakeid"
+	km.LockKey(key)
+	defer km.UnlockKey(key)
+
+	// Act
+	recovered := recoverPanic(func() {
+		km.LockKey(key)
+	})
+
+	// Assert
+	expected := fmt.Sprintf("keymutex: re-entrant lock on key %q", key)
+	if recovered != expected {
+		t.Errorf("Expected panic %q, got %v.", expected, recovered)
+	}
+	if recovered := recoverPanic(func() { LockKeys(km, "other", key) }); recovered == nil {
+		t.Errorf("Expected LockKeys to panic on a key held by the caller.")
+	}
+}
+
+func Test_Debug_ReentrantWaitsWhichGiveUp(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	key := "fakeid"
+	km.LockKey(key)
+	defer km.UnlockKey(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act & Assert
+	if TryLockKey(km, key) {
+		t.Errorf("Expected TryLockKey to fail on a key held by the caller.")
+	}
+	if LockKeyWithTimeout(km, key, 10*time.Millisecond) {
+		t.Errorf("Expected LockKeyWithTimeout to time out on a key held by the caller.")
+	}
+	if LockKeyWithContext(ctx, km, key) {
+		t.Errorf("Expected LockKeyWithContext to give up on a key held by the caller.")
+	}
+}
+
+func Test_Debug_OtherGoroutineBlocks(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+
+	// Act & Assert
+	km.LockKey(key)
+	go lockAndCallback(km, key, callbackCh)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+}
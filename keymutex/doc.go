@@ -0,0 +1,65 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package keymutex provides locks on arbitrary keys.
+//
+// Every constructor returning a KeyMutex returns an implementation of the
+// same contract, so one can be substituted for another, except for:
+//
+//   - NewReentrantHashed, which lets the goroutine holding a key lock it
+//     again, where any other KeyMutex would block, and only releases it once
+//     every lock has been matched by an unlock.
+//   - NewNoop, which excludes nothing, so it only stands in for a KeyMutex
+//     where mutual exclusion isn't needed.
+//
+// KeyMutex itself only locks and unlocks keys. Features beyond that are
+// offered through optional interfaces, which callers type-assert the KeyMutex
+// to. Every KeyMutex returned by this package implements TryLocker,
+// ContextLocker and TimeoutLocker, and:
+//
+//   - NewHashed, NewHashedStrict, NewHashedWithHasher, NewHashedWithPinning,
+//     NewHashedWithOptions and the constructors built on them, such as
+//     NewFairHashed, NewHashedPadded, NewHashedPow2, NewHashedWithStrategy,
+//     NewHashedWithNormalizer, NewHashedWithObserver,
+//     NewHashedWithClassifiedObserver, NewWatchdogHashed, NewReentrantHashed
+//     and NewHashedReentrantSafe,
+//     implement ByteKeyMutex, Introspectable (and so LockInspector,
+//     StatsReporter, ShardCounter, LockedCounter and ShardIndexer),
+//     HeldKeysReporter, WaitLatencyReporter, WaiterDumper, WaiterCanceller,
+//     PriorityLocker, Closer, Resetter, IdleWaiter, Resizer, Snapshotter,
+//     KeyTransferrer, HolderLabeler, BatchLocker, BatchTryLocker,
+//     ContextBatchLocker, ContextErrLocker, StopLocker, ExpvarPublisher,
+//     FreeWaiter and TokenLocker.
+//     Some of them only report data when the matching Option is configured,
+//     as their documentation describes.
+//   - NewPerKey and NewPerKeyBounded implement LockInspector,
+//     WaiterCanceller, Resetter, Warmer, BatchLocker, ContextErrLocker and
+//     StopLocker.
+//   - NewNoop and NewOptimisticHashed implement BatchLocker,
+//     ContextErrLocker and StopLocker.
+//
+// The TryLockKey, LockKeyWithContext, LockKeyWithTimeout, LockKeys,
+// UnlockKeys, LockKeyWithContextErr and LockKeyWithStop functions use these
+// interfaces where they are implemented. TryLockKey and LockKeyWithContext
+// panic on a KeyMutex which doesn't implement TryLocker and ContextLocker,
+// while the others fall back to the methods it does implement.
+//
+// NewHashedOf returns a KeyMutexOf, which also implements ShardCounter, and
+// NewRWHashed returns a RWKeyMutex, which also implements KeyUpgrader and
+// RWContextLocker.
+// NewHierarchical returns a HierarchicalKeyMutex, which locks whole subtrees
+// of keys by their prefix.
+package keymutex // import "k8s.io/utils/keymutex"
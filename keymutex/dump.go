@@ -0,0 +1,123 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"bytes"
+	"runtime"
+)
+
+// WaiterDumper is implemented by KeyMutex instances which can show what the
+// goroutines waiting for a key are doing, such as those returned by
+// NewHashed, for diagnosing keys which stay held far longer than expected.
+type WaiterDumper interface {
+	// Returns the stack traces of the goroutines currently waiting for the
+	// lock associated with the specified ID, in the format of runtime.Stack,
+	// or nil if the KeyMutex was not created with WithWaiterDumps. Waiters
+	// for other keys hashing to the same lock are not included. Keys which
+	// share a lock and are waited for together by LockKeys are reported
+	// under the smallest of them. This stops the world to capture the stacks
+	// of all goroutines, so it is meant to be called on demand rather than
+	// routinely.
+	DumpWaiters(id string) []string
+}
+
+// WithWaiterDumps records which goroutines are waiting for each key, so that
+// DumpWaiters can report their stacks. Only waits which block are recorded,
+// at the cost of identifying the waiting goroutine; stacks are only captured
+// by DumpWaiters. By default nothing is recorded.
+func WithWaiterDumps() Option {
+	return func(km *hashedKeyMutex) {
+		km.dumpWaiters = true
+	}
+}
+
+// addWaiter records that the calling goroutine waits for id, returning its ID
+// for removeWaiter.
+func (km *hashedKeyMutex) addWaiter(id string) uint64 {
+	goroutine := goroutineID()
+	w := km.waiterShard(id)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.goroutines == nil {
+		w.goroutines = make(map[string]map[uint64]struct{})
+	}
+	goroutines, ok := w.goroutines[id]
+	if !ok {
+		goroutines = make(map[uint64]struct{})
+		w.goroutines[id] = goroutines
+	}
+	goroutines[goroutine] = struct{}{}
+	return goroutine
+}
+
+// removeWaiter drops a record made by addWaiter.
+func (km *hashedKeyMutex) removeWaiter(id string, goroutine uint64) {
+	w := km.waiterShard(id)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	goroutines := w.goroutines[id]
+	delete(goroutines, goroutine)
+	if len(goroutines) == 0 {
+		delete(w.goroutines, id)
+	}
+}
+
+// waitingGoroutines returns the IDs of the goroutines waiting for id.
+func (km *hashedKeyMutex) waitingGoroutines(id string) map[uint64]struct{} {
+	w := km.waiterShard(id)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	goroutines := make(map[uint64]struct{}, len(w.goroutines[id]))
+	for goroutine := range w.goroutines[id] {
+		goroutines[goroutine] = struct{}{}
+	}
+	return goroutines
+}
+
+// Returns the stack traces of the goroutines currently waiting for the lock
+// associated with the specified ID, if the KeyMutex was created with
+// WithWaiterDumps, or nil otherwise.
+func (km *hashedKeyMutex) DumpWaiters(id string) []string {
+	if !km.dumpWaiters {
+		return nil
+	}
+	waiters := km.waitingGoroutines(km.normalized(id))
+	if len(waiters) == 0 {
+		return nil
+	}
+	var stacks []string
+	for _, stack := range bytes.Split(allStacks(), []byte("\n\n")) {
+		if _, ok := waiters[stackGoroutineID(stack)]; ok {
+			stacks = append(stacks, string(stack))
+		}
+	}
+	return stacks
+}
+
+// allStacks returns the stack traces of all goroutines, as runtime.Stack
+// formats them.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return bytes.TrimSpace(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_DumpWaiters(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(1, WithWaiterDumps())
+	dumper := km.(WaiterDumper)
+	key := "fakeid"
+	km.LockKey(key)
+	lockCh := make(chan interface{})
+	contextCh := make(chan interface{})
+	otherCh := make(chan interface{})
+
+	// Act
+	go lockAndCallback(km, key, lockCh)
+	go func() {
+		LockKeyWithContext(context.Background(), km, key)
+		contextCh <- true
+	}()
+	go lockAndCallback(km, "otherid", otherCh)
+	verifyEventually(t, func() bool { return len(dumper.DumpWaiters(key)) == 2 })
+	stacks := dumper.DumpWaiters(key)
+
+	// Assert
+	for _, stack := range stacks {
+		if !strings.HasPrefix(stack, "goroutine ") || !strings.Contains(stack, "Test_DumpWaiters") {
+			t.Errorf("Expected the stack of a goroutine started by the test, got %q.", stack)
+		}
+	}
+	if others := dumper.DumpWaiters("otherid"); len(others) != 1 {
+		t.Errorf("Expected 1 waiter for another key sharing the lock, got %d.", len(others))
+	}
+	km.UnlockKey(key)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-lockCh:
+			km.UnlockKey(key)
+		case <-contextCh:
+			km.UnlockKey(key)
+		case <-otherCh:
+			km.UnlockKey("otherid")
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for the waiters to acquire their keys.")
+		}
+	}
+	if stacks := dumper.DumpWaiters(key); stacks != nil {
+		t.Errorf("Expected no waiters once the key was acquired, got %q.", stacks)
+	}
+}
+
+func Test_DumpWaiters_LockKeys(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(64, WithWaiterDumps())
+	dumper := km.(WaiterDumper)
+	km.LockKey("b")
+	callbackCh := make(chan interface{})
+
+	// Act
+	go func() {
+		LockKeys(km, "a", "b")
+		callbackCh <- true
+	}()
+
+	// Assert
+	verifyEventually(t, func() bool { return len(dumper.DumpWaiters("b")) == 1 })
+	km.UnlockKey("b")
+	verifyCallbackHappens(t, callbackCh)
+	UnlockKeys(km, "a", "b")
+}
+
+func Test_DumpWaiters_Disabled(t *testing.T) {
+	// Arrange
+	km := NewHashed(1)
+	km.LockKey("fakeid")
+	callbackCh := make(chan interface{})
+	go lockAndCallback(km, "fakeid", callbackCh)
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount("fakeid") == 1 })
+
+	// Act
+	stacks := km.(WaiterDumper).DumpWaiters("fakeid")
+
+	// Assert
+	if stacks != nil {
+		t.Errorf("Expected no stacks without WithWaiterDumps, got %q.", stacks)
+	}
+	km.UnlockKey("fakeid")
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey("fakeid")
+}
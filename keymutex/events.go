@@ -0,0 +1,47 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+// EventHook is notified of the lifecycle of locks, so callers can log it with
+// their structured logger without this package depending on a logging
+// library. Methods are called synchronously on the goroutine acquiring or
+// releasing the lock, so they must be fast and safe for concurrent use, and
+// must not lock or unlock keys of the same KeyMutex.
+// Keys which share a lock and are locked together by LockKeys are reported
+// once, under the smallest of them.
+type EventHook interface {
+	// OnAcquire is called when the lock for key has been acquired. A
+	// reentrant acquisition is only reported the first time.
+	OnAcquire(key string)
+
+	// OnRelease is called when the lock for key has been released.
+	OnRelease(key string)
+
+	// OnTimeout is called when an acquisition in LockKeyWithContext,
+	// LockKeyWithContextErr, LockKeyWithStop or LockKeyWithTimeout for key
+	// gives up waiting.
+	OnTimeout(key string)
+}
+
+// WithEventHook configures hook to be notified whenever a lock is acquired,
+// released or given up on. By default nothing is notified, at no cost to
+// locking.
+func WithEventHook(hook EventHook) Option {
+	return func(km *hashedKeyMutex) {
+		km.events = hook
+	}
+}
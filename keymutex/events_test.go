@@ -0,0 +1,87 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeEventHook struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (h *fakeEventHook) record(event, key string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.events = append(h.events, event+" "+key)
+}
+
+func (h *fakeEventHook) OnAcquire(key string) { h.record("acquire", key) }
+func (h *fakeEventHook) OnRelease(key string) { h.record("release", key) }
+func (h *fakeEventHook) OnTimeout(key string) { h.record("timeout", key) }
+
+func (h *fakeEventHook) recorded() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]string(nil), h.events...)
+}
+
+func Test_EventHook(t *testing.T) {
+	// Arrange
+	hook := &fakeEventHook{}
+	km := NewHashedWithOptions(4, WithEventHook(hook))
+	key := "fakeid"
+
+	// Act
+	km.LockKey(key)
+	LockKeyWithTimeout(km, key, 10*time.Millisecond)
+	stop := make(chan struct{})
+	time.AfterFunc(10*time.Millisecond, func() { close(stop) })
+	LockKeyWithStop(km, key, stop)
+	TryLockKey(km, key)
+	km.UnlockKey(key)
+
+	// Assert
+	expected := []string{"acquire fakeid", "timeout fakeid", "timeout fakeid", "release fakeid"}
+	if events := hook.recorded(); !reflect.DeepEqual(events, expected) {
+		t.Fatalf("Expected events %v, got %v.", expected, events)
+	}
+}
+
+func Test_EventHook_Reentrant(t *testing.T) {
+	// Arrange
+	hook := &fakeEventHook{}
+	km := NewReentrantHashed(4).(*hashedKeyMutex)
+	WithEventHook(hook)(km)
+	key := "fakeid"
+
+	// Act
+	km.LockKey(key)
+	km.LockKey(key)
+	km.UnlockKey(key)
+	km.UnlockKey(key)
+
+	// Assert
+	expected := []string{"acquire fakeid", "release fakeid"}
+	if events := hook.recorded(); !reflect.DeepEqual(events, expected) {
+		t.Fatalf("Expected events %v, got %v.", expected, events)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// ErrLockExpired is returned when unlocking with a Token which no longer
+// owns the lock, because the lock expired and may have been reacquired since.
+var ErrLockExpired = errors.New("keymutex: lock already expired")
+
+// Token identifies a single acquisition of a lock. It is opaque to callers
+// and only meaningful to the KeyMutex that issued it.
+type Token uint64
+
+// ExpiringKeyMutex is a thread-safe interface for acquiring locks on
+// arbitrary strings which are released automatically if they are held for
+// too long.
+type ExpiringKeyMutex interface {
+	// Acquires a lock associated with the specified ID, returning a token
+	// which must be passed to UnlockKey.
+	LockKey(id string) Token
+
+	// Acquires a lock associated with the specified ID, giving up once ctx is
+	// done. Returns false if ctx was done first, or is already done.
+	LockKeyWithContext(ctx context.Context, id string) (Token, bool)
+
+	// Releases the lock associated with the specified ID if token still
+	// owns it. Returns ErrLockExpired if the lock has expired in the
+	// meantime, in which case nothing is released.
+	UnlockKey(id string, token Token) error
+}
+
+// NewExpiringHashed returns a new instance of ExpiringKeyMutex which hashes
+// arbitrary keys to a fixed set of locks, like NewHashed. A lock which is not
+// unlocked within ttl of being acquired is released automatically, so a
+// goroutine which hangs while holding a lock cannot starve others forever.
+// `n` specifies number of locks, if n <= 0, a single lock is shared by all
+// keys, as with NewHashed.
+func NewExpiringHashed(n int, ttl time.Duration) ExpiringKeyMutex {
+	return NewExpiringHashedWithClock(n, ttl, clock.RealClock{})
+}
+
+// NewExpiringHashedWithClock is like NewExpiringHashed, but measures ttl with
+// clk instead of the real clock, so that tests can expire locks by advancing
+// it. clk must call the functions passed to AfterFunc without holding any
+// lock of its own.
+func NewExpiringHashedWithClock(n int, ttl time.Duration, clk clock.WithDelayedExecution) ExpiringKeyMutex {
+	if n <= 0 {
+		n = 1
+	}
+	return &expiringKeyMutex{
+		shards: make([]expiringShard, n),
+		ttl:    ttl,
+		clock:  clk,
+	}
+}
+
+var _ ExpiringKeyMutex = (*expiringKeyMutex)(nil)
+
+type expiringKeyMutex struct {
+	// lastToken is kept first so that it is 64-bit aligned on 32-bit platforms.
+	lastToken uint64
+	shards    []expiringShard
+	ttl       time.Duration
+	clock     clock.WithDelayedExecution
+}
+
+type expiringShard struct {
+	mutex mutex
+
+	// lock guards the fields below, which describe the current holder of mutex.
+	lock  sync.Mutex
+	token Token
+	timer clock.Timer
+	// expired, if set, is called once the current holder's lock expires.
+	expired func()
+}
+
+// Acquires a lock associated with the specified ID.
+func (km *expiringKeyMutex) LockKey(id string) Token {
+	s := km.shard(id)
+	s.mutex.lock()
+	return km.acquired(s, nil)
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is done.
+func (km *expiringKeyMutex) LockKeyWithContext(ctx context.Context, id string) (Token, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+	s := km.shard(id)
+	if !s.mutex.lockOrDone(ctx.Done()) {
+		return 0, false
+	}
+	return km.acquired(s, nil), true
+}
+
+// Releases the lock associated with the specified ID if token still owns it.
+func (km *expiringKeyMutex) UnlockKey(id string, token Token) error {
+	s := km.shard(id)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if token == 0 || s.token != token {
+		return ErrLockExpired
+	}
+	s.timer.Stop()
+	s.release()
+	return nil
+}
+
+// acquired records a new holder of s, which must have just been locked, and
+// arms its expiry timer. If expired is set, it is called with s.lock held
+// once the lock expires.
+func (km *expiringKeyMutex) acquired(s *expiringShard, expired func()) Token {
+	token := Token(atomic.AddUint64(&km.lastToken, 1))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.token = token
+	s.expired = expired
+	s.timer = km.clock.AfterFunc(km.ttl, func() {
+		s.expire(token)
+	})
+	return token
+}
+
+func (km *expiringKeyMutex) shard(id string) *expiringShard {
+	return &km.shards[hash(id)%uint32(len(km.shards))]
+}
+
+// expire releases s if it is still held by token.
+func (s *expiringShard) expire(token Token) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.token == token {
+		expired := s.expired
+		s.release()
+		if expired != nil {
+			expired()
+		}
+	}
+}
+
+// release clears the current holder and unlocks s. s.lock must be held.
+func (s *expiringShard) release() {
+	s.token = 0
+	s.timer = nil
+	s.expired = nil
+	s.mutex.unlock()
+}
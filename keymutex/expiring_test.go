@@ -0,0 +1,111 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// fakeClock is a testingclock.FakeClock which calls the functions passed to
+// AfterFunc on goroutines of their own, as the real clock does, rather than
+// while stepping, when they couldn't read the clock.
+type fakeClock struct {
+	*testingclock.FakeClock
+}
+
+func newFakeClock() fakeClock {
+	return fakeClock{testingclock.NewFakeClock(time.Now())}
+}
+
+func (c fakeClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	return c.FakeClock.AfterFunc(d, func() { go f() })
+}
+
+func Test_Expiring_Unlock(t *testing.T) {
+	// Arrange
+	km := NewExpiringHashed(4, time.Hour)
+	key := "fakeid"
+
+	// Act & Assert
+	token := km.LockKey(key)
+	if _, ok := km.LockKeyWithContext(expiredContext(), key); ok {
+		t.Fatalf("Expected LockKeyWithContext to give up on a held key.")
+	}
+	if err := km.UnlockKey(key, token); err != nil {
+		t.Fatalf("Unexpected error from UnlockKey: %v", err)
+	}
+	if err := km.UnlockKey(key, token); err != ErrLockExpired {
+		t.Fatalf("Expected a second UnlockKey to return ErrLockExpired, got %v.", err)
+	}
+}
+
+func Test_Expiring_Expires(t *testing.T) {
+	// Arrange
+	km := NewExpiringHashed(4, 10*time.Millisecond)
+	key := "fakeid"
+	staleToken := km.LockKey(key)
+
+	// Act
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+	token, ok := km.LockKeyWithContext(ctx, key)
+
+	// Assert
+	if !ok {
+		t.Fatalf("Expected the lock to expire and be reacquired.")
+	}
+	if err := km.UnlockKey(key, staleToken); err != ErrLockExpired {
+		t.Fatalf("Expected the original holder to see ErrLockExpired, got %v.", err)
+	}
+	if err := km.UnlockKey(key, token); err != nil {
+		t.Fatalf("Unexpected error from the current holder's UnlockKey: %v", err)
+	}
+}
+
+func Test_Expiring_ExpiresWithClock(t *testing.T) {
+	// Arrange
+	clk := newFakeClock()
+	ttl := time.Minute
+	km := NewExpiringHashedWithClock(1, ttl, clk)
+	key := "fakeid"
+	staleToken := km.LockKey(key)
+	callbackCh := make(chan interface{}, 1)
+	go func() {
+		token, _ := km.LockKeyWithContext(context.Background(), key)
+		callbackCh <- token
+	}()
+
+	// Act & Assert
+	clk.Step(ttl - time.Second)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	clk.Step(time.Second)
+	verifyCallbackHappens(t, callbackCh)
+	if err := km.UnlockKey(key, staleToken); err != ErrLockExpired {
+		t.Fatalf("Expected the original holder to see ErrLockExpired, got %v.", err)
+	}
+}
+
+func expiredContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
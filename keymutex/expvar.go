@@ -0,0 +1,63 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"expvar"
+)
+
+// ExpvarPublisher is implemented by KeyMutex instances which can publish
+// their usage through the expvar package, such as those returned by
+// NewHashed, for monitoring through the /debug/vars endpoint without any
+// metrics library.
+type ExpvarPublisher interface {
+	// Publishes a map under the specified name, holding the number of
+	// locks acquired as "locks", the number of acquisitions which had to wait
+	// as "waits", and the number of locks currently held as "held". The
+	// values are computed whenever the map is read. Like expvar.Publish,
+	// panics if the name is already in use, so each KeyMutex has to be
+	// published under a name of its own.
+	PublishExpvar(name string)
+}
+
+// Publishes the usage of the KeyMutex under the specified name. The totals
+// are summed from Stats when read, so like Stats they start over from zero
+// after a Resize or Reset.
+func (km *hashedKeyMutex) PublishExpvar(name string) {
+	m := expvar.NewMap(name)
+	m.Set("locks", expvar.Func(func() interface{} {
+		locks, _ := km.totals()
+		return locks
+	}))
+	m.Set("waits", expvar.Func(func() interface{} {
+		_, waits := km.totals()
+		return waits
+	}))
+	m.Set("held", expvar.Func(func() interface{} {
+		return km.Locked()
+	}))
+}
+
+// totals returns the number of acquisitions of the current locks, and how
+// many of them had to wait.
+func (km *hashedKeyMutex) totals() (locks, waits uint64) {
+	for _, stat := range km.Stats() {
+		locks += stat.Acquired
+		waits += stat.Contended
+	}
+	return locks, waits
+}
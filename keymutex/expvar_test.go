@@ -0,0 +1,56 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"expvar"
+	"testing"
+)
+
+func Test_PublishExpvar(t *testing.T) {
+	// Arrange
+	km := NewHashed(1)
+	name := "keymutex_test_publish"
+	km.(ExpvarPublisher).PublishExpvar(name)
+	vars := expvar.Get(name).(*expvar.Map)
+	expect := func(key, value string) {
+		t.Helper()
+		if got := vars.Get(key).String(); got != value {
+			t.Fatalf("Expected %q to be %s, got %s.", key, value, got)
+		}
+	}
+	callbackCh := make(chan interface{})
+
+	// Act & Assert
+	expect("locks", "0")
+	expect("waits", "0")
+	expect("held", "0")
+	km.LockKey("a")
+	expect("locks", "1")
+	expect("held", "1")
+	go lockAndCallback(km, "a", callbackCh)
+	verifyEventually(t, func() bool { return vars.Get("waits").String() == "1" })
+	km.UnlockKey("a")
+	verifyCallbackHappens(t, callbackCh)
+	expect("locks", "2")
+	expect("held", "1")
+	km.UnlockKey("a")
+	expect("held", "0")
+	if recoverPanic(func() { NewHashed(1).(ExpvarPublisher).PublishExpvar(name) }) == nil {
+		t.Fatalf("Expected publishing under a name in use to panic.")
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"sync"
+)
+
+// NewFairHashed is like NewHashed, but each lock is granted to the goroutines
+// waiting for it in the order they started waiting, so that no waiter can be
+// starved by later arrivals. Since keys share locks, the order is shared by
+// all keys hashing to the same lock. Queueing waiters explicitly makes
+// contended locking slower than with NewHashed.
+func NewFairHashed(n int) KeyMutex {
+	return NewHashedWithOptions(n, WithFairness())
+}
+
+// WithFairness grants each lock to its waiters in the order they started
+// waiting, as NewFairHashed does.
+func WithFairness() Option {
+	return func(km *hashedKeyMutex) {
+		km.fair = true
+		km.rebuild()
+	}
+}
+
+// fairMutex is a mutual exclusion lock which is granted to its waiters in the
+// order they started waiting, or of their priority if they have different
+// ones. Unlocking hands the lock straight to the first waiter, so a goroutine
+// arriving meanwhile can't overtake it.
+type fairMutex struct {
+	lock sync.Mutex
+	held bool
+	// waiters are the goroutines waiting for the lock, by decreasing priority
+	// and then in arrival order.
+	waiters []fairWaiter
+}
+
+// fairWaiter is a goroutine waiting for a fairMutex.
+type fairWaiter struct {
+	// granted is closed once the lock has been handed to the waiter.
+	granted chan struct{}
+	prio    int
+}
+
+func (m *fairMutex) tryLock() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.held {
+		return false
+	}
+	m.held = true
+	return true
+}
+
+// lockOrAbort blocks until the lock is acquired or either done or abort is
+// closed. While waiting, it is granted the lock ahead of the waiters with a
+// lower prio.
+func (m *fairMutex) lockOrAbort(prio int, done, abort <-chan struct{}) bool {
+	m.lock.Lock()
+	if !m.held {
+		m.held = true
+		m.lock.Unlock()
+		return true
+	}
+	granted := make(chan struct{})
+	i := len(m.waiters)
+	for i > 0 && m.waiters[i-1].prio < prio {
+		i--
+	}
+	m.waiters = append(m.waiters, fairWaiter{})
+	copy(m.waiters[i+1:], m.waiters[i:])
+	m.waiters[i] = fairWaiter{granted: granted, prio: prio}
+	m.lock.Unlock()
+
+	select {
+	case <-granted:
+		return true
+	case <-done:
+	case <-abort:
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for i, waiter := range m.waiters {
+		if waiter.granted == granted {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			return false
+		}
+	}
+	// The lock was handed over while giving up, so pass it on.
+	m.handOff()
+	return false
+}
+
+func (m *fairMutex) unlock() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.held {
+		panic("keymutex: unlock of unlocked mutex")
+	}
+	m.handOff()
+}
+
+// handOff passes the held lock to the first waiter, or releases it if there
+// is none. m.lock must be held.
+func (m *fairMutex) handOff() {
+	if len(m.waiters) == 0 {
+		m.held = false
+		return
+	}
+	next := m.waiters[0]
+	m.waiters = m.waiters[1:]
+	close(next.granted)
+}
+
+func (m *fairMutex) locked() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.held
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_FairHashed_ArrivalOrder(t *testing.T) {
+	// Arrange
+	km := NewFairHashed(1)
+	m := km.(*hashedKeyMutex).current().shards[0].locker.(*fairMutex)
+	key := "fakeid"
+	const waiters = 20
+	order := make(chan int, waiters)
+	km.LockKey(key)
+
+	// Act
+	for i := 0; i < waiters; i++ {
+		go func(i int) {
+			km.LockKey(key)
+			order <- i
+			km.UnlockKey(key)
+		}(i)
+		verifyEventually(t, func() bool { return queued(m) == i+1 })
+	}
+	km.UnlockKey(key)
+
+	// Assert
+	for i := 0; i < waiters; i++ {
+		select {
+		case got := <-order:
+			if got != i {
+				t.Fatalf("Expected waiter %d to acquire the lock next, got waiter %d.", i, got)
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for waiter %d.", i)
+		}
+	}
+}
+
+func Test_FairHashed_AbandonedWait(t *testing.T) {
+	// Arrange
+	km := NewFairHashed(1)
+	m := km.(*hashedKeyMutex).current().shards[0].locker.(*fairMutex)
+	key := "fakeid"
+	ctx, cancel := context.WithCancel(context.Background())
+	resultCh := make(chan bool)
+	callbackCh := make(chan interface{})
+	km.LockKey(key)
+	go func() {
+		resultCh <- LockKeyWithContext(ctx, km, key)
+	}()
+	verifyEventually(t, func() bool { return queued(m) == 1 })
+	go lockAndCallback(km, key, callbackCh)
+	verifyEventually(t, func() bool { return queued(m) == 2 })
+
+	// Act
+	cancel()
+
+	// Assert
+	if <-resultCh {
+		t.Fatalf("Expected LockKeyWithContext to give up once cancelled.")
+	}
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+	if km.(LockInspector).IsLocked(key) {
+		t.Fatalf("Expected the key to be unlocked.")
+	}
+}
+
+func Test_FairHashed_Priority(t *testing.T) {
+	// Arrange
+	km := NewFairHashed(1)
+	m := km.(*hashedKeyMutex).current().shards[0].locker.(*fairMutex)
+	key := "fakeid"
+	// Waiters arrive in this order, and should be granted the lock by
+	// decreasing priority, in arrival order among equal priorities.
+	prios := []int{0, -1, 5, 0, 5, 10}
+	expected := []int{5, 2, 4, 0, 3, 1}
+	order := make(chan int, len(prios))
+	km.LockKey(key)
+
+	// Act
+	for i, prio := range prios {
+		go func(i, prio int) {
+			km.(PriorityLocker).LockKeyWithPriority(context.Background(), key, prio)
+			order <- i
+			km.UnlockKey(key)
+		}(i, prio)
+		verifyEventually(t, func() bool { return queued(m) == i+1 })
+	}
+	km.UnlockKey(key)
+
+	// Assert
+	for _, want := range expected {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Fatalf("Expected waiter %d with priority %d to acquire the lock next, got waiter %d with priority %d.", want, prios[want], got, prios[got])
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for waiter %d.", want)
+		}
+	}
+}
+
+func Test_FairHashed_PriorityOvertakes(t *testing.T) {
+	// Arrange
+	km := NewFairHashed(1)
+	m := km.(*hashedKeyMutex).current().shards[0].locker.(*fairMutex)
+	key := "fakeid"
+	order := make(chan string, 2)
+	km.LockKey(key)
+	go func() {
+		km.LockKey(key)
+		order <- "low"
+		km.UnlockKey(key)
+	}()
+	verifyEventually(t, func() bool { return queued(m) == 1 })
+
+	// Act
+	go func() {
+		km.(PriorityLocker).LockKeyWithPriority(context.Background(), key, 1)
+		order <- "high"
+		km.UnlockKey(key)
+	}()
+	verifyEventually(t, func() bool { return queued(m) == 2 })
+	km.UnlockKey(key)
+
+	// Assert
+	if first := <-order; first != "high" {
+		t.Fatalf("Expected the late high priority waiter to acquire the lock first, got %s.", first)
+	}
+	<-order
+}
+
+// queued returns the number of goroutines waiting for m.
+func queued(m *fairMutex) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.waiters)
+}
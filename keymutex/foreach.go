@@ -0,0 +1,92 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// KeyError reports that the function called for Key by ForEachLocked failed.
+type KeyError struct {
+	Key string
+	Err error
+}
+
+// Error names the key along with the error of the function.
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key %q: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the error of the function.
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// KeyErrors is returned by ForEachLocked when the function failed for some
+// keys, in the order of the keys.
+type KeyErrors []*KeyError
+
+// Error lists the errors for each key.
+func (e KeyErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("keymutex: %d keys failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// ForEachLocked calls fn for each of keys on goroutines of its own, holding
+// the lock associated with the key for the duration of the call, for example
+// to refresh a cache entry per key. At most maxConcurrency calls run at once,
+// or all of them if maxConcurrency <= 0. Calls for keys which share a lock,
+// or for a key which appears more than once, run one after another. It
+// returns once all calls have returned, with nil if all of them succeeded and
+// a KeyErrors otherwise.
+func ForEachLocked(km KeyMutex, keys []string, maxConcurrency int, fn func(key string) error) error {
+	if maxConcurrency <= 0 || maxConcurrency > len(keys) {
+		maxConcurrency = len(keys)
+	}
+	errs := make([]error, len(keys))
+	slots := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, key string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			km.LockKey(key)
+			defer km.UnlockKey(key)
+			errs[i] = fn(key)
+		}(i, key)
+	}
+	wg.Wait()
+	var failed KeyErrors
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, &KeyError{Key: keys[i], Err: err})
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
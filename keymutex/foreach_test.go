@@ -0,0 +1,140 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_ForEachLocked_Concurrent(t *testing.T) {
+	// Arrange
+	km := NewPerKey()
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	resultCh := make(chan error, 1)
+
+	// Act
+	go func() {
+		resultCh <- ForEachLocked(km, []string{"a", "b"}, 2, func(key string) error {
+			if !km.(LockInspector).IsLocked(key) {
+				t.Errorf("Expected %q to be locked while fn runs.", key)
+			}
+			started <- key
+			<-release
+			return nil
+		})
+	}()
+
+	// Assert
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for distinct keys to run concurrently.")
+		}
+	}
+	close(release)
+	if err := <-resultCh; err != nil {
+		t.Fatalf("Expected no error, got %v.", err)
+	}
+	if km.(LockInspector).IsLocked("a") || km.(LockInspector).IsLocked("b") {
+		t.Fatalf("Expected the keys to be released.")
+	}
+}
+
+func Test_ForEachLocked_SameKeySerializes(t *testing.T) {
+	// Arrange
+	km := NewPerKey()
+	var running, maxRunning int32
+	var lock sync.Mutex
+
+	// Act
+	err := ForEachLocked(km, []string{"a", "a", "a"}, 0, func(key string) error {
+		n := atomic.AddInt32(&running, 1)
+		lock.Lock()
+		if n > maxRunning {
+			maxRunning = n
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v.", err)
+	}
+	if maxRunning != 1 {
+		t.Fatalf("Expected calls for the same key to run one at a time, got %d at once.", maxRunning)
+	}
+}
+
+func Test_ForEachLocked_MaxConcurrency(t *testing.T) {
+	// Arrange
+	km := NewPerKey()
+	var running, maxRunning int32
+	var lock sync.Mutex
+
+	// Act
+	ForEachLocked(km, []string{"a", "b", "c", "d", "e"}, 2, func(key string) error {
+		n := atomic.AddInt32(&running, 1)
+		lock.Lock()
+		if n > maxRunning {
+			maxRunning = n
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+
+	// Assert
+	if maxRunning > 2 {
+		t.Fatalf("Expected at most 2 calls at once, got %d.", maxRunning)
+	}
+}
+
+func Test_ForEachLocked_Errors(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	errFake := errors.New("fake error")
+
+	// Act
+	err := ForEachLocked(km, []string{"a", "b", "c"}, 0, func(key string) error {
+		if key == "b" {
+			return nil
+		}
+		return errFake
+	})
+
+	// Assert
+	var failed KeyErrors
+	if !errors.As(err, &failed) {
+		t.Fatalf("Expected KeyErrors, got %v.", err)
+	}
+	if len(failed) != 2 || failed[0].Key != "a" || failed[1].Key != "c" {
+		t.Fatalf("Expected errors for a and c, got %v.", err)
+	}
+	if !errors.Is(failed[0], errFake) {
+		t.Fatalf("Expected the error of fn to be wrapped, got %v.", failed[0])
+	}
+}
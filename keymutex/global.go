@@ -0,0 +1,87 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+)
+
+// NewHashedWithGlobalLimit is like NewHashed, but at most maxHeld locks are
+// held at once across all keys, e.g. to bound the number of files open for
+// the keys held. See WithGlobalLimit.
+func NewHashedWithGlobalLimit(n, maxHeld int) KeyMutex {
+	return NewHashedWithOptions(n, WithGlobalLimit(maxHeld))
+}
+
+// WithGlobalLimit holds at most maxHeld locks at once, as
+// NewHashedWithGlobalLimit does. Acquiring a key then waits both for its
+// lock and, once the lock is free, for fewer than maxHeld locks to be held.
+// Waits which can give up, such as LockKeyWithContext, give up on either,
+// and TryLockKey fails if either is unavailable. Keys sharing a lock and
+// locked together by LockKeys take up a single place, while LockKeys panics
+// if its keys need more than maxHeld locks. Reentrant acquisitions by the
+// holder of a lock don't take up another place. If maxHeld <= 0, the number
+// of locks held is not limited.
+//
+// Places are granted in the order they are waited for. While waiting for a
+// place, a key's lock is already taken, so that holders of the maximum
+// number of locks can always lock further keys once places free up, rather
+// than competing with goroutines which wait for their keys.
+func WithGlobalLimit(maxHeld int) Option {
+	return func(km *hashedKeyMutex) {
+		km.limit = nil
+		if maxHeld > 0 {
+			km.limit = &weightedSemaphore{capacity: maxHeld}
+		}
+	}
+}
+
+// tryLimit takes a place of the global limit for s, which was just locked on
+// behalf of id, without waiting.
+func (km *hashedKeyMutex) tryLimit(s *shard, id string) bool {
+	s.limited = km.limit.tryAcquire(id, 1)
+	return s.limited
+}
+
+// waitLimit takes a place of the global limit for s, which was just locked on
+// behalf of id, giving up once done or abort is closed.
+func (km *hashedKeyMutex) waitLimit(s *shard, id string, done, abort <-chan struct{}) bool {
+	s.limited = km.limit.acquireOrAbort(id, 1, done, abort)
+	return s.limited
+}
+
+// waitLimitAll takes a place of the global limit for each of the locks of g
+// which were just locked by LockKeys.
+func (km *hashedKeyMutex) waitLimitAll(g *generation, locked []shardKey) {
+	km.limit.acquire(locked[0].id, len(locked), nil)
+	for _, sk := range locked {
+		g.shards[sk.index].limited = true
+	}
+}
+
+// checkLimit panics if LockKeys needs more places than the global limit has.
+func (km *hashedKeyMutex) checkLimit(locks int) {
+	if locks > km.limit.capacity {
+		panic(fmt.Sprintf("keymutex: LockKeys of %d locks exceeds the global limit of %d", locks, km.limit.capacity))
+	}
+}
+
+// releaseLimit gives back the place of a lock which was just released.
+func (km *hashedKeyMutex) releaseLimit() {
+	// The place was taken, so releasing it can't fail.
+	_ = km.limit.release("", 1)
+}
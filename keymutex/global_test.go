@@ -0,0 +1,118 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_GlobalLimit(t *testing.T) {
+	// Arrange
+	km := NewHashedWithGlobalLimit(64, 2)
+	callbackCh := make(chan interface{}, 1)
+	km.LockKey("a")
+	km.LockKey("b")
+
+	// Act
+	go lockAndCallback(km, "x", callbackCh)
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	if TryLockKey(km, "y") {
+		t.Fatalf("Expected TryLockKey to fail while the maximum number of keys is held.")
+	}
+	km.UnlockKey("a")
+	verifyCallbackHappens(t, callbackCh)
+	if TryLockKey(km, "y") {
+		t.Fatalf("Expected TryLockKey to fail once the waiting key took the free place.")
+	}
+	km.UnlockKey("b")
+	if !TryLockKey(km, "y") {
+		t.Fatalf("Expected TryLockKey to succeed once fewer keys are held.")
+	}
+	km.UnlockKey("x")
+	km.UnlockKey("y")
+}
+
+func Test_GlobalLimit_Context(t *testing.T) {
+	// Arrange
+	km := NewHashedWithGlobalLimit(64, 1)
+	km.LockKey("a")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := LockKeyWithContextErr(ctx, km, "x")
+
+	// Assert
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected the wait for a place to give up with context.DeadlineExceeded, got %v.", err)
+	}
+	if km.(LockInspector).IsLocked("x") {
+		t.Fatalf("Expected the key to be released after giving up.")
+	}
+	km.UnlockKey("a")
+	if !TryLockKey(km, "x") {
+		t.Fatalf("Expected the place given up on to be free.")
+	}
+	km.UnlockKey("x")
+}
+
+func Test_GlobalLimit_LockKeys(t *testing.T) {
+	// Arrange
+	km := NewHashedWithGlobalLimit(64, 2)
+	callbackCh := make(chan interface{}, 1)
+	LockKeys(km, "a", "b")
+
+	// Act
+	go func() {
+		LockKeys(km, "x")
+		callbackCh <- true
+	}()
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	UnlockKeys(km, "a", "b")
+	verifyCallbackHappens(t, callbackCh)
+	if recoverPanic(func() { LockKeys(km, "a", "b", "y") }) == nil {
+		t.Fatalf("Expected LockKeys of more keys than the limit to panic.")
+	}
+	if km.(LockInspector).IsLocked("a") {
+		t.Fatalf("Expected LockKeys to panic before locking any key.")
+	}
+	UnlockKeys(km, "x")
+}
+
+func Test_GlobalLimit_SharedLock(t *testing.T) {
+	// Arrange
+	km := NewHashedWithGlobalLimit(1, 1)
+
+	// Act
+	LockKeys(km, "a", "b")
+
+	// Assert
+	if locked := km.(LockedCounter).Locked(); locked != 1 {
+		t.Fatalf("Expected keys sharing a lock to take up one place, got %d locks held.", locked)
+	}
+	UnlockKeys(km, "a", "b")
+	if !TryLockKey(km, "a") {
+		t.Fatalf("Expected the place to be released with the lock.")
+	}
+	km.UnlockKey("a")
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// KeyedGroup is a thread-safe interface for waiting until the operations in
+// progress for arbitrary strings have completed, like a sync.WaitGroup per
+// key.
+//
+// Unlike sync.WaitGroup, operations may be added for a key while others wait
+// for it, so a KeyedGroup can track work which starts at any time, such as
+// the requests in flight for a tenant.
+type KeyedGroup interface {
+	// Adds delta, which may be negative, to the counter of the specified
+	// key. When the counter drops to zero, all goroutines waiting for the
+	// key are woken. Panics if the counter would become negative.
+	Add(key string, delta int)
+
+	// Decrements the counter of the specified key by one.
+	Done(key string)
+
+	// Blocks until the counter of the specified key is zero, returning
+	// ctx.Err() if ctx is done first. Returns nil right away if the counter
+	// is already zero. Operations added after the counter drops to zero
+	// don't delay waits which started before.
+	Wait(ctx context.Context, key string) error
+}
+
+// NewKeyedGroup returns a new instance of KeyedGroup which hashes keys to a
+// fixed set of shards, as NewHashed does with locks. `shards` specifies
+// number of shards, if shards <= 0, a single shard is used, as with NewHashed.
+// Keys only take up memory while their counter is above zero.
+func NewKeyedGroup(shards int) KeyedGroup {
+	if shards <= 0 {
+		shards = 1
+	}
+	g := &keyedGroup{shards: make([]groupShard, shards)}
+	for i := range g.shards {
+		g.shards[i].keys = make(map[string]*groupKey)
+	}
+	return g
+}
+
+var _ KeyedGroup = (*keyedGroup)(nil)
+
+type keyedGroup struct {
+	shards []groupShard
+}
+
+type groupShard struct {
+	// lock guards keys, which holds the state of each key hashing to the
+	// shard whose counter is above zero.
+	lock sync.Mutex
+	keys map[string]*groupKey
+}
+
+type groupKey struct {
+	count int
+	// zero is closed once count drops to zero, to wake the waiting
+	// goroutines.
+	zero chan struct{}
+}
+
+// Adds delta to the counter of the specified key.
+func (g *keyedGroup) Add(key string, delta int) {
+	s := g.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	k, ok := s.keys[key]
+	if !ok {
+		k = &groupKey{zero: make(chan struct{})}
+	}
+	if k.count+delta < 0 {
+		panic(fmt.Sprintf("keymutex: negative KeyedGroup counter for key %q", key))
+	}
+	k.count += delta
+	switch {
+	case k.count == 0 && ok:
+		close(k.zero)
+		delete(s.keys, key)
+	case k.count > 0 && !ok:
+		s.keys[key] = k
+	}
+}
+
+// Decrements the counter of the specified key.
+func (g *keyedGroup) Done(key string) {
+	g.Add(key, -1)
+}
+
+// Blocks until the counter of the specified key is zero or ctx is done.
+func (g *keyedGroup) Wait(ctx context.Context, key string) error {
+	s := g.shard(key)
+	s.lock.Lock()
+	k, ok := s.keys[key]
+	s.lock.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-k.zero:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *keyedGroup) shard(key string) *groupShard {
+	return &g.shards[hash(key)%uint32(len(g.shards))]
+}
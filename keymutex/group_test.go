@@ -0,0 +1,163 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func groupWaitAndCallback(ctx context.Context, g KeyedGroup, key string, callbackCh chan<- interface{}) {
+	callbackCh <- g.Wait(ctx, key)
+}
+
+func Test_KeyedGroup_Wait(t *testing.T) {
+	// Arrange
+	g := NewKeyedGroup(1)
+	key := "fakeid"
+	callbackCh := make(chan interface{}, 1)
+	otherCh := make(chan interface{}, 1)
+	g.Add(key, 2)
+	g.Add("otherid", 1)
+
+	// Act
+	go groupWaitAndCallback(context.Background(), g, key, callbackCh)
+	go groupWaitAndCallback(context.Background(), g, "otherid", otherCh)
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	g.Done(key)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	g.Done(key)
+	verifyCallbackHappens(t, callbackCh)
+	verifyCallbackDoesntHappens(t, otherCh)
+	g.Done("otherid")
+	verifyCallbackHappens(t, otherCh)
+	if keys := len(g.(*keyedGroup).shards[0].keys); keys != 0 {
+		t.Fatalf("Expected no key state to be left, got %d keys.", keys)
+	}
+}
+
+func Test_KeyedGroup_WaitIdle(t *testing.T) {
+	// Arrange
+	g := NewKeyedGroup(1)
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	// Act & Assert
+	if err := g.Wait(ctx, "fakeid"); err != nil {
+		t.Fatalf("Expected Wait for a key without operations to return right away, got %v.", err)
+	}
+}
+
+func Test_KeyedGroup_Cancelled(t *testing.T) {
+	// Arrange
+	g := NewKeyedGroup(1)
+	key := "fakeid"
+	g.Add(key, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	callbackCh := make(chan interface{}, 1)
+	go groupWaitAndCallback(ctx, g, key, callbackCh)
+
+	// Act
+	cancel()
+
+	// Assert
+	select {
+	case err := <-callbackCh:
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got %v.", err)
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for the cancelled Wait to return.")
+	}
+	g.Done(key)
+}
+
+func Test_KeyedGroup_Negative(t *testing.T) {
+	// Arrange
+	g := NewKeyedGroup(1)
+	key := "fakeid"
+	g.Add(key, 1)
+	g.Done(key)
+
+	// Act
+	recovered := recoverPanic(func() { g.Done(key) })
+
+	// Assert
+	if recovered == nil {
+		t.Fatalf("Expected Done without a matching Add to panic.")
+	}
+}
+
+func Test_KeyedGroup_Concurrent(t *testing.T) {
+	// Arrange
+	g := NewKeyedGroup(1)
+	key := "fakeid"
+	const workers = 10
+	const rounds = 100
+	var wg sync.WaitGroup
+
+	// Act
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				g.Add(key, 1)
+				if j%2 == 0 {
+					// Let waits overlap operations in progress.
+					time.Sleep(time.Microsecond)
+				}
+				g.Done(key)
+			}
+		}()
+	}
+	waitsCh := make(chan interface{}, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := 0; j < rounds; j++ {
+				if err := g.Wait(context.Background(), key); err != nil {
+					waitsCh <- err
+					return
+				}
+			}
+			waitsCh <- nil
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	for i := 0; i < workers; i++ {
+		select {
+		case err := <-waitsCh:
+			if err != nil {
+				t.Fatalf("Expected waits to succeed, got %v.", err)
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for waits, one missed the counter dropping to zero.")
+		}
+	}
+	if err := g.Wait(context.Background(), key); err != nil {
+		t.Fatalf("Expected Wait to return once all operations are done, got %v.", err)
+	}
+	if keys := len(g.(*keyedGroup).shards[0].keys); keys != 0 {
+		t.Fatalf("Expected no key state to be left, got %d keys.", keys)
+	}
+}
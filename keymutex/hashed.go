@@ -17,42 +17,961 @@ limitations under the License.
 package keymutex
 
 import (
-	"hash/fnv"
-	"runtime"
+	"context"
+	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/utils/clock"
 )
 
 // NewHashed returns a new instance of KeyMutex which hashes arbitrary keys to
-// a fixed set of locks. `n` specifies number of locks, if n <= 0, we use
-// number of cpus.
+// a fixed set of locks. `n` specifies number of locks, if n <= 0, a single
+// lock is shared by all keys. The number chosen is reported by ShardCount.
+// Use NewHashedStrict to reject n <= 0 instead. Every constructor of this
+// package taking a number of locks or shards treats n <= 0 the same way.
+// Before, n <= 0 meant as many locks as there are CPUs, so callers which pass
+// 0 now lock all keys one at a time, and should pass runtime.NumCPU() to keep
+// the old number.
 // Note that because it uses fixed set of locks, different keys may share same
 // lock, so it's possible to wait on same lock.
+// Locking and unlocking keys doesn't allocate, unless options which record
+// more about each acquisition are configured. A single lock skips hashing
+// keys, but is otherwise kept like any other number of locks, so that it
+// still implements every optional interface; callers which only need one
+// lock for all keys and none of those interfaces are better served by a
+// sync.Mutex.
+//
+// When built with the race detector or the keymutex_debug build tag, as in
+// `go test -race` or `go test -tags keymutex_debug`, the KeyMutex also records
+// which goroutine holds each lock, and LockKey and LockKeys panic with a
+// message naming the key if the calling goroutine already holds its lock,
+// rather than deadlocking silently. Waits which can give up, and
+// TryLockKey, behave as usual. Code which hands a held lock to another
+// goroutine and then locks the key again before that goroutine has released
+// it would also panic, so such code can't be run in those builds. Recording
+// the holder formats the calling goroutine's stack trace, which makes locking
+// and unlocking take microseconds rather than nanoseconds in those builds.
+// Other builds don't record holders and pay nothing for the check.
 func NewHashed(n int) KeyMutex {
+	return NewHashedWithHasher(n, nil)
+}
+
+// NewHashedStrict is like NewHashed, but returns an error if n < 1 rather
+// than choosing the number of locks itself, for callers which take n from
+// configuration and want to reject a missing or invalid value.
+func NewHashedStrict(n int) (KeyMutex, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("keymutex: invalid number of locks %d, must be at least 1", n)
+	}
+	return NewHashed(n), nil
+}
+
+// NewHashedWithHasher is like NewHashed, but maps keys to locks with the
+// given hash function instead of FNV-1a. hasher must be deterministic and
+// safe for concurrent use; if it is nil the default hash is used. With a
+// single lock, keys aren't hashed at all.
+func NewHashedWithHasher(n int, hasher func(string) uint32) KeyMutex {
+	return newHashed(n, hasher)
+}
+
+func newHashed(n int, hasher func(string) uint32) *hashedKeyMutex {
 	if n <= 0 {
-		n = runtime.NumCPU()
+		n = 1
+	}
+	km := &hashedKeyMutex{
+		hasher:       hasher,
+		customHasher: hasher != nil,
+		waiters:      make([]waiterShard, n),
+		closed:       make(chan struct{}),
+		clock:        clock.RealClock{},
 	}
-	return &hashedKeyMutex{
-		mutexes: make([]sync.Mutex, n),
+	if hasher == nil {
+		km.hasher = hash
 	}
+	if detectReentry {
+		km.owners = ownerPanicOnDeadlock
+	}
+	km.generation.Store(km.newGeneration(n))
+	return km
 }
 
+var (
+	_ KeyMutex            = (*hashedKeyMutex)(nil)
+	_ KeyMutexOf[string]  = (*hashedKeyMutex)(nil)
+	_ TryLocker           = (*hashedKeyMutex)(nil)
+	_ ContextLocker       = (*hashedKeyMutex)(nil)
+	_ TimeoutLocker       = (*hashedKeyMutex)(nil)
+	_ ByteKeyMutex        = (*hashedKeyMutex)(nil)
+	_ Introspectable      = (*hashedKeyMutex)(nil)
+	_ HeldKeysReporter    = (*hashedKeyMutex)(nil)
+	_ WaitLatencyReporter = (*hashedKeyMutex)(nil)
+	_ WaiterDumper        = (*hashedKeyMutex)(nil)
+	_ WaiterCanceller     = (*hashedKeyMutex)(nil)
+	_ PriorityLocker      = (*hashedKeyMutex)(nil)
+	_ Closer              = (*hashedKeyMutex)(nil)
+	_ Resetter            = (*hashedKeyMutex)(nil)
+	_ IdleWaiter          = (*hashedKeyMutex)(nil)
+	_ Resizer             = (*hashedKeyMutex)(nil)
+	_ Snapshotter         = (*hashedKeyMutex)(nil)
+	_ KeyTransferrer      = (*hashedKeyMutex)(nil)
+	_ HolderLabeler       = (*hashedKeyMutex)(nil)
+	_ BatchTryLocker      = (*hashedKeyMutex)(nil)
+	_ ExpvarPublisher     = (*hashedKeyMutex)(nil)
+	_ FreeWaiter          = (*hashedKeyMutex)(nil)
+	_ ContextBatchLocker  = (*hashedKeyMutex)(nil)
+	_ TokenLocker         = (*hashedKeyMutex)(nil)
+	_ BatchLocker         = (*hashedKeyMutex)(nil)
+	_ ContextErrLocker    = (*hashedKeyMutex)(nil)
+	_ StopLocker          = (*hashedKeyMutex)(nil)
+)
+
 type hashedKeyMutex struct {
-	mutexes []sync.Mutex
+	// lastToken is kept first so that it is 64-bit aligned on 32-bit
+	// platforms. It is the last Token handed out by LockKeyToken.
+	lastToken uint64
+	// generation holds the current *generation of locks.
+	generation atomic.Value
+	// resizeLock serializes changes to the chain of generations.
+	resizeLock sync.Mutex
+	hasher     func(string) uint32
+	// customHasher is set unless hasher is the default hash, which never
+	// retains the keys it hashes.
+	customHasher bool
+	// normalize, if set, canonicalizes every key before it is used.
+	normalize func(string) string
+	// owners controls whether, and why, the goroutine holding each lock is
+	// tracked.
+	owners ownerMode
+	// tracer, if set, traces context-aware acquisitions.
+	tracer TracerHook
+	// profileLabel, if set, derives the profiler label of a blocking wait
+	// from its key.
+	profileLabel func(key string) string
+	// events, if set, is notified of acquisitions, releases and timeouts.
+	events EventHook
+	// orderHook, if set, is notified around acquisitions and releases.
+	orderHook LockOrderHook
+	// observer, if set, receives measurements of lock usage.
+	observer MetricsObserver
+	// holdObserver, if set, is observer measuring how long locks are held.
+	holdObserver HoldDurationObserver
+	// contextObserver, if set, is observer counting acquisitions whose
+	// context was done first.
+	contextObserver ContextFailureObserver
+	// classObserver, if set, receives measurements of lock usage segmented
+	// by the class classify returns for each key.
+	classObserver ClassObserver
+	classify      func(key string) string
+	// latency, if set, counts acquisitions by how long they waited.
+	latency *latencyHistogram
+	// dumpWaiters records the goroutines waiting for each key.
+	dumpWaiters bool
+	// trackHeld records the holder of each lock for HeldKeys.
+	trackHeld bool
+	// attributeContention counts contention by whether the holder's key is
+	// the same as the waiter's. It relies on trackHeld.
+	attributeContention bool
+	// watchdog, if set, reports keys held for too long. It relies on
+	// trackHeld.
+	watchdog *watchdog
+	// clock tells how long keys are held and schedules watchdog checks.
+	clock clock.WithDelayedExecution
+	// limit, if set, bounds the number of locks held at once.
+	limit *weightedSemaphore
+	// spins is how often to retry a held lock before waiting for it.
+	spins int
+	// fair grants each lock to its waiters in arrival order.
+	fair bool
+	// padded lays out the locks, and the fair locks they are built on, a
+	// cache line apart.
+	padded bool
+	// pow2 rounds the number of locks up to a power of two.
+	pow2 bool
+	// pinned, if set, maps keys to the index of the lock they are locked on
+	// rather than the lock they hash to.
+	pinned map[string]int
+	// strategy selects the kind of lock each shard is built on, unless fair
+	// is set.
+	strategy Strategy
+	// idleWaiters counts the calls to WaitIdle in progress, which unlocking
+	// has to wake once no locks are held.
+	idleWaiters int32
+	idleLock    sync.Mutex
+	// idle is closed and replaced whenever WaitIdle calls should check again
+	// whether any lock is held. It is guarded by idleLock.
+	idle chan struct{}
+	// freeWaiters counts the calls to WaitUntilFree in progress, which
+	// unlocking has to wake.
+	freeWaiters int32
+	// free is closed and replaced whenever WaitUntilFree calls should check
+	// again whether their key is held. It is guarded by idleLock.
+	free chan struct{}
+	// waiters records the goroutines waiting for keys, partitioned by key.
+	waiters []waiterShard
+	// closed is closed by Close, failing all further acquisitions.
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 // Acquires a lock associated with the specified ID.
+// Panics if the KeyMutex has been closed.
 func (km *hashedKeyMutex) LockKey(id string) {
-	km.mutexes[km.hash(id)%uint32(len(km.mutexes))].Lock()
+	km.checkOpen()
+	km.lock(nil, km.normalized(id), 0, nil, nil)
+}
+
+// Attempts to acquire the lock associated with the specified ID without blocking.
+func (km *hashedKeyMutex) TryLockKey(id string) bool {
+	if km.isClosed() {
+		return false
+	}
+	return km.tryLock(km.normalized(id))
+}
+
+// tryLock implements TryLockKey for an ID which is already normalized.
+func (km *hashedKeyMutex) tryLock(id string) bool {
+	if km.owners == ownerReentrant && km.reenter(id) {
+		return true
+	}
+	g := km.current()
+	if !km.tryDrain(g, id) {
+		return false
+	}
+	s := km.shardOf(g, id)
+	if !s.tryLock() {
+		return false
+	}
+	if !km.enter(g, s) {
+		s.unlock()
+		return false
+	}
+	if km.limit != nil && !km.tryLimit(s, id) {
+		km.leave(g, s)
+		s.unlock()
+		return false
+	}
+	km.acquired(s, id)
+	if km.orderHook != nil {
+		km.orderHook.AfterAcquire(goroutineID(), id, true)
+	}
+	return true
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is done.
+// Waiting stops early if the KeyMutex is closed.
+func (km *hashedKeyMutex) LockKeyWithContext(ctx context.Context, id string) bool {
+	return km.LockKeyWithContextErr(ctx, id) == nil
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is done.
+// Returns ctx.Err() if ctx was done first, ErrClosed if the KeyMutex is
+// closed before the lock is acquired, or ErrWaitCancelled if CancelWaiters
+// was called for the ID meanwhile.
+func (km *hashedKeyMutex) LockKeyWithContextErr(ctx context.Context, id string) error {
+	return km.lockWithContext(ctx, id, 0, true)
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is
+// done, ahead of the waiters with a lower prio if the KeyMutex grants its
+// locks in order.
+func (km *hashedKeyMutex) LockKeyWithPriority(ctx context.Context, id string, prio int) bool {
+	return km.lockWithContext(ctx, id, prio, true) == nil
+}
+
+// lockWithContext implements LockKeyWithContextErr for waiters of the given
+// priority. If labelled is set, waiting is labelled for profiles on top of the
+// labels of ctx.
+func (km *hashedKeyMutex) lockWithContext(ctx context.Context, id string, prio int, labelled bool) error {
+	if km.isClosed() {
+		return ErrClosed
+	}
+	id = km.normalized(id)
+	var labels context.Context
+	if labelled {
+		labels = ctx
+	}
+	var acquired bool
+	if ctx.Err() != nil {
+		// The caller has already given up, so don't acquire the lock even
+		// if it is free.
+	} else if km.tracer == nil {
+		acquired = km.lock(labels, id, prio, ctx.Done(), km.closed)
+	} else {
+		start := time.Now()
+		end := km.tracer.StartSpan(ctx, lockSpanName, id)
+		acquired = km.lock(labels, id, prio, ctx.Done(), km.closed)
+		end(acquired, time.Since(start))
+	}
+	if acquired {
+		return nil
+	}
+	if km.events != nil {
+		km.events.OnTimeout(id)
+	}
+	if err := ctx.Err(); err != nil {
+		if km.contextObserver != nil {
+			km.observeContextFailure(id, err)
+		}
+		return err
+	}
+	if km.isClosed() {
+		return ErrClosed
+	}
+	return ErrWaitCancelled
+}
+
+// Acquires a lock associated with the specified ID, giving up when stop is
+// closed. Waiting stops early if the KeyMutex is closed.
+func (km *hashedKeyMutex) LockKeyWithStop(id string, stop <-chan struct{}) bool {
+	if km.isClosed() || isStopped(stop) {
+		return false
+	}
+	id = km.normalized(id)
+	if km.lock(nil, id, 0, stop, km.closed) {
+		return true
+	}
+	if km.events != nil {
+		km.events.OnTimeout(id)
+	}
+	return false
+}
+
+// Acquires a lock associated with the specified ID, giving up after d.
+func (km *hashedKeyMutex) LockKeyWithTimeout(id string, d time.Duration) bool {
+	if d <= 0 {
+		return km.TryLockKey(id)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	// Labelling the wait would drop the labels of the calling goroutine,
+	// which ctx doesn't carry.
+	return km.lockWithContext(ctx, id, 0, false) == nil
 }
 
 // Releases the lock associated with the specified ID.
+// Panics if the specified ID is not locked.
 func (km *hashedKeyMutex) UnlockKey(id string) error {
-	km.mutexes[km.hash(id)%uint32(len(km.mutexes))].Unlock()
+	km.release(km.normalized(id))
+	return nil
+}
+
+// Acquires the locks associated with all of the specified IDs. IDs are
+// locked in the order of the locks they hash to, and IDs sharing a lock only
+// lock it once. Panics if the KeyMutex has been closed.
+func (km *hashedKeyMutex) LockKeys(ids ...string) {
+	km.checkOpen()
+	ids = km.normalizedAll(ids)
+	for !km.lockAll(km.current(), ids) {
+	}
+}
+
+// Releases the locks associated with all of the specified IDs.
+func (km *hashedKeyMutex) UnlockKeys(ids ...string) error {
+	ids = sortedUnique(km.normalizedAll(ids))
+	if km.owners == ownerReentrant {
+		var exited []*shard
+		for _, id := range ids {
+			if _, s := km.ownedShard(id); s != nil && containsShard(exited, s) {
+				continue
+			} else if s != nil {
+				exited = append(exited, s)
+			}
+			km.release(id)
+		}
+		return nil
+	}
+	// Each lock is held on behalf of the smallest of its IDs, so the other
+	// IDs sharing it come later and find it released already.
+	type released struct {
+		g *generation
+		s *shard
+	}
+	var done []released
+	for _, id := range ids {
+		if g, s := km.heldShard(id); s != nil {
+			km.releaseShard(g, s)
+			done = append(done, released{g, s})
+			continue
+		}
+		shared := false
+		for _, r := range done {
+			if km.shardOf(r.g, id) == r.s {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			panicUnlocked(id)
+		}
+	}
 	return nil
 }
 
-func (km *hashedKeyMutex) hash(id string) uint32 {
-	h := fnv.New32a()
-	h.Write([]byte(id))
-	return h.Sum32()
+// Attempts to acquire the locks associated with all of the specified IDs
+// without blocking. Like LockKeys, IDs are tried in the order of the locks
+// they hash to, and IDs sharing a lock only try it once. If any lock is held,
+// those already acquired are released again and false is returned.
+func (km *hashedKeyMutex) TryLockAll(ids ...string) bool {
+	ids = sortedUnique(km.normalizedAll(ids))
+	for {
+		g := km.current()
+		locked := km.shardKeys(g, ids)
+		for i, sk := range locked {
+			if km.isClosed() || !km.tryLock(sk.id) {
+				km.releaseAll(locked[:i])
+				return false
+			}
+		}
+		if km.current() == g {
+			return true
+		}
+		// A resize may have spread the IDs sharing a lock over several, so
+		// try again on the new locks.
+		km.releaseAll(locked)
+	}
+}
+
+// Acquires the locks associated with all of the specified IDs, as LockKeys
+// does, giving up once ctx is done or the KeyMutex is closed. If it gives up
+// while waiting for any of the locks, those already acquired are released
+// again and false is returned.
+func (km *hashedKeyMutex) LockKeysWithContext(ctx context.Context, ids ...string) bool {
+	ids = sortedUnique(km.normalizedAll(ids))
+	for {
+		if ctx.Err() != nil || km.isClosed() {
+			return false
+		}
+		g := km.current()
+		locked := km.shardKeys(g, ids)
+		for i, sk := range locked {
+			if !km.lock(ctx, sk.id, 0, ctx.Done(), km.closed) {
+				if km.events != nil {
+					km.events.OnTimeout(sk.id)
+				}
+				km.releaseAll(locked[:i])
+				return false
+			}
+		}
+		if km.current() == g {
+			return true
+		}
+		// As in TryLockAll, IDs which shared a lock may no longer do so.
+		km.releaseAll(locked)
+	}
+}
+
+// releaseAll releases the locks held on behalf of the IDs of sks.
+func (km *hashedKeyMutex) releaseAll(sks []shardKey) {
+	for _, sk := range sks {
+		km.release(sk.id)
+	}
+}
+
+// Reports whether the lock associated with the specified ID is held. Since
+// keys share locks, this is also true while a different key hashing to the
+// same lock is held.
+func (km *hashedKeyMutex) IsLocked(id string) bool {
+	id = km.normalized(id)
+	for g := km.current(); g != nil; g = g.older() {
+		if km.shardOf(g, id).locked() {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the number of goroutines waiting for the lock associated with the
+// specified ID. Since keys share locks, this includes goroutines waiting for
+// other keys hashing to the same lock.
+func (km *hashedKeyMutex) WaitersCount(id string) int {
+	id = km.normalized(id)
+	waiters := 0
+	for g := km.current(); g != nil; g = g.older() {
+		waiters += km.shardOf(g, id).waitersCount()
+	}
+	return waiters
+}
+
+// Stops the KeyMutex from accepting new locks, e.g. during graceful shutdown.
+// Once closed:
+//   - LockKey and LockKeys panic; calls already waiting keep waiting.
+//   - TryLockKey, LockKeyWithContext and LockKeyWithTimeout return false,
+//     and calls already waiting in them return false immediately.
+//   - Locks which are already held can still be unlocked, so in-flight
+//     operations can drain.
+//
+// Closing is permanent, and closing more than once has no further effect.
+func (km *hashedKeyMutex) Close() {
+	km.closeOnce.Do(func() {
+		close(km.closed)
+		km.cancelAllWaiters()
+	})
+}
+
+// Returns the KeyMutex to its initial state, keeping its locks.
+// Panics if any key is held.
+func (km *hashedKeyMutex) Reset() {
+	if locked := km.Locked(); locked > 0 {
+		panic(fmt.Sprintf("keymutex: reset with %d locks held", locked))
+	}
+	g := km.current()
+	for i := range g.shards {
+		s := g.shards[i]
+		atomic.StoreUint64(&s.acquisitions, 0)
+		atomic.StoreUint64(&s.contended, 0)
+		atomic.StoreUint64(&s.sameKeyContended, 0)
+		atomic.StoreUint64(&s.otherKeyContended, 0)
+		s.holder = s.holder[:0]
+		if km.trackHeld {
+			s.clearHeld()
+		}
+	}
+	if km.latency != nil {
+		km.latency.reset()
+	}
+	km.closed = make(chan struct{})
+	km.closeOnce = sync.Once{}
+}
+
+// Returns the keys currently held, if the KeyMutex was created with
+// WithHeldKeyTracking, or nil otherwise.
+func (km *hashedKeyMutex) HeldKeys() []HeldKey {
+	if !km.trackHeld {
+		return nil
+	}
+	var held []HeldKey
+	for g := km.current(); g != nil; g = g.older() {
+		for i := range g.shards {
+			if h, ok := g.shards[i].heldBy(); ok {
+				held = append(held, h)
+			}
+		}
+	}
+	return held
+}
+
+// Returns the number of locks currently held. Rather than counting on every
+// acquisition, which would contend across all locks, this checks the state
+// each lock already keeps, so it takes time proportional to the number of
+// locks.
+func (km *hashedKeyMutex) Locked() int {
+	locked := 0
+	for g := km.current(); g != nil; g = g.older() {
+		for i := range g.shards {
+			if atomic.LoadInt32(&g.shards[i].state) == shardHeld {
+				locked++
+			}
+		}
+	}
+	return locked
+}
+
+// Returns the number of underlying locks.
+func (km *hashedKeyMutex) ShardCount() int {
+	return len(km.current().shards)
+}
+
+// Returns the index of the lock the specified ID hashes to.
+func (km *hashedKeyMutex) ShardIndex(id string) int {
+	return km.shardIndex(km.current(), km.normalized(id))
+}
+
+// Returns contention statistics for each of the underlying locks.
+func (km *hashedKeyMutex) Stats() []ShardStat {
+	g := km.current()
+	stats := make([]ShardStat, len(g.shards))
+	for i := range g.shards {
+		stats[i] = g.shards[i].stat(i)
+	}
+	return stats
+}
+
+// lock acquires the lock id hashes to, giving up once either done or abort
+// is closed. Fair locks are granted to waiters with a higher prio first. If
+// labels is set, a blocking wait is labelled for profiles on top of its
+// labels.
+func (km *hashedKeyMutex) lock(labels context.Context, id string, prio int, done, abort <-chan struct{}) bool {
+	// Keep the common case of a free lock, with no resize in progress and no
+	// options watching the acquisition, clear of the bookkeeping below.
+	if km.unwatched() {
+		if g := km.current(); g.older() == nil {
+			s := km.shardOf(g, id)
+			if s.tryLock() {
+				// With nothing else to record, the lock can be marked held
+				// right away instead of entering it first.
+				s.holder = append(s.holder[:0], id...)
+				atomic.StoreInt32(&s.state, shardHeld)
+				if km.current() == g {
+					atomic.AddUint64(&s.acquisitions, 1)
+					return true
+				}
+				km.leave(g, s)
+				s.unlock()
+			}
+		}
+	}
+	return km.lockSlow(labels, id, prio, done, abort)
+}
+
+// unwatched reports whether none of the options which have to see each
+// acquisition are configured, so that acquisitions can take a fast path.
+func (km *hashedKeyMutex) unwatched() bool {
+	return km.owners == ownerUntracked && km.orderHook == nil && km.limit == nil &&
+		km.events == nil && km.classObserver == nil && !km.trackHeld && !km.timesWaits()
+}
+
+// lockSlow implements lock for when the lock isn't simply free.
+func (km *hashedKeyMutex) lockSlow(labels context.Context, id string, prio int, done, abort <-chan struct{}) (acquired bool) {
+	switch km.owners {
+	case ownerPanicOnReentry:
+		km.checkReentrant(id)
+	case ownerPanicOnDeadlock:
+		if done == nil && abort == nil {
+			km.checkReentrant(id)
+		}
+	case ownerReentrant:
+		if km.reenter(id) {
+			return true
+		}
+	}
+	if km.orderHook != nil {
+		goroutine := goroutineID()
+		km.orderHook.BeforeAcquire(goroutine, id)
+		defer func() {
+			km.orderHook.AfterAcquire(goroutine, id, acquired)
+		}()
+	}
+	var start time.Time
+	if km.timesWaits() {
+		start = time.Now()
+	}
+	// Waits which can be aborted can also be cancelled by CancelWaiters, but
+	// the key is only registered for that once it has to wait.
+	cancellable := abort != nil
+	// Likewise, a waiting goroutine is only recorded once it has to wait.
+	dumpable := km.dumpWaiters
+	for {
+		g := km.current()
+		if g.older() != nil {
+			if cancellable {
+				abort, cancellable = km.watchWaiters(id), false
+				defer km.unwatchWaiters(id, abort)
+			}
+			if dumpable {
+				dumpable = false
+				defer km.removeWaiter(id, km.addWaiter(id))
+			}
+		}
+		if !km.drain(g, id, prio, done, abort) {
+			return false
+		}
+		s := km.shardOf(g, id)
+		if !s.tryLock() && (km.spins <= 0 || !km.spin(s)) {
+			if cancellable {
+				abort, cancellable = km.watchWaiters(id), false
+				defer km.unwatchWaiters(id, abort)
+			}
+			if dumpable {
+				dumpable = false
+				defer km.removeWaiter(id, km.addWaiter(id))
+			}
+			if km.attributeContention {
+				km.attributeWait(s, id)
+			}
+			if !km.waitContended(labels, s, id, prio, done, abort) {
+				return false
+			}
+		}
+		if !km.enter(g, s) {
+			s.unlock()
+			continue
+		}
+		if km.limit != nil && !km.waitLimit(s, id, done, abort) {
+			km.leave(g, s)
+			s.unlock()
+			return false
+		}
+		if km.timesWaits() {
+			km.observeWait(s, id, time.Since(start))
+		}
+		km.acquired(s, id)
+		return true
+	}
+}
+
+// lockAll acquires the locks of g which ids hash to, as LockKeys does. It
+// returns false, holding none of them, if g is replaced meanwhile.
+func (km *hashedKeyMutex) lockAll(g *generation, ids []string) bool {
+	var reentered []*shard
+	unowned := make([]string, 0, len(ids))
+	for _, id := range ids {
+		switch km.owners {
+		case ownerPanicOnReentry, ownerPanicOnDeadlock:
+			km.checkReentrant(id)
+		case ownerReentrant:
+			if _, s := km.ownedShard(id); s != nil {
+				if !containsShard(reentered, s) {
+					reentered = append(reentered, s)
+				}
+				continue
+			}
+		}
+		km.drain(g, id, 0, nil, nil)
+		unowned = append(unowned, id)
+	}
+	locked := km.shardKeys(g, unowned)
+	if km.limit != nil {
+		km.checkLimit(len(locked))
+	}
+	var goroutine uint64
+	if km.orderHook != nil {
+		goroutine = goroutineID()
+	}
+	for i, sk := range locked {
+		s := g.shards[sk.index]
+		if km.orderHook != nil {
+			km.orderHook.BeforeAcquire(goroutine, sk.id)
+		}
+		var start time.Time
+		if km.timesWaits() {
+			start = time.Now()
+		}
+		if !km.dumpWaiters && !km.attributeContention && km.spins <= 0 {
+			s.lock()
+		} else if !s.tryLock() && (km.spins <= 0 || !km.spin(s)) {
+			if km.attributeContention {
+				km.attributeWait(s, sk.id)
+			}
+			if km.dumpWaiters {
+				goroutine := km.addWaiter(sk.id)
+				s.lockContended(0, nil, nil)
+				km.removeWaiter(sk.id, goroutine)
+			} else {
+				s.lockContended(0, nil, nil)
+			}
+		}
+		if !km.enter(g, s) {
+			s.unlock()
+			if km.orderHook != nil {
+				km.orderHook.AfterAcquire(goroutine, sk.id, false)
+			}
+			for _, prev := range locked[:i] {
+				km.releaseShard(g, g.shards[prev.index])
+			}
+			return false
+		}
+		if km.timesWaits() {
+			km.observeWait(s, sk.id, time.Since(start))
+		}
+		km.acquired(s, sk.id)
+		if km.orderHook != nil {
+			km.orderHook.AfterAcquire(goroutine, sk.id, true)
+		}
+	}
+	if km.limit != nil && len(locked) > 0 {
+		km.waitLimitAll(g, locked)
+	}
+	for _, s := range reentered {
+		s.depth++
+	}
+	return true
+}
+
+// acquired records that s has just been locked on behalf of id.
+func (km *hashedKeyMutex) acquired(s *shard, id string) {
+	s.holder = append(s.holder[:0], id...)
+	if km.trackHeld {
+		s.setHeld(id, km.clock.Now())
+		if km.watchdog != nil {
+			km.armWatchdog()
+		}
+	}
+	if km.observer != nil {
+		km.observer.IncHeld(s.index)
+		if km.holdObserver != nil {
+			s.acquiredAt = km.clock.Now()
+		}
+	}
+	if km.classObserver != nil {
+		km.classObserver.IncHeld(km.classify(id))
+	}
+	switch km.owners {
+	case ownerPanicOnReentry, ownerPanicOnDeadlock:
+		s.setOwner(goroutineID())
+	case ownerReentrant:
+		s.setOwner(goroutineID())
+		s.depth = 1
+	}
+	atomic.StoreInt32(&s.state, shardHeld)
+	atomic.AddUint64(&s.acquisitions, 1)
+	if km.events != nil {
+		km.events.OnAcquire(id)
+	}
+}
+
+// release unlocks the lock held on behalf of id. It panics if id isn't held.
+func (km *hashedKeyMutex) release(id string) {
+	if km.owners == ownerReentrant {
+		g, s := km.ownedShard(id)
+		if s == nil {
+			// The lock may have been detached by TransferKey.
+			g, s = km.heldShard(id)
+		}
+		if s == nil {
+			g = km.current()
+			s = km.shardOf(g, id)
+		}
+		if s.exit(id) {
+			km.releaseShard(g, s)
+		}
+		return
+	}
+	g, s := km.heldShard(id)
+	if s == nil {
+		panicUnlocked(id)
+	}
+	km.releaseShard(g, s)
+}
+
+// releaseShard unlocks s, which is held and belongs to g.
+func (km *hashedKeyMutex) releaseShard(g *generation, s *shard) {
+	if km.owners == ownerPanicOnReentry || km.owners == ownerPanicOnDeadlock {
+		s.setOwner(0)
+	}
+	if km.trackHeld {
+		s.clearHeld()
+	}
+	if s.labelled {
+		s.clearLabel()
+	}
+	var held time.Duration
+	if km.holdObserver != nil {
+		held = km.clock.Since(s.acquiredAt)
+	}
+	var key string
+	if km.events != nil || km.orderHook != nil || km.classObserver != nil {
+		key = string(s.holder)
+	}
+	if km.orderHook != nil {
+		km.orderHook.BeforeRelease(goroutineID(), key)
+	}
+	if atomic.LoadUint64(&s.token) != 0 {
+		atomic.StoreUint64(&s.token, 0)
+	}
+	limited := s.limited
+	s.limited = false
+	km.leave(g, s)
+	s.unlock()
+	if limited {
+		km.releaseLimit()
+	}
+	if km.events != nil {
+		km.events.OnRelease(key)
+	}
+	if km.observer != nil {
+		km.observer.DecHeld(s.index)
+		if km.holdObserver != nil {
+			km.holdObserver.ObserveHoldDuration(s.index, held)
+		}
+	}
+	if km.classObserver != nil {
+		km.classObserver.DecHeld(km.classify(key))
+	}
+}
+
+func (km *hashedKeyMutex) isClosed() bool {
+	select {
+	case <-km.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkOpen panics if the KeyMutex has been closed.
+func (km *hashedKeyMutex) checkOpen() {
+	if km.isClosed() {
+		panic("keymutex: lock of closed KeyMutex")
+	}
+}
+
+// shardOf returns the lock of g which id hashes to.
+func (km *hashedKeyMutex) shardOf(g *generation, id string) *shard {
+	return g.shards[km.shardIndex(g, id)]
+}
+
+func (km *hashedKeyMutex) shardIndex(g *generation, id string) int {
+	// A single lock is shared by every key, so there is nothing to hash.
+	if len(g.shards) == 1 {
+		return 0
+	}
+	if km.pinned != nil {
+		return km.pinnedShardIndex(g, id)
+	}
+	if g.mask != 0 {
+		return int(km.hasher(id) & g.mask)
+	}
+	return int(km.hasher(id) % uint32(len(g.shards)))
+}
+
+// shardKey is a lock index along with one of the IDs which hash to it.
+type shardKey struct {
+	index int
+	id    string
+}
+
+// shardKeys returns the distinct locks of g which ids hash to, in ascending
+// order of index. Each lock is paired with the smallest of its IDs.
+func (km *hashedKeyMutex) shardKeys(g *generation, ids []string) []shardKey {
+	sks := make([]shardKey, 0, len(ids))
+	for _, id := range ids {
+		sks = append(sks, shardKey{index: km.shardIndex(g, id), id: id})
+	}
+	sort.Slice(sks, func(i, j int) bool {
+		if sks[i].index != sks[j].index {
+			return sks[i].index < sks[j].index
+		}
+		return sks[i].id < sks[j].id
+	})
+	unique := sks[:0]
+	for i, sk := range sks {
+		if i == 0 || sk.index != sks[i-1].index {
+			unique = append(unique, sk)
+		}
+	}
+	return unique
+}
+
+func containsShard(shards []*shard, s *shard) bool {
+	for _, other := range shards {
+		if other == s {
+			return true
+		}
+	}
+	return false
+}
+
+func panicUnlocked(id string) {
+	panic(fmt.Sprintf("keymutex: unlock of unlocked key %q", id))
+}
+
+// FNV-1a parameters, as used by hash/fnv.
+const (
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+// hash returns the FNV-1a hash of id, the same as hash/fnv does. It is
+// computed over the string directly, so that locking never allocates
+// regardless of how well the compiler optimizes hash/fnv.
+func hash(id string) uint32 {
+	h := uint32(fnvOffset32)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= fnvPrime32
+	}
+	return h
 }
@@ -0,0 +1,648 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Stats(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	reporter := km.(StatsReporter)
+	key := "fakeid"
+	index := int(hash(key) % 4)
+	callbackCh := make(chan interface{})
+	km.LockKey(key)
+
+	// Act
+	go lockAndCallback(km, key, callbackCh)
+	verifyEventually(t, func() bool { return reporter.Stats()[index].Waiters == 1 })
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+
+	// Assert
+	stats := reporter.Stats()
+	if len(stats) != 4 {
+		t.Fatalf("Expected 4 shard stats, got %d.", len(stats))
+	}
+	for i, stat := range stats {
+		if stat.Index != i {
+			t.Errorf("Expected stat %d to have index %d, got %d.", i, i, stat.Index)
+		}
+		if stat.Waiters != 0 {
+			t.Errorf("Expected no waiters on shard %d, got %d.", i, stat.Waiters)
+		}
+		expected := uint64(0)
+		if i == index {
+			expected = 1
+		}
+		if stat.Contended != expected {
+			t.Errorf("Expected shard %d to be contended %d times, got %d.", i, expected, stat.Contended)
+		}
+	}
+}
+
+func Test_ShardCount(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		km       interface{}
+		expected int
+	}{
+		{name: "NewHashed(4)", km: NewHashed(4), expected: 4},
+		{name: "NewHashed(0)", km: NewHashed(0), expected: 1},
+		{name: "NewHashed(-3)", km: NewHashed(-3), expected: 1},
+		{name: "NewReentrantHashed(3)", km: NewReentrantHashed(3), expected: 3},
+		{name: "NewHashedOf[int](5)", km: NewHashedOf[int](5), expected: 5},
+		{name: "NewHashedOf[int](0)", km: NewHashedOf[int](0), expected: 1},
+	} {
+		// Act
+		count := tc.km.(ShardCounter).ShardCount()
+
+		// Assert
+		if count != tc.expected {
+			t.Errorf("Expected %s to use %d shards, got %d.", tc.name, tc.expected, count)
+		}
+	}
+}
+
+func Test_Resize_ZeroLocks(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+
+	// Act
+	km.(Resizer).Resize(0)
+
+	// Assert
+	if count := km.(ShardCounter).ShardCount(); count != 1 {
+		t.Errorf("Expected Resize(0) to leave a single lock, got %d.", count)
+	}
+}
+
+func Test_ZeroShards_SingleShard(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		shards int
+	}{
+		{"NewOptimisticHashed", len(NewOptimisticHashed(0).(*optimisticKeyMutex).shards)},
+		{"NewExpiringHashed", len(NewExpiringHashed(0, time.Minute).(*expiringKeyMutex).shards)},
+		{"NewLeasedHashed", len(NewLeasedHashed(0, time.Minute).(*leasedKeyMutex).expiring.shards)},
+		{"NewRWHashed", len(NewRWHashed(0).(*rwHashedKeyMutex).mutexes)},
+		{"NewKeyedSemaphore", len(NewKeyedSemaphore(0, 2).(*hashedKeyedSemaphore).semaphores)},
+		{"NewWeightedKeyedSemaphore", len(NewWeightedKeyedSemaphore(0, 2).(*hashedWeightedKeyedSemaphore).semaphores)},
+		{"NewKeyedLimiter", len(NewKeyedLimiter(0, 1, 1).(*keyedLimiter).shards)},
+		{"NewKeyedCond", len(NewKeyedCond(0).(*keyedCond).shards)},
+		{"NewKeyedGroup", len(NewKeyedGroup(0).(*keyedGroup).shards)},
+		{"NewKeyedBarrier", len(NewKeyedBarrier(0).(*keyedBarrier).shards)},
+		{"NewKeyedOnce", NewKeyedOnce(0).(*keyedOnce).km.(ShardCounter).ShardCount()},
+		{"NewKeyedStore", NewKeyedStore[int](0).(*keyedStore[int]).km.(ShardCounter).ShardCount()},
+	} {
+		// Assert
+		if tc.shards != 1 {
+			t.Errorf("Expected %s(0) to use a single shard, got %d.", tc.name, tc.shards)
+		}
+	}
+}
+
+func Test_NewHashedStrict(t *testing.T) {
+	for _, n := range []int{-1, 0} {
+		// Act
+		km, err := NewHashedStrict(n)
+
+		// Assert
+		if err == nil || km != nil {
+			t.Errorf("Expected NewHashedStrict(%d) to fail, got %v.", n, km)
+		}
+	}
+
+	// Act
+	km, err := NewHashedStrict(3)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected NewHashedStrict(3) to succeed, got %v.", err)
+	}
+	if count := km.(ShardCounter).ShardCount(); count != 3 {
+		t.Fatalf("Expected NewHashedStrict(3) to use 3 shards, got %d.", count)
+	}
+}
+
+func Test_HashedWithHasher(t *testing.T) {
+	// Arrange
+	// Route keys by their last byte, so "a0" and "b0" share a lock while
+	// "a0" and "a1" do not.
+	hasher := func(id string) uint32 { return uint32(id[len(id)-1]) }
+	km := NewHashedWithHasher(2, hasher)
+
+	// Act & Assert
+	km.LockKey("a0")
+	if TryLockKey(km, "b0") {
+		t.Fatalf("Expected keys with the same hash to share a lock.")
+	}
+	if !TryLockKey(km, "a1") {
+		t.Fatalf("Expected keys with different hashes to use different locks.")
+	}
+	km.UnlockKey("a1")
+	km.UnlockKey("a0")
+}
+
+func Test_HashedWithHasher_Nil(t *testing.T) {
+	// Arrange
+	km := NewHashedWithHasher(4, nil)
+	key := "fakeid"
+
+	// Act & Assert
+	km.LockKey(key)
+	if TryLockKey(km, key) {
+		t.Fatalf("Expected TryLockKey to fail on a held key.")
+	}
+	km.UnlockKey(key)
+}
+
+func Test_Hashed_UnlockUnlocked(t *testing.T) {
+	testCases := []struct {
+		name   string
+		locked string
+	}{
+		{name: "nothing locked"},
+		{name: "other key sharing the lock", locked: "otherid"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			km := NewHashed(1)
+			key := "order-42"
+			if tc.locked != "" {
+				km.LockKey(tc.locked)
+				defer km.UnlockKey(tc.locked)
+			}
+
+			// Act
+			recovered := recoverPanic(func() {
+				km.UnlockKey(key)
+			})
+
+			// Assert
+			expected := fmt.Sprintf("keymutex: unlock of unlocked key %q", key)
+			if recovered != expected {
+				t.Errorf("Expected panic %q, got %v.", expected, recovered)
+			}
+		})
+	}
+}
+
+func Test_Close(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	key := "fakeid"
+	resultCh := make(chan bool)
+	km.LockKey(key)
+	go func() {
+		resultCh <- LockKeyWithContext(context.Background(), km, key)
+	}()
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+
+	// Act
+	km.(Closer).Close()
+
+	// Assert
+	select {
+	case acquired := <-resultCh:
+		if acquired {
+			t.Fatalf("Expected a waiting LockKeyWithContext to fail once closed.")
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for LockKeyWithContext to be woken by Close.")
+	}
+	if err := km.UnlockKey(key); err != nil {
+		t.Fatalf("Unexpected error unlocking a lock held before Close: %v", err)
+	}
+	if TryLockKey(km, key) {
+		t.Errorf("Expected TryLockKey to fail once closed.")
+	}
+	if LockKeyWithContext(context.Background(), km, key) {
+		t.Errorf("Expected LockKeyWithContext to fail once closed.")
+	}
+	if LockKeyWithTimeout(km, key, callbackTimeout) {
+		t.Errorf("Expected LockKeyWithTimeout to fail once closed.")
+	}
+	if err := LockKeyWithContextErr(context.Background(), km, key); err != ErrClosed {
+		t.Errorf("Expected LockKeyWithContextErr to return ErrClosed once closed, got %v.", err)
+	}
+	if recovered := recoverPanic(func() { km.LockKey(key) }); recovered == nil {
+		t.Errorf("Expected LockKey to panic once closed.")
+	}
+	km.(Closer).Close()
+}
+
+func Test_HeldKeys(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(1, WithHeldKeyTracking())
+	reporter := km.(HeldKeysReporter)
+	key := "fakeid"
+	before := time.Now()
+
+	// Act & Assert
+	if held := reporter.HeldKeys(); len(held) != 0 {
+		t.Fatalf("Expected no held keys, got %v.", held)
+	}
+	km.LockKey(key)
+	held := reporter.HeldKeys()
+	if len(held) != 1 || held[0].Key != key {
+		t.Fatalf("Expected %q to be held, got %v.", key, held)
+	}
+	if acquired := held[0].Acquired; acquired.Before(before) || acquired.After(time.Now()) {
+		t.Errorf("Unexpected acquisition time %v.", acquired)
+	}
+	km.UnlockKey(key)
+	if held := reporter.HeldKeys(); len(held) != 0 {
+		t.Errorf("Expected no held keys after unlocking, got %v.", held)
+	}
+	LockKeys(km, "b", "a")
+	if held := reporter.HeldKeys(); len(held) != 1 || held[0].Key != "a" {
+		t.Errorf("Expected keys sharing a lock to be reported as %q, got %v.", "a", held)
+	}
+	UnlockKeys(km, "a", "b")
+}
+
+func Test_Snapshot(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(2, WithHeldKeyTracking())
+	key := "fakeid"
+	callbackCh := make(chan interface{}, 1)
+	km.LockKey(key)
+	go lockAndCallback(km, key, callbackCh)
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+
+	// Act
+	data, err := json.Marshal(km.(Snapshotter).Snapshot())
+	if err != nil {
+		t.Fatalf("Expected the snapshot to encode, got %v.", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("Expected the snapshot to decode, got %v.", err)
+	}
+
+	// Assert
+	if snapshot.ShardCount != 2 || len(snapshot.Shards) != 2 {
+		t.Fatalf("Expected 2 shards, got %d and %d stats.", snapshot.ShardCount, len(snapshot.Shards))
+	}
+	if snapshot.Locked != 1 || snapshot.Waiters != 1 || snapshot.Contended != 1 || snapshot.Closed {
+		t.Fatalf("Expected one held lock with one waiter, got %s.", data)
+	}
+	if len(snapshot.HeldKeys) != 1 || snapshot.HeldKeys[0].Key != key || snapshot.HeldKeys[0].Acquired.IsZero() {
+		t.Fatalf("Expected %q to be held, got %v.", key, snapshot.HeldKeys)
+	}
+	index := km.(ShardIndexer).ShardIndex(key)
+	if stat := snapshot.Shards[index]; !stat.Held || stat.Waiters != 1 {
+		t.Fatalf("Expected shard %d to be held with one waiter, got %+v.", index, stat)
+	}
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+}
+
+func Test_HeldKeys_Disabled(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	km.LockKey("fakeid")
+	defer km.UnlockKey("fakeid")
+
+	// Act
+	held := km.(HeldKeysReporter).HeldKeys()
+
+	// Assert
+	if held != nil {
+		t.Errorf("Expected no held keys without tracking, got %v.", held)
+	}
+}
+
+func Test_ShardIndex(t *testing.T) {
+	for _, n := range []int{1, 3, 8} {
+		// Arrange
+		km := NewHashed(n)
+		indexer := km.(ShardIndexer)
+
+		// Act & Assert
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprint(i)
+			index := indexer.ShardIndex(key)
+			if index < 0 || index >= km.(ShardCounter).ShardCount() {
+				t.Fatalf("Expected the index of %q to be in [0, %d), got %d.", key, n, index)
+			}
+			if again := indexer.ShardIndex(key); again != index {
+				t.Fatalf("Expected the index of %q to be stable, got %d and %d.", key, index, again)
+			}
+		}
+	}
+}
+
+func Test_KeysForShard(t *testing.T) {
+	// Arrange
+	const n = 4
+	km := NewHashed(n)
+	indexer := km.(ShardIndexer)
+	var keys []string
+	for i := 0; i < 100; i++ {
+		keys = append(keys, fmt.Sprint(i))
+	}
+
+	// Act
+	partitions := make([][]string, n)
+	for shard := range partitions {
+		partitions[shard] = KeysForShard(indexer, shard, keys)
+	}
+
+	// Assert
+	total := 0
+	for shard, partition := range partitions {
+		total += len(partition)
+		for _, key := range partition {
+			if index := int(hash(key) % n); index != shard {
+				t.Errorf("Expected %q to belong to shard %d, got it for shard %d.", key, index, shard)
+			}
+		}
+		// Keys of different shards never contend.
+		if len(partition) > 0 {
+			km.LockKey(partition[0])
+		}
+	}
+	for _, partition := range partitions {
+		if len(partition) > 0 {
+			km.UnlockKey(partition[0])
+		}
+	}
+	if total != len(keys) {
+		t.Fatalf("Expected the partitions to cover all %d keys, got %d.", len(keys), total)
+	}
+}
+
+func Test_Locked(t *testing.T) {
+	for _, km := range []KeyMutex{NewHashed(64), NewFairHashed(64), NewReentrantHashed(64)} {
+		// Arrange
+		counter := km.(LockedCounter)
+		// These keys hash to distinct locks of 64.
+		keys := []string{"a", "b", "x", "y"}
+
+		// Act & Assert
+		for i, key := range keys {
+			km.LockKey(key)
+			if locked := counter.Locked(); locked != i+1 {
+				t.Fatalf("Expected %d locks to be held, got %d.", i+1, locked)
+			}
+		}
+		acquiredCh := make(chan bool)
+		go func() {
+			acquiredCh <- LockKeyWithTimeout(km, keys[0], 10*time.Millisecond)
+		}()
+		if <-acquiredCh {
+			t.Fatalf("Expected LockKeyWithTimeout to fail on a held key.")
+		}
+		if locked := counter.Locked(); locked != len(keys) {
+			t.Fatalf("Expected a failed acquisition not to count, got %d locks held.", locked)
+		}
+		if held := km.(StatsReporter).Stats()[hash(keys[0])%64].Held; !held {
+			t.Fatalf("Expected the stats to report the lock of %q held.", keys[0])
+		}
+		for i, key := range keys {
+			km.UnlockKey(key)
+			if locked := counter.Locked(); locked != len(keys)-i-1 {
+				t.Fatalf("Expected %d locks to be held, got %d.", len(keys)-i-1, locked)
+			}
+		}
+	}
+}
+
+func Test_Reset(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(4, WithHeldKeyTracking())
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+	km.LockKey(key)
+	go lockAndCallback(km, key, callbackCh)
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+	km.(Closer).Close()
+
+	// Act
+	km.(Resetter).Reset()
+
+	// Assert
+	if locked := km.(LockedCounter).Locked(); locked != 0 {
+		t.Fatalf("Expected no locks to be held after Reset, got %d.", locked)
+	}
+	for _, stat := range km.(StatsReporter).Stats() {
+		if stat.Contended != 0 {
+			t.Fatalf("Expected Reset to clear the contention of shard %d, got %d.", stat.Index, stat.Contended)
+		}
+	}
+	if held := km.(HeldKeysReporter).HeldKeys(); len(held) != 0 {
+		t.Fatalf("Expected no held keys after Reset, got %v.", held)
+	}
+	if !TryLockKey(km, key) {
+		t.Fatalf("Expected Reset to reopen a closed KeyMutex.")
+	}
+	if recovered := recoverPanic(km.(Resetter).Reset); recovered == nil {
+		t.Fatalf("Expected Reset to panic while a key is held.")
+	}
+	km.UnlockKey(key)
+}
+
+func Test_hash(t *testing.T) {
+	for _, id := range []string{"", "a", "fakeid", "order-42", "\xff\x00"} {
+		// Arrange
+		h := fnv.New32a()
+		h.Write([]byte(id))
+
+		// Act
+		got := hash(id)
+
+		// Assert
+		if expected := h.Sum32(); got != expected {
+			t.Errorf("Expected %q to hash to %d as with hash/fnv, got %d.", id, expected, got)
+		}
+	}
+}
+
+func Test_Hashed_NoAllocations(t *testing.T) {
+	if detectReentry {
+		t.Skip("Debug builds record the holder of each lock, which allocates.")
+	}
+	// Arrange
+	km := NewHashed(64)
+	key := "fakeid"
+
+	// Act
+	lockAllocs := testing.AllocsPerRun(100, func() {
+		km.LockKey(key)
+		km.UnlockKey(key)
+	})
+	tryLockAllocs := testing.AllocsPerRun(100, func() {
+		TryLockKey(km, key)
+		km.UnlockKey(key)
+	})
+
+	// Assert
+	if lockAllocs != 0 {
+		t.Errorf("Expected LockKey and UnlockKey not to allocate, got %v allocations.", lockAllocs)
+	}
+	if tryLockAllocs != 0 {
+		t.Errorf("Expected TryLockKey and UnlockKey not to allocate, got %v allocations.", tryLockAllocs)
+	}
+}
+
+// baselineKeyMutex is NewHashed as it was before any of the methods and
+// options which keep books on its locks were added: a sync.Mutex per lock and
+// nothing else. The hot path of NewHashed is benchmarked against it so that
+// its overhead doesn't grow unnoticed.
+type baselineKeyMutex struct {
+	mutexes []sync.Mutex
+}
+
+func (km *baselineKeyMutex) LockKey(id string) {
+	km.mutexes[hash(id)%uint32(len(km.mutexes))].Lock()
+}
+
+func (km *baselineKeyMutex) UnlockKey(id string) error {
+	km.mutexes[hash(id)%uint32(len(km.mutexes))].Unlock()
+	return nil
+}
+
+// lockUnlocker is the part of KeyMutex which baselineKeyMutex implements.
+type lockUnlocker interface {
+	LockKey(id string)
+	UnlockKey(id string) error
+}
+
+// benchmarkLockUnlock locks and unlocks a single key.
+func benchmarkLockUnlock(b *testing.B, km lockUnlocker) {
+	key := "fakeid"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		km.LockKey(key)
+		km.UnlockKey(key)
+	}
+}
+
+// benchmarkContended locks and unlocks a single key from many goroutines.
+func benchmarkContended(b *testing.B, km lockUnlocker) {
+	key := "fakeid"
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			km.LockKey(key)
+			km.UnlockKey(key)
+		}
+	})
+}
+
+func BenchmarkBaseline_LockUnlock(b *testing.B) {
+	benchmarkLockUnlock(b, &baselineKeyMutex{mutexes: make([]sync.Mutex, 64)})
+}
+
+func BenchmarkHashed_LockUnlock(b *testing.B) {
+	benchmarkLockUnlock(b, NewHashed(64))
+}
+
+func BenchmarkBaseline_Contended(b *testing.B) {
+	benchmarkContended(b, &baselineKeyMutex{mutexes: make([]sync.Mutex, 64)})
+}
+
+func BenchmarkHashed_Contended(b *testing.B) {
+	benchmarkContended(b, NewHashed(64))
+}
+
+// benchmarkLongKey locks and unlocks a single key long enough for hashing it
+// to show.
+func benchmarkLongKey(b *testing.B, km KeyMutex) {
+	key := "tenant/a/namespace/default/resource/pods/instance-0123456789"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		km.LockKey(key)
+		km.UnlockKey(key)
+	}
+}
+
+// BenchmarkHashed_SingleLock doesn't hash keys, since they all share the one
+// lock, but otherwise pays for the same bookkeeping as any other number of
+// locks. NewHashed(1) isn't a bare sync.Mutex, whose cost
+// BenchmarkSyncMutex_SingleLock shows for comparison.
+func BenchmarkHashed_SingleLock(b *testing.B) {
+	benchmarkLongKey(b, NewHashed(1))
+}
+
+func BenchmarkSyncMutex_SingleLock(b *testing.B) {
+	var m sync.Mutex
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Lock()
+		m.Unlock()
+	}
+}
+
+// BenchmarkHashedParallel measures the throughput of locking and unlocking
+// from many goroutines at once, for a matrix of lock counts, key
+// cardinalities and ways of acquiring the lock, to catch regressions on the
+// hot path. Run it with -cpu to see how throughput scales with GOMAXPROCS,
+// e.g. go test -run=^$ -bench=HashedParallel -cpu=1,2,4,8.
+func BenchmarkHashedParallel(b *testing.B) {
+	acquires := []struct {
+		name string
+		lock func(km KeyMutex, id string)
+	}{
+		{"LockKey", func(km KeyMutex, id string) { km.LockKey(id) }},
+		{"LockKeyWithContext", func(km KeyMutex, id string) { LockKeyWithContext(context.Background(), km, id) }},
+	}
+	for _, shards := range []int{1, 64, 1024} {
+		for _, keys := range []int{1, 10, 10000} {
+			ids := make([]string, keys)
+			for i := range ids {
+				ids[i] = fmt.Sprintf("key-%d", i)
+			}
+			for _, acquire := range acquires {
+				name := fmt.Sprintf("Shards=%d/Keys=%d/%s", shards, keys, acquire.name)
+				b.Run(name, func(b *testing.B) {
+					km := NewHashed(shards)
+					var workers uint32
+					b.ReportAllocs()
+					b.ResetTimer()
+					b.RunParallel(func(pb *testing.PB) {
+						// Start each goroutine at a different key, so they
+						// don't go through the keys in lockstep.
+						i := int(atomic.AddUint32(&workers, 1)) * 7919
+						for ; pb.Next(); i++ {
+							id := ids[i%len(ids)]
+							acquire.lock(km, id)
+							km.UnlockKey(id)
+						}
+					})
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,328 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// NewHashedOf returns a new instance of KeyMutexOf which hashes arbitrary
+// comparable keys to a fixed set of locks. `n` specifies number of locks, if
+// n <= 0, a single lock is shared by all keys, as with NewHashed.
+// Keys are hashed with FNV over a canonical byte encoding of their value, so
+// equal keys always map to the same lock. As with NewHashed, different keys
+// may share the same lock. Locking and unlocking keys doesn't allocate,
+// unless K holds interface values, which are encoded through reflection.
+func NewHashedOf[K comparable](n int) KeyMutexOf[K] {
+	if n <= 0 {
+		n = 1
+	}
+	return &hashedKeyMutexOf[K]{
+		shards: make([]keyShard[K], n),
+		hasher: newKeyHasher[K](),
+	}
+}
+
+var (
+	_ KeyMutexOf[int] = (*hashedKeyMutexOf[int])(nil)
+	_ ShardCounter    = (*hashedKeyMutexOf[int])(nil)
+)
+
+type hashedKeyMutexOf[K comparable] struct {
+	shards []keyShard[K]
+	hasher keyHasher[K]
+}
+
+// keyShard is a single lock of a KeyMutexOf.
+type keyShard[K comparable] struct {
+	mutex mutex
+	// held is set while the lock is held on behalf of holder, which is
+	// written by the holder right after locking.
+	held   int32
+	holder K
+}
+
+// Acquires a lock associated with the specified ID.
+func (km *hashedKeyMutexOf[K]) LockKey(id K) {
+	s := km.shard(&id)
+	s.mutex.lock()
+	s.acquired(id)
+}
+
+// Attempts to acquire the lock associated with the specified ID without blocking.
+func (km *hashedKeyMutexOf[K]) TryLockKey(id K) bool {
+	s := km.shard(&id)
+	if !s.mutex.tryLock() {
+		return false
+	}
+	s.acquired(id)
+	return true
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is done.
+func (km *hashedKeyMutexOf[K]) LockKeyWithContext(ctx context.Context, id K) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	s := km.shard(&id)
+	if !s.mutex.lockOrDone(ctx.Done()) {
+		return false
+	}
+	s.acquired(id)
+	return true
+}
+
+// Acquires a lock associated with the specified ID, giving up after d.
+func (km *hashedKeyMutexOf[K]) LockKeyWithTimeout(id K, d time.Duration) bool {
+	return lockKeyWithTimeout[K](km, id, d)
+}
+
+// Releases the lock associated with the specified ID.
+// Panics if the specified ID is not locked.
+func (km *hashedKeyMutexOf[K]) UnlockKey(id K) error {
+	s := km.shard(&id)
+	if atomic.LoadInt32(&s.held) == 0 || s.holder != id {
+		panic(fmt.Sprintf("keymutex: unlock of unlocked key %v", id))
+	}
+	var zero K
+	s.holder = zero
+	atomic.StoreInt32(&s.held, 0)
+	s.mutex.unlock()
+	return nil
+}
+
+// Returns the number of underlying locks.
+func (km *hashedKeyMutexOf[K]) ShardCount() int {
+	return len(km.shards)
+}
+
+func (km *hashedKeyMutexOf[K]) shard(id *K) *keyShard[K] {
+	if len(km.shards) == 1 {
+		return &km.shards[0]
+	}
+	return &km.shards[km.hasher.hash(id)%uint32(len(km.shards))]
+}
+
+// acquired records that s has just been locked on behalf of id.
+func (s *keyShard[K]) acquired(id K) {
+	s.holder = id
+	atomic.StoreInt32(&s.held, 1)
+}
+
+// keyHasher hashes keys of type K. The layout of K is worked out once, so
+// that hashing a key reads its values directly rather than through
+// reflection.
+type keyHasher[K comparable] struct {
+	// fields lists where the values a key is encoded from are found in it,
+	// in the order they are encoded in.
+	fields []keyField
+	// str is set if K is a string type, which is hashed exactly as NewHashed
+	// hashes its keys.
+	str bool
+	// reflective is set if K holds interface values, whose layout is only
+	// known once a key is hashed, so that keys are encoded through
+	// reflection instead.
+	reflective bool
+}
+
+// keyField is a value of a basic kind within a key.
+type keyField struct {
+	offset uintptr
+	kind   reflect.Kind
+}
+
+func newKeyHasher[K comparable]() keyHasher[K] {
+	t := reflect.TypeOf((*K)(nil)).Elem()
+	if t.Kind() == reflect.String {
+		return keyHasher[K]{str: true}
+	}
+	fields, ok := appendKeyFields(nil, t, 0)
+	return keyHasher[K]{fields: fields, reflective: !ok}
+}
+
+// appendKeyFields appends the values of basic kinds which a value of type t
+// at offset consists of to fields. It returns false if t holds interface
+// values.
+func appendKeyFields(fields []keyField, t reflect.Type, offset uintptr) ([]keyField, bool) {
+	ok := true
+	switch t.Kind() {
+	case reflect.Array:
+		for i := 0; ok && i < t.Len(); i++ {
+			fields, ok = appendKeyFields(fields, t.Elem(), offset+uintptr(i)*t.Elem().Size())
+		}
+	case reflect.Struct:
+		for i := 0; ok && i < t.NumField(); i++ {
+			// Blank fields don't take part in comparisons.
+			if f := t.Field(i); f.Name != "_" {
+				fields, ok = appendKeyFields(fields, f.Type, offset+f.Offset)
+			}
+		}
+	case reflect.Interface:
+		return nil, false
+	default:
+		fields = append(fields, keyField{offset: offset, kind: t.Kind()})
+	}
+	return fields, ok
+}
+
+// hash returns the FNV-1a hash of the canonical encoding of *id, which is
+// the same for keys which compare equal.
+func (kh *keyHasher[K]) hash(id *K) uint32 {
+	if kh.str {
+		return hash(*(*string)(unsafe.Pointer(id)))
+	}
+	if kh.reflective {
+		return hashValue(*id)
+	}
+	p := unsafe.Pointer(id)
+	h := uint32(fnvOffset32)
+	for _, f := range kh.fields {
+		v := unsafe.Add(p, f.offset)
+		switch f.kind {
+		case reflect.String:
+			s := *(*string)(v)
+			h = fnvString(fnvUint64(h, uint64(len(s))), s)
+		case reflect.Bool:
+			if *(*bool)(v) {
+				h = fnvUint64(h, 1)
+			} else {
+				h = fnvUint64(h, 0)
+			}
+		case reflect.Int:
+			h = fnvUint64(h, uint64(*(*int)(v)))
+		case reflect.Int8:
+			h = fnvUint64(h, uint64(*(*int8)(v)))
+		case reflect.Int16:
+			h = fnvUint64(h, uint64(*(*int16)(v)))
+		case reflect.Int32:
+			h = fnvUint64(h, uint64(*(*int32)(v)))
+		case reflect.Int64:
+			h = fnvUint64(h, uint64(*(*int64)(v)))
+		case reflect.Uint:
+			h = fnvUint64(h, uint64(*(*uint)(v)))
+		case reflect.Uint8:
+			h = fnvUint64(h, uint64(*(*uint8)(v)))
+		case reflect.Uint16:
+			h = fnvUint64(h, uint64(*(*uint16)(v)))
+		case reflect.Uint32:
+			h = fnvUint64(h, uint64(*(*uint32)(v)))
+		case reflect.Uint64:
+			h = fnvUint64(h, *(*uint64)(v))
+		case reflect.Uintptr:
+			h = fnvUint64(h, uint64(*(*uintptr)(v)))
+		case reflect.Float32:
+			h = fnvFloat(h, float64(*(*float32)(v)))
+		case reflect.Float64:
+			h = fnvFloat(h, *(*float64)(v))
+		case reflect.Complex64:
+			c := *(*complex64)(v)
+			h = fnvFloat(fnvFloat(h, float64(real(c))), float64(imag(c)))
+		case reflect.Complex128:
+			c := *(*complex128)(v)
+			h = fnvFloat(fnvFloat(h, real(c)), imag(c))
+		case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+			h = fnvUint64(h, uint64(uintptr(*(*unsafe.Pointer)(v))))
+		default:
+			panic("keymutex: cannot hash key of kind " + f.kind.String())
+		}
+	}
+	return h
+}
+
+// hashValue is like keyHasher.hash, for keys which hold interface values.
+func hashValue[K comparable](id K) uint32 {
+	return encodeValue(fnvOffset32, reflect.ValueOf(&id).Elem())
+}
+
+// encodeValue feeds a canonical encoding of a comparable value to the FNV-1a
+// hash h, the same as keyHasher.hash does, such that values which compare
+// equal produce the same hash.
+func encodeValue(h uint32, v reflect.Value) uint32 {
+	switch v.Kind() {
+	case reflect.String:
+		return fnvString(fnvUint64(h, uint64(v.Len())), v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			return fnvUint64(h, 1)
+		}
+		return fnvUint64(h, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fnvUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fnvUint64(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return fnvFloat(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return fnvFloat(fnvFloat(h, real(c)), imag(c))
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return fnvUint64(h, uint64(v.Pointer()))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			h = encodeValue(h, v.Index(i))
+		}
+		return h
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Name != "_" {
+				h = encodeValue(h, v.Field(i))
+			}
+		}
+		return h
+	case reflect.Interface:
+		if v.IsNil() {
+			return fnvUint64(h, 0)
+		}
+		e := v.Elem()
+		return encodeValue(fnvString(h, e.Type().String()), e)
+	}
+	panic("keymutex: cannot hash key of kind " + v.Kind().String())
+}
+
+// fnvString feeds the bytes of s to the FNV-1a hash h.
+func fnvString(h uint32, s string) uint32 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= fnvPrime32
+	}
+	return h
+}
+
+// fnvUint64 feeds the little endian bytes of u to the FNV-1a hash h.
+func fnvUint64(h uint32, u uint64) uint32 {
+	for i := 0; i < 8; i++ {
+		h ^= uint32(byte(u >> (8 * i)))
+		h *= fnvPrime32
+	}
+	return h
+}
+
+// fnvFloat feeds f to the FNV-1a hash h, such that floats which compare equal
+// are fed the same bytes.
+func fnvFloat(h uint32, f float64) uint32 {
+	if f == 0 {
+		// +0 and -0 compare equal.
+		f = 0
+	}
+	return fnvUint64(h, math.Float64bits(f))
+}
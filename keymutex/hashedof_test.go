@@ -0,0 +1,201 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeKey struct {
+	TenantID   string
+	ResourceID int
+}
+
+var _ KeyMutexOf[string] = NewHashed(1).(KeyMutexOf[string])
+
+func newKeyMutexesOf() []KeyMutexOf[fakeKey] {
+	return []KeyMutexOf[fakeKey]{
+		NewHashedOf[fakeKey](0),
+		NewHashedOf[fakeKey](1),
+		NewHashedOf[fakeKey](2),
+		NewHashedOf[fakeKey](4),
+	}
+}
+
+func Test_DoubleLock_DoubleUnlock_Of(t *testing.T) {
+	for _, km := range newKeyMutexesOf() {
+		// Arrange
+		key := fakeKey{TenantID: "tenant", ResourceID: 42}
+		callbackCh1stLock := make(chan interface{})
+		callbackCh2ndLock := make(chan interface{})
+
+		// Act & Assert
+		go lockOfAndCallback(km, key, callbackCh1stLock)
+		verifyCallbackHappens(t, callbackCh1stLock)
+		go lockOfAndCallback(km, fakeKey{TenantID: "tenant", ResourceID: 42}, callbackCh2ndLock)
+		verifyCallbackDoesntHappens(t, callbackCh2ndLock)
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackCh2ndLock)
+		km.UnlockKey(key)
+	}
+}
+
+func Test_TryLock_Of(t *testing.T) {
+	for _, km := range newKeyMutexesOf() {
+		// Arrange
+		key := fakeKey{TenantID: "tenant", ResourceID: 42}
+
+		// Act & Assert
+		if !km.TryLockKey(key) {
+			t.Fatalf("Expected TryLockKey to acquire a free key.")
+		}
+		if km.TryLockKey(key) {
+			t.Fatalf("Expected TryLockKey to fail on a held key.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_HashOf_Stable(t *testing.T) {
+	type nested struct {
+		Key   fakeKey
+		Flag  bool
+		Ratio float64
+	}
+	verifySameHash(t, fakeKey{"tenant", 1}, fakeKey{"tenant", 1})
+	verifySameHash(t, nested{fakeKey{"t", 2}, true, 0}, nested{fakeKey{"t", 2}, true, math.Copysign(0, -1)})
+	verifySameHash(t, [2]int{1, 2}, [2]int{1, 2})
+	verifySameHash(t, 7, 7)
+	if hashOf(fakeKey{"tenant", 1}) == hashOf(fakeKey{"tenant", 2}) {
+		t.Errorf("Expected distinct keys to hash differently.")
+	}
+	if hashOf("fakeid") != hash("fakeid") {
+		t.Errorf("Expected string keys to hash the same as NewHashed.")
+	}
+}
+
+func Test_HashOf_Interfaces(t *testing.T) {
+	// Keys holding interface values only satisfy comparable from Go 1.20 on,
+	// so check how they are encoded through reflection directly.
+	type withInterface struct {
+		Key   fakeKey
+		Value interface{}
+	}
+	encode := func(key withInterface) uint32 {
+		return encodeValue(fnvOffset32, reflect.ValueOf(key))
+	}
+	if _, ok := appendKeyFields(nil, reflect.TypeOf(withInterface{}), 0); ok {
+		t.Errorf("Expected keys holding interface values to be encoded through reflection.")
+	}
+	if encode(withInterface{fakeKey{"t", 2}, 3}) != encode(withInterface{fakeKey{"t", 2}, 3}) {
+		t.Errorf("Expected equal keys holding interface values to hash equally.")
+	}
+	if encode(withInterface{Value: 3}) == encode(withInterface{Value: "3"}) {
+		t.Errorf("Expected keys holding values of distinct types to hash differently.")
+	}
+}
+
+func Test_HashOf_MatchesReflection(t *testing.T) {
+	type nested struct {
+		Key    fakeKey
+		_      int
+		Flags  [2]bool
+		Ratio  float32
+		Offset int8
+		Ptr    *int
+		Pair   complex64
+	}
+	key := nested{Key: fakeKey{"tenant", -1}, Flags: [2]bool{true, false}, Ratio: 0.5, Offset: -2, Ptr: new(int), Pair: 1 + 2i}
+
+	// Act
+	got := hashOf(key)
+
+	// Assert
+	if expected := hashValue(key); got != expected {
+		t.Errorf("Expected %+v to hash to %d as through reflection, got %d.", key, expected, got)
+	}
+}
+
+func Test_HashedOf_NoAllocations(t *testing.T) {
+	// Arrange
+	km := NewHashedOf[fakeKey](64)
+	ints := NewHashedOf[int](64)
+	key := fakeKey{TenantID: "tenant", ResourceID: 42}
+
+	// Act
+	structAllocs := testing.AllocsPerRun(100, func() {
+		km.LockKey(key)
+		km.UnlockKey(key)
+	})
+	intAllocs := testing.AllocsPerRun(100, func() {
+		ints.LockKey(42)
+		ints.UnlockKey(42)
+	})
+
+	// Assert
+	if structAllocs != 0 {
+		t.Errorf("Expected locking a struct key not to allocate, got %v allocations.", structAllocs)
+	}
+	if intAllocs != 0 {
+		t.Errorf("Expected locking an int key not to allocate, got %v allocations.", intAllocs)
+	}
+}
+
+func Test_UnlockUnlocked_Of(t *testing.T) {
+	// Arrange
+	km := NewHashedOf[fakeKey](1)
+	held := fakeKey{TenantID: "tenant", ResourceID: 1}
+	km.LockKey(held)
+
+	// Act
+	recovered := recoverPanic(func() {
+		km.UnlockKey(fakeKey{TenantID: "tenant", ResourceID: 2})
+	})
+
+	// Assert
+	if recovered == nil {
+		t.Fatalf("Expected unlocking a key which isn't held to panic.")
+	}
+	if msg := fmt.Sprint(recovered); !strings.Contains(msg, "{tenant 2}") {
+		t.Errorf("Expected the panic to name the key, got %q.", msg)
+	}
+	if km.TryLockKey(held) {
+		t.Errorf("Expected the held key to stay held.")
+	}
+}
+
+func verifySameHash[K comparable](t *testing.T, a, b K) {
+	t.Helper()
+	if ha, hb := hashOf(a), hashOf(b); ha != hb {
+		t.Errorf("Expected equal keys %v and %v to hash equally, got %d and %d.", a, b, ha, hb)
+	}
+}
+
+// hashOf hashes id as NewHashedOf does.
+func hashOf[K comparable](id K) uint32 {
+	kh := newKeyHasher[K]()
+	return kh.hash(&id)
+}
+
+func lockOfAndCallback[K comparable](km KeyMutexOf[K], id K, callbackCh chan<- interface{}) {
+	km.LockKey(id)
+	callbackCh <- true
+}
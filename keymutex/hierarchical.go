@@ -0,0 +1,266 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// HierarchicalKeyMutex is a thread-safe interface for acquiring locks on
+// paths such as "tenant/a/resource/1", where a whole subtree of paths can be
+// locked at once by its prefix. Paths are split into segments at "/", and a
+// prefix covers the paths which equal it or start with it followed by "/",
+// so "tenant/a" covers "tenant/a/resource/1" but not "tenant/ab".
+//
+// A key lock only excludes the same key and the prefixes covering it, so
+// keys in the same subtree can be held at the same time. A prefix lock
+// excludes every key and prefix it covers, as well as the prefixes covering
+// it.
+type HierarchicalKeyMutex interface {
+	// Acquires the lock associated with the specified key.
+	LockKey(key string)
+
+	// Acquires the lock associated with the specified key, giving up once
+	// ctx is done. Returns false if ctx was done first, or is already done.
+	LockKeyWithContext(ctx context.Context, key string) bool
+
+	// Releases the lock associated with the specified key.
+	// Returns an error if the key is not locked.
+	UnlockKey(key string) error
+
+	// Acquires the lock associated with the specified prefix, waiting until
+	// no key or prefix it covers, and no prefix covering it, is held. While
+	// it waits, new locks on the keys it covers wait for it, so that a
+	// stream of key locks can't keep it waiting forever.
+	LockPrefix(prefix string)
+
+	// Acquires the lock associated with the specified prefix, as LockPrefix
+	// does, giving up once ctx is done. Returns false if ctx was done first,
+	// or is already done.
+	LockPrefixWithContext(ctx context.Context, prefix string) bool
+
+	// Releases the lock associated with the specified prefix.
+	// Returns an error if the prefix is not locked.
+	UnlockPrefix(prefix string) error
+}
+
+// NewHierarchical returns a new instance of HierarchicalKeyMutex. Since a
+// prefix lock has to know about the locks of every path below it, all locks
+// are tracked under a single mutex, and paths only take up memory while they
+// are locked or waited for.
+func NewHierarchical() HierarchicalKeyMutex {
+	return &hierarchicalKeyMutex{
+		keys:     make(map[string]bool),
+		prefixes: make(map[string]bool),
+		busy:     make(map[string]int),
+		pending:  make(map[string]int),
+	}
+}
+
+var _ HierarchicalKeyMutex = (*hierarchicalKeyMutex)(nil)
+
+type hierarchicalKeyMutex struct {
+	// lock guards the fields below.
+	lock sync.Mutex
+	// keys and prefixes hold the keys and prefixes which are locked.
+	keys     map[string]bool
+	prefixes map[string]bool
+	// busy counts the locked keys and prefixes at or below each path.
+	busy map[string]int
+	// pending counts the goroutines waiting to lock each prefix.
+	pending map[string]int
+	// changed is closed and replaced whenever a lock is released or a prefix
+	// stops waiting, so that waiters check again whether they can proceed.
+	changed chan struct{}
+}
+
+// Acquires the lock associated with the specified key.
+func (km *hierarchicalKeyMutex) LockKey(key string) {
+	km.lockKey(nil, key)
+}
+
+// Acquires the lock associated with the specified key, giving up when ctx is
+// done.
+func (km *hierarchicalKeyMutex) LockKeyWithContext(ctx context.Context, key string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return km.lockKey(ctx.Done(), key)
+}
+
+// Releases the lock associated with the specified key.
+func (km *hierarchicalKeyMutex) UnlockKey(key string) error {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	if !km.keys[key] {
+		return fmt.Errorf("keymutex: unlock of unlocked key %q", key)
+	}
+	delete(km.keys, key)
+	km.released(key)
+	return nil
+}
+
+// Acquires the lock associated with the specified prefix.
+func (km *hierarchicalKeyMutex) LockPrefix(prefix string) {
+	km.lockPrefix(nil, prefix)
+}
+
+// Acquires the lock associated with the specified prefix, giving up when ctx
+// is done.
+func (km *hierarchicalKeyMutex) LockPrefixWithContext(ctx context.Context, prefix string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return km.lockPrefix(ctx.Done(), prefix)
+}
+
+// Releases the lock associated with the specified prefix.
+func (km *hierarchicalKeyMutex) UnlockPrefix(prefix string) error {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	if !km.prefixes[prefix] {
+		return fmt.Errorf("keymutex: unlock of unlocked prefix %q", prefix)
+	}
+	delete(km.prefixes, prefix)
+	km.released(prefix)
+	return nil
+}
+
+// lockKey acquires key, giving up once done is closed.
+func (km *hierarchicalKeyMutex) lockKey(done <-chan struct{}, key string) bool {
+	paths := pathPrefixes(key)
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	for !km.keyFree(key, paths) {
+		if !km.wait(done) {
+			return false
+		}
+	}
+	km.keys[key] = true
+	km.acquired(paths)
+	return true
+}
+
+// lockPrefix acquires prefix, giving up once done is closed.
+func (km *hierarchicalKeyMutex) lockPrefix(done <-chan struct{}, prefix string) bool {
+	paths := pathPrefixes(prefix)
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	km.pending[prefix]++
+	defer func() {
+		if km.pending[prefix]--; km.pending[prefix] == 0 {
+			delete(km.pending, prefix)
+		}
+	}()
+	for !km.prefixFree(prefix, paths) {
+		if !km.wait(done) {
+			// Keys which waited for this prefix may proceed now.
+			km.notify()
+			return false
+		}
+	}
+	km.prefixes[prefix] = true
+	km.acquired(paths)
+	return true
+}
+
+// keyFree reports whether key can be locked, given the paths covering it.
+// km.lock must be held.
+func (km *hierarchicalKeyMutex) keyFree(key string, paths []string) bool {
+	if km.keys[key] {
+		return false
+	}
+	for _, path := range paths {
+		if km.prefixes[path] || km.pending[path] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixFree reports whether prefix can be locked, given the paths covering
+// it. km.lock must be held.
+func (km *hierarchicalKeyMutex) prefixFree(prefix string, paths []string) bool {
+	if km.busy[prefix] > 0 {
+		return false
+	}
+	for _, path := range paths {
+		if km.prefixes[path] {
+			return false
+		}
+	}
+	return true
+}
+
+// wait releases km.lock until the locks change or done is closed, and
+// reports whether they changed. km.lock must be held, and is held again when
+// wait returns.
+func (km *hierarchicalKeyMutex) wait(done <-chan struct{}) bool {
+	if km.changed == nil {
+		km.changed = make(chan struct{})
+	}
+	changed := km.changed
+	km.lock.Unlock()
+	defer km.lock.Lock()
+	select {
+	case <-changed:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// acquired counts a new lock at the end of paths. km.lock must be held.
+func (km *hierarchicalKeyMutex) acquired(paths []string) {
+	for _, path := range paths {
+		km.busy[path]++
+	}
+}
+
+// released uncounts the lock of path and wakes the waiters. km.lock must be
+// held.
+func (km *hierarchicalKeyMutex) released(path string) {
+	for _, p := range pathPrefixes(path) {
+		if km.busy[p]--; km.busy[p] == 0 {
+			delete(km.busy, p)
+		}
+	}
+	km.notify()
+}
+
+// notify wakes the waiters. km.lock must be held.
+func (km *hierarchicalKeyMutex) notify() {
+	if km.changed != nil {
+		close(km.changed)
+		km.changed = nil
+	}
+}
+
+// pathPrefixes returns the prefixes covering path, from the shortest one up
+// to path itself.
+func pathPrefixes(path string) []string {
+	prefixes := make([]string, 0, strings.Count(path, "/")+1)
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			prefixes = append(prefixes, path[:i])
+		}
+	}
+	return append(prefixes, path)
+}
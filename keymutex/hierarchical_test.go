@@ -0,0 +1,155 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_Hierarchical_PrefixBlocksDescendantKey(t *testing.T) {
+	// Arrange
+	km := NewHierarchical()
+	km.LockPrefix("tenant/a")
+	callbackCh := make(chan interface{})
+
+	// Act
+	go func() {
+		km.LockKey("tenant/a/resource/1")
+		callbackCh <- true
+	}()
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	if err := km.UnlockPrefix("tenant/a"); err != nil {
+		t.Fatalf("Unexpected error from UnlockPrefix: %v", err)
+	}
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey("tenant/a/resource/1")
+}
+
+func Test_Hierarchical_DescendantKeyBlocksPrefix(t *testing.T) {
+	// Arrange
+	km := NewHierarchical()
+	km.LockKey("tenant/a/resource/1")
+	callbackCh := make(chan interface{})
+
+	// Act
+	go func() {
+		km.LockPrefix("tenant/a")
+		callbackCh <- true
+	}()
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	if err := km.UnlockKey("tenant/a/resource/1"); err != nil {
+		t.Fatalf("Unexpected error from UnlockKey: %v", err)
+	}
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockPrefix("tenant/a")
+}
+
+func Test_Hierarchical_UnrelatedPathsDontBlock(t *testing.T) {
+	// Arrange
+	km := NewHierarchical()
+	km.LockPrefix("tenant/a")
+	km.LockKey("tenant/b/resource/1")
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	// Act & Assert
+	if !km.LockKeyWithContext(ctx, "tenant/ab/resource/1") {
+		t.Fatalf("Expected a prefix not to cover a path which only shares its leading characters.")
+	}
+	if !km.LockKeyWithContext(ctx, "tenant/b/resource/2") {
+		t.Fatalf("Expected keys below the same path not to block each other.")
+	}
+	if !km.LockPrefixWithContext(ctx, "tenant/c") {
+		t.Fatalf("Expected a prefix with no held descendants to be free.")
+	}
+	if km.LockPrefixWithContext(expiredContext(), "tenant/d") {
+		t.Fatalf("Expected LockPrefixWithContext with a done context to acquire nothing.")
+	}
+}
+
+func Test_Hierarchical_NestedPrefixes(t *testing.T) {
+	// Arrange
+	km := NewHierarchical()
+	km.LockPrefix("tenant/a/resource")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act
+	acquired := km.LockPrefixWithContext(ctx, "tenant")
+
+	// Assert
+	if acquired {
+		t.Fatalf("Expected a held prefix to block a prefix covering it.")
+	}
+	km.UnlockPrefix("tenant/a/resource")
+	km.LockPrefix("tenant")
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if km.LockPrefixWithContext(ctx, "tenant/a") {
+		t.Fatalf("Expected a held prefix to block the prefixes it covers.")
+	}
+	km.UnlockPrefix("tenant")
+}
+
+func Test_Hierarchical_WaitingPrefixBlocksNewKeys(t *testing.T) {
+	// Arrange
+	km := NewHierarchical()
+	km.LockKey("tenant/a/resource/1")
+	ctx, cancel := context.WithCancel(context.Background())
+	prefixCh := make(chan interface{})
+	go func() {
+		prefixCh <- km.LockPrefixWithContext(ctx, "tenant/a")
+	}()
+	verifyEventually(t, func() bool {
+		m := km.(*hierarchicalKeyMutex)
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		return m.pending["tenant/a"] > 0
+	})
+	keyCh := make(chan interface{})
+
+	// Act
+	go func() {
+		km.LockKey("tenant/a/resource/2")
+		keyCh <- true
+	}()
+
+	// Assert
+	verifyCallbackDoesntHappens(t, keyCh)
+	cancel()
+	if acquired := <-prefixCh; acquired.(bool) {
+		t.Fatalf("Expected the prefix to give up once ctx was cancelled.")
+	}
+	verifyCallbackHappens(t, keyCh)
+}
+
+func Test_Hierarchical_UnlockUnlocked(t *testing.T) {
+	km := NewHierarchical()
+	if err := km.UnlockKey("tenant/a/resource/1"); err == nil {
+		t.Fatalf("Expected an error unlocking a key which isn't locked.")
+	}
+	km.LockKey("tenant/a")
+	if err := km.UnlockPrefix("tenant/a"); err == nil {
+		t.Fatalf("Expected an error unlocking a prefix which is only locked as a key.")
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Blocks until no key is held, e.g. to let in-flight operations finish
+// during shutdown, returning ctx.Err() if ctx is done first. This is a
+// best-effort barrier: it doesn't stop keys from being locked again as soon as
+// it returns, which Close can be used for.
+// Rather than counting held keys on every acquisition, which would contend
+// across all locks, unlocking only checks whether the locks are idle while a
+// WaitIdle call is in progress.
+func (km *hashedKeyMutex) WaitIdle(ctx context.Context) error {
+	// Registering first ensures that a key unlocked after the check below
+	// wakes this call.
+	atomic.AddInt32(&km.idleWaiters, 1)
+	defer atomic.AddInt32(&km.idleWaiters, -1)
+	for {
+		wake := km.idleChan()
+		if km.isIdle() {
+			return nil
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Blocks until the lock associated with the specified ID is not held, or ctx
+// is done. Since keys share locks, this also waits while a different key
+// hashing to the same lock is held.
+func (km *hashedKeyMutex) WaitUntilFree(ctx context.Context, id string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	// Registering first ensures that a key unlocked after the check below
+	// wakes this call.
+	atomic.AddInt32(&km.freeWaiters, 1)
+	defer atomic.AddInt32(&km.freeWaiters, -1)
+	for {
+		wake := km.freeChan()
+		if !km.heldOnAnyGeneration(id) {
+			return true
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// heldOnAnyGeneration reports whether the lock id hashes to is held on any
+// generation. Unlike IsLocked, it reads the state leave clears before
+// notifying WaitUntilFree, so that a woken call doesn't find the lock still
+// locked and miss the wakeup, and it ignores locks taken briefly by callers
+// which never hold them on behalf of a key.
+func (km *hashedKeyMutex) heldOnAnyGeneration(id string) bool {
+	id = km.normalized(id)
+	for g := km.current(); g != nil; g = g.older() {
+		if atomic.LoadInt32(&km.shardOf(g, id).state) != shardFree {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdle reports whether none of the locks of any generation are held.
+func (km *hashedKeyMutex) isIdle() bool {
+	for g := km.current(); g != nil; g = g.older() {
+		if !g.drained() {
+			return false
+		}
+	}
+	return true
+}
+
+// idleChan returns the channel which is closed when WaitIdle calls should
+// check again whether the locks are idle.
+func (km *hashedKeyMutex) idleChan() <-chan struct{} {
+	km.idleLock.Lock()
+	defer km.idleLock.Unlock()
+	if km.idle == nil {
+		km.idle = make(chan struct{})
+	}
+	return km.idle
+}
+
+// freeChan returns the channel which is closed when WaitUntilFree calls should
+// check again whether their key is held.
+func (km *hashedKeyMutex) freeChan() <-chan struct{} {
+	km.idleLock.Lock()
+	defer km.idleLock.Unlock()
+	if km.free == nil {
+		km.free = make(chan struct{})
+	}
+	return km.free
+}
+
+// notifyFree wakes the WaitUntilFree calls in progress.
+func (km *hashedKeyMutex) notifyFree() {
+	km.idleLock.Lock()
+	defer km.idleLock.Unlock()
+	if km.free != nil {
+		close(km.free)
+		km.free = nil
+	}
+}
+
+// notifyIdle wakes the WaitIdle calls in progress if no lock is held.
+func (km *hashedKeyMutex) notifyIdle() {
+	if !km.isIdle() {
+		return
+	}
+	km.idleLock.Lock()
+	defer km.idleLock.Unlock()
+	if km.idle != nil {
+		close(km.idle)
+		km.idle = nil
+	}
+}
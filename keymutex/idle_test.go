@@ -0,0 +1,120 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_WaitIdle(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	waiter := km.(IdleWaiter)
+	resultCh := make(chan interface{})
+	km.LockKey("a")
+	LockKeys(km, "b", "c")
+
+	// Act
+	go func() {
+		resultCh <- waiter.WaitIdle(context.Background())
+	}()
+
+	// Assert
+	km.UnlockKey("a")
+	verifyCallbackDoesntHappens(t, resultCh)
+	UnlockKeys(km, "b", "c")
+	select {
+	case err := <-resultCh:
+		if err != nil {
+			t.Fatalf("Expected WaitIdle to succeed, got %v.", err)
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for WaitIdle to return once all keys were unlocked.")
+	}
+	if err := waiter.WaitIdle(context.Background()); err != nil {
+		t.Fatalf("Expected WaitIdle to return immediately when idle, got %v.", err)
+	}
+}
+
+func Test_WaitIdle_Context(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	km.LockKey("a")
+
+	// Act
+	err := km.(IdleWaiter).WaitIdle(ctx)
+
+	// Assert
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded while a key is held, got %v.", err)
+	}
+	km.UnlockKey("a")
+}
+
+func Test_WaitUntilFree(t *testing.T) {
+	// Arrange
+	km := NewHashed(1)
+	resultCh := make(chan interface{})
+	km.LockKey("a")
+
+	// Act
+	go func() {
+		resultCh <- km.(FreeWaiter).WaitUntilFree(context.Background(), "a")
+	}()
+
+	// Assert
+	verifyCallbackDoesntHappens(t, resultCh)
+	km.UnlockKey("a")
+	select {
+	case free := <-resultCh:
+		if !free.(bool) {
+			t.Fatalf("Expected WaitUntilFree to observe the key free.")
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for WaitUntilFree to return once the key was unlocked.")
+	}
+	if km.(LockInspector).IsLocked("a") {
+		t.Fatalf("Expected WaitUntilFree not to acquire the key.")
+	}
+	if !km.(FreeWaiter).WaitUntilFree(context.Background(), "a") {
+		t.Fatalf("Expected WaitUntilFree to return immediately for a free key.")
+	}
+}
+
+func Test_WaitUntilFree_Context(t *testing.T) {
+	// Arrange
+	km := NewHashed(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	km.LockKey("a")
+
+	// Act
+	free := km.(FreeWaiter).WaitUntilFree(ctx, "a")
+
+	// Assert
+	if free {
+		t.Fatalf("Expected WaitUntilFree to give up once ctx was done while the key is held.")
+	}
+	km.UnlockKey("a")
+	if km.(FreeWaiter).WaitUntilFree(expiredContext(), "a") {
+		t.Fatalf("Expected WaitUntilFree with a done context to return false.")
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"time"
+)
+
+// LockInspector is implemented by KeyMutex instances which can report on the
+// state of a key's lock, including those returned by NewHashed and NewPerKey.
+// None of its methods block or acquire the lock. Results are best-effort,
+// point-in-time snapshots: the lock may be acquired or released by the time
+// the caller acts on them.
+type LockInspector interface {
+	// Reports whether the lock associated with the specified ID is currently
+	// held.
+	IsLocked(id string) bool
+
+	// Returns the approximate number of goroutines currently blocked waiting
+	// for the lock associated with the specified ID.
+	WaitersCount(id string) int
+}
+
+// StatsReporter is implemented by KeyMutex instances which hash keys to a
+// fixed set of locks, such as those returned by NewHashed. Callers can
+// type-assert a KeyMutex to StatsReporter to inspect how well keys are spread
+// across the locks.
+type StatsReporter interface {
+	// Returns a point-in-time snapshot of the contention on each lock,
+	// ordered by lock index.
+	Stats() []ShardStat
+}
+
+// ShardCounter is implemented by KeyMutex and KeyMutexOf instances which hash
+// keys to a fixed set of locks, such as those returned by NewHashed,
+// NewHashedPow2 and NewHashedOf. The count reflects any defaulting or
+// rounding applied when they were created or last resized.
+type ShardCounter interface {
+	// Returns the number of locks keys are hashed to.
+	ShardCount() int
+}
+
+// LockedCounter is implemented by KeyMutex instances which can cheaply count
+// their held locks, such as those returned by NewHashed, e.g. to export as a
+// gauge.
+type LockedCounter interface {
+	// Returns the number of locks currently held. Keys which share a lock
+	// and were locked together by LockKeys count once, as do reentrant
+	// acquisitions of a lock by its holder.
+	Locked() int
+}
+
+// ShardIndexer is implemented by KeyMutex instances which hash keys to a
+// fixed set of locks, such as those returned by NewHashed. Keys with the same
+// index share a lock, and keys with different indexes never contend, so the
+// index can be used to partition keys between consumers which then never
+// wait on each other.
+type ShardIndexer interface {
+	// Returns the index, in [0, ShardCount()), of the lock the specified ID
+	// hashes to. It is the same index as in Stats and MetricsObserver, and
+	// stays the same for an ID until the number of locks is changed with
+	// Resize.
+	ShardIndex(id string) int
+}
+
+// KeysForShard returns the keys among ids which hash to the lock at index
+// shard of indexer, in the order they appear in ids.
+func KeysForShard(indexer ShardIndexer, shard int, ids []string) []string {
+	var keys []string
+	for _, id := range ids {
+		if indexer.ShardIndex(id) == shard {
+			keys = append(keys, id)
+		}
+	}
+	return keys
+}
+
+// Introspectable is implemented by KeyMutex instances which hash keys to a
+// fixed set of locks and can report on them, such as those returned by
+// NewHashed and the other constructors built on it. It combines the
+// introspection interfaces, for callers which want to type-assert once.
+type Introspectable interface {
+	LockInspector
+	StatsReporter
+	ShardCounter
+	LockedCounter
+	ShardIndexer
+}
+
+// ShardStat reports contention on one of the fixed set of locks of a hashed
+// KeyMutex. A few shards with much higher counts than the rest indicates
+// that a few hot keys dominate them.
+type ShardStat struct {
+	// Index of the lock.
+	Index int `json:"index"`
+	// Acquired is the number of times the lock was acquired.
+	Acquired uint64 `json:"acquired"`
+	// Contended is the number of times a goroutine had to block waiting for
+	// the lock.
+	Contended uint64 `json:"contended"`
+	// SameKeyContended is the number of those times the lock was held for
+	// the key being waited for, if the KeyMutex was created with
+	// WithContentionAttribution. Such true contention would remain with any
+	// number of locks.
+	SameKeyContended uint64 `json:"sameKeyContended"`
+	// OtherKeyContended is the number of those times the lock was held for
+	// another key hashing to the same lock, if the KeyMutex was created with
+	// WithContentionAttribution. Such false contention shrinks as the number
+	// of locks grows.
+	OtherKeyContended uint64 `json:"otherKeyContended"`
+	// Waiters is the number of goroutines currently blocked waiting for the
+	// lock.
+	Waiters int `json:"waiters"`
+	// Held reports whether the lock is currently held.
+	Held bool `json:"held"`
+}
+
+// HeldKeysReporter is implemented by KeyMutex instances which can list the
+// keys currently held, such as those returned by NewHashedWithOptions with
+// WithHeldKeyTracking.
+type HeldKeysReporter interface {
+	// Returns a best-effort snapshot of the keys currently held. Keys which
+	// share a lock and were locked together by LockKeys are reported once,
+	// under the smallest of them.
+	HeldKeys() []HeldKey
+}
+
+// HeldKey describes a key which is currently locked.
+type HeldKey struct {
+	// Key that was locked.
+	Key string `json:"key"`
+	// Acquired is when the lock was acquired.
+	Acquired time.Time `json:"acquired"`
+	// Label is the label the holder recorded by LockKeyTagged, if any.
+	Label string `json:"label,omitempty"`
+}
+
+// Snapshotter is implemented by KeyMutex instances which can describe their
+// whole state at once, such as those returned by NewHashed, e.g. for a debug
+// endpoint.
+type Snapshotter interface {
+	// Returns a best-effort snapshot of the state of every lock. The locks
+	// are inspected one after another without stopping them, so the
+	// snapshot may mix states from slightly different moments, but each
+	// lock is only inspected once, so its totals match its shards.
+	Snapshot() Snapshot
+}
+
+// Snapshot describes the state of a KeyMutex, as returned by Snapshotter.
+// It can be encoded with encoding/json.
+type Snapshot struct {
+	// Taken is when the snapshot was taken.
+	Taken time.Time `json:"taken"`
+	// ShardCount is the number of locks keys are hashed to.
+	ShardCount int `json:"shardCount"`
+	// Closed reports whether the KeyMutex has been closed.
+	Closed bool `json:"closed"`
+	// Locked is the number of locks held.
+	Locked int `json:"locked"`
+	// Waiters is the number of goroutines blocked waiting for any lock.
+	Waiters int `json:"waiters"`
+	// Contended is the number of times a goroutine had to block waiting for
+	// any lock.
+	Contended uint64 `json:"contended"`
+	// HeldKeys lists the keys held, as HeldKeysReporter does, if the
+	// KeyMutex was created with WithHeldKeyTracking.
+	HeldKeys []HeldKey `json:"heldKeys,omitempty"`
+	// Shards reports on each lock, as StatsReporter does.
+	Shards []ShardStat `json:"shards"`
+}
@@ -16,12 +16,655 @@ limitations under the License.
 
 package keymutex
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
 // KeyMutex is a thread-safe interface for acquiring locks on arbitrary strings.
+// Every string is a valid key, including the empty string, which is locked
+// like any other key and is distinct from all of them.
 type KeyMutex interface {
 	// Acquires a lock associated with the specified ID, creates the lock if one doesn't already exist.
 	LockKey(id string)
 
+	// Releases the lock associated with the specified ID. Releasing an ID
+	// which is not locked is a bug in the caller, which implementations
+	// report differently: those returned by NewHashed and the constructors
+	// built on it panic with a message naming the ID, those returned by
+	// NewOptimisticHashed panic, those returned by NewPerKey return an
+	// error, and the one returned by NewNoop can't tell and returns nil.
+	UnlockKey(id string) error
+}
+
+// TryLocker is implemented by KeyMutex instances whose locks can be tried
+// without blocking, which includes every KeyMutex returned by this package.
+type TryLocker interface {
+	// Attempts to acquire the lock associated with the specified ID without
+	// blocking, as TryLockKey does.
+	TryLockKey(id string) bool
+}
+
+// TryLockKey attempts to acquire the lock associated with id without
+// blocking. It returns true if the lock was acquired, false if it is
+// currently held. A lock acquired by TryLockKey is released with UnlockKey.
+// It panics if km doesn't implement TryLocker.
+func TryLockKey(km KeyMutex, id string) bool {
+	locker, ok := km.(TryLocker)
+	if !ok {
+		panic(fmt.Sprintf("keymutex: %T doesn't implement TryLocker", km))
+	}
+	return locker.TryLockKey(id)
+}
+
+// ContextLocker is implemented by KeyMutex instances whose waits for a lock
+// can be abandoned, which includes every KeyMutex returned by this package.
+type ContextLocker interface {
+	// Acquires a lock associated with the specified ID, giving up once ctx is
+	// done, as LockKeyWithContext does.
+	LockKeyWithContext(ctx context.Context, id string) bool
+}
+
+// LockKeyWithContext acquires the lock associated with id, giving up once ctx
+// is done. It returns true if the lock was acquired, false if ctx was done
+// first. If ctx is already done, the lock is not acquired even if it is free.
+// It panics if km doesn't implement ContextLocker, since a wait which can't be
+// abandoned would leave a goroutine behind to release the lock once it is
+// eventually acquired.
+func LockKeyWithContext(ctx context.Context, km KeyMutex, id string) bool {
+	locker, ok := km.(ContextLocker)
+	if !ok {
+		panic(fmt.Sprintf("keymutex: %T doesn't implement ContextLocker", km))
+	}
+	return locker.LockKeyWithContext(ctx, id)
+}
+
+// TimeoutLocker is implemented by KeyMutex instances which wait for a lock
+// for a limited time without setting up a context, such as those returned by
+// NewHashed and NewPerKey.
+type TimeoutLocker interface {
+	// Acquires a lock associated with the specified ID, waiting at most d,
+	// as LockKeyWithTimeout does.
+	LockKeyWithTimeout(id string, d time.Duration) bool
+}
+
+// LockKeyWithTimeout acquires the lock associated with id, waiting at most d.
+// It returns true if the lock was acquired, false if d elapsed first. A
+// d <= 0 does not wait at all and behaves like TryLockKey. A KeyMutex which
+// doesn't implement TimeoutLocker is waited for with TryLockKey or
+// LockKeyWithContext, and so must implement those instead.
+func LockKeyWithTimeout(km KeyMutex, id string, d time.Duration) bool {
+	if locker, ok := km.(TimeoutLocker); ok {
+		return locker.LockKeyWithTimeout(id, d)
+	}
+	if d <= 0 {
+		return TryLockKey(km, id)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return LockKeyWithContext(ctx, km, id)
+}
+
+// BatchLocker is implemented by KeyMutex instances which can acquire several
+// keys at once, such as those returned by NewHashed and NewPerKey, where
+// keys may share a lock which locking them one by one would then find held
+// by the batch itself.
+type BatchLocker interface {
+	// Acquires the locks associated with all of the specified IDs, as
+	// LockKeys does.
+	LockKeys(ids ...string)
+
+	// Releases the locks acquired by LockKeys with the same IDs.
+	UnlockKeys(ids ...string) error
+}
+
+// LockKeys acquires the locks associated with all of the specified IDs.
+// Locks are always taken in the same global order and duplicates are only
+// locked once, so concurrent LockKeys calls on overlapping IDs cannot
+// deadlock each other. The caller must release them with UnlockKeys and the
+// same IDs.
+func LockKeys(km KeyMutex, ids ...string) {
+	if batch, ok := km.(BatchLocker); ok {
+		batch.LockKeys(ids...)
+		return
+	}
+	for _, id := range sortedUnique(ids) {
+		km.LockKey(id)
+	}
+}
+
+// UnlockKeys releases the locks acquired by LockKeys with the same IDs.
+// Every ID is released even if unlocking some of them fails, and the first
+// error is returned.
+func UnlockKeys(km KeyMutex, ids ...string) error {
+	if batch, ok := km.(BatchLocker); ok {
+		return batch.UnlockKeys(ids...)
+	}
+	var firstErr error
+	for _, id := range sortedUnique(ids) {
+		if err := km.UnlockKey(id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ContextErrLocker is implemented by KeyMutex instances which report why a
+// wait for a lock ended, such as those returned by NewHashed and NewPerKey.
+type ContextErrLocker interface {
+	// Acquires a lock associated with the specified ID, giving up once ctx is
+	// done, as LockKeyWithContextErr does.
+	LockKeyWithContextErr(ctx context.Context, id string) error
+}
+
+// LockKeyWithContextErr is like LockKeyWithContext, but returns nil if the
+// lock associated with id was acquired and otherwise why it wasn't, so that
+// callers can tell a cancellation from an expired deadline: ctx.Err() if ctx
+// was done first, or ErrClosed or ErrWaitCancelled if the KeyMutex gave up
+// the wait for its own reasons. A KeyMutex which doesn't implement
+// ContextErrLocker reports ErrWaitCancelled for any wait it gives up while
+// ctx isn't done.
+func LockKeyWithContextErr(ctx context.Context, km KeyMutex, id string) error {
+	if locker, ok := km.(ContextErrLocker); ok {
+		return locker.LockKeyWithContextErr(ctx, id)
+	}
+	if LockKeyWithContext(ctx, km, id) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrWaitCancelled
+}
+
+// StopLocker is implemented by KeyMutex instances whose locks can be waited
+// for until a channel is closed, such as those returned by NewHashed and
+// NewPerKey.
+type StopLocker interface {
+	// Acquires a lock associated with the specified ID, giving up once stop
+	// is closed, as LockKeyWithStop does.
+	LockKeyWithStop(id string, stop <-chan struct{}) bool
+}
+
+// LockKeyWithStop acquires the lock associated with id, giving up once stop
+// is closed, for callers which signal cancellation with a channel rather than
+// a context. It returns true if the lock was acquired, false if stop was
+// closed first. If stop is already closed, the lock is not acquired even if
+// it is free. A KeyMutex which doesn't implement StopLocker is waited for
+// with LockKeyWithContext, with a context which a helper goroutine cancels
+// once stop is closed, and which exits when LockKeyWithStop returns.
+func LockKeyWithStop(km KeyMutex, id string, stop <-chan struct{}) bool {
+	if locker, ok := km.(StopLocker); ok {
+		return locker.LockKeyWithStop(id, stop)
+	}
+	if isStopped(stop) {
+		return false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return LockKeyWithContext(ctx, km, id)
+}
+
+// ErrClosed is returned by LockKeyWithContextErr when the KeyMutex has been
+// closed.
+var ErrClosed = errors.New("keymutex: KeyMutex is closed")
+
+// ErrWaitCancelled is returned by LockKeyWithContextErr when CancelWaiters
+// was called for the key while waiting for it.
+var ErrWaitCancelled = errors.New("keymutex: wait for key was cancelled")
+
+// WaiterCanceller is implemented by KeyMutex instances which can abort the
+// waits for a single key, such as those returned by NewHashed and NewPerKey.
+type WaiterCanceller interface {
+	// Makes every goroutine currently waiting for the specified ID in
+	// LockKeyWithContext, LockKeyWithContextErr, LockKeyWithStop or
+	// LockKeyWithTimeout give up as if its context were done. The holder of
+	// the lock, later waits, waits in LockKey and LockKeys, and waits for
+	// other keys are not affected, even for keys sharing the same lock.
+	CancelWaiters(id string)
+}
+
+// PriorityLocker is implemented by KeyMutex instances which can let some
+// waiters for a lock go ahead of others, such as those returned by
+// NewHashed. Priorities only take effect on KeyMutex instances which grant
+// their locks to waiters in order, such as those returned by NewFairHashed;
+// elsewhere waiters are granted locks in no particular order regardless.
+type PriorityLocker interface {
+	// Acquires a lock associated with the specified ID, giving up once ctx is
+	// done, as LockKeyWithContext does. Whenever the lock is released, it is
+	// granted to the waiter with the highest prio, and among those with the
+	// same prio to the one which started waiting first. Waiters with a low
+	// prio can therefore wait indefinitely while waiters with a higher prio
+	// keep arriving. Other methods wait with a prio of 0.
+	LockKeyWithPriority(ctx context.Context, id string, prio int) bool
+}
+
+// Closer is implemented by KeyMutex instances which can stop accepting new
+// locks, such as those returned by NewHashed.
+type Closer interface {
+	// Stops accepting new locks. Waits which can fail, such as those in
+	// LockKeyWithContext, fail immediately, while already held locks can
+	// still be unlocked.
+	Close()
+}
+
+// Resetter is implemented by KeyMutex instances which can be returned to the
+// state they were created in, such as those returned by NewHashed and
+// NewPerKey, so that test fixtures can reuse them between test cases.
+type Resetter interface {
+	// Returns the KeyMutex to its initial state, clearing statistics and
+	// reopening it if it was closed. Panics if any key is still held, which
+	// usually means the previous test case leaked a lock. Must not be called
+	// concurrently with any other method.
+	Reset()
+}
+
+// IdleWaiter is implemented by KeyMutex instances which can wait until none
+// of their keys are held, such as those returned by NewHashed.
+type IdleWaiter interface {
+	// Blocks until no key is held, returning ctx.Err() if ctx is done first.
+	// Keys may be locked again as soon as it returns.
+	WaitIdle(ctx context.Context) error
+}
+
+// FreeWaiter is implemented by KeyMutex instances which can wait until a key
+// is not held without acquiring it, such as those returned by NewHashed.
+type FreeWaiter interface {
+	// Blocks until the lock associated with the specified ID is not held,
+	// e.g. to wait for the operation in progress on a key to finish,
+	// returning false if ctx is done first. This is inherently racy: the key
+	// may be locked again as soon as it returns, so the caller must not rely
+	// on it still being free.
+	WaitUntilFree(ctx context.Context, id string) bool
+}
+
+// Resizer is implemented by KeyMutex instances whose number of locks can be
+// changed while they are in use, such as those returned by NewHashed.
+type Resizer interface {
+	// Rehashes keys onto n locks. Locks which are already held are unlocked
+	// as usual, and keys stay mutually exclusive throughout.
+	Resize(n int)
+}
+
+// Warmer is implemented by KeyMutex instances which allocate the lock of each
+// key when it is first needed, and can allocate them ahead of time instead,
+// such as those returned by NewPerKey.
+type Warmer interface {
+	// Allocates the locks associated with the specified IDs without
+	// acquiring them, so that acquiring them later doesn't allocate. Locks
+	// allocated this way can still be freed once they are no longer needed.
+	Warm(ids ...string)
+}
+
+// ByteKeyMutex is implemented by KeyMutex instances which can lock keys held
+// in byte slices without converting them to strings, such as those returned
+// by NewHashed. A byte slice key is the same key as the string with the same
+// bytes, and key is only read for the duration of each call.
+type ByteKeyMutex interface {
+	// Acquires a lock associated with the specified key.
+	LockKeyBytes(key []byte)
+
+	// Releases the lock associated with the specified key, as UnlockKey does.
+	UnlockKeyBytes(key []byte) error
+}
+
+// KeyMutexOf is a thread-safe interface for acquiring locks on arbitrary
+// comparable keys. KeyMutexOf[string] has the same methods as KeyMutex, so
+// any KeyMutex can be used where a KeyMutexOf[string] is expected.
+type KeyMutexOf[K comparable] interface {
+	// Acquires a lock associated with the specified ID.
+	LockKey(id K)
+
+	// Attempts to acquire the lock associated with the specified ID without blocking.
+	// Returns true if the lock was acquired, false if it is currently held.
+	TryLockKey(id K) bool
+
+	// Acquires a lock associated with the specified ID, giving up once ctx is done.
+	// Returns true if the lock was acquired, false if ctx was done first.
+	// If ctx is already done, the lock is not acquired even if it is free.
+	LockKeyWithContext(ctx context.Context, id K) bool
+
+	// Acquires a lock associated with the specified ID, waiting at most d.
+	// A d <= 0 does not wait at all and behaves like TryLockKey.
+	LockKeyWithTimeout(id K, d time.Duration) bool
+
 	// Releases the lock associated with the specified ID.
-	// Returns an error if the specified ID doesn't exist.
+	UnlockKey(id K) error
+}
+
+// RWKeyMutex is a thread-safe interface for acquiring reader/writer locks on
+// arbitrary strings. Any number of readers or a single writer may hold the
+// lock associated with an ID at a time.
+type RWKeyMutex interface {
+	// Acquires the write lock associated with the specified ID.
+	LockKey(id string)
+
+	// Releases the write lock associated with the specified ID.
 	UnlockKey(id string) error
+
+	// Acquires a read lock associated with the specified ID.
+	RLockKey(id string)
+
+	// Releases a read lock associated with the specified ID.
+	RUnlockKey(id string) error
+}
+
+// KeyUpgrader is implemented by RWKeyMutex instances which can turn a held
+// read lock into the write lock and back without releasing it in between,
+// such as those returned by NewRWHashed.
+//
+// Upgrading never waits for other readers to go: if two readers of a key
+// both waited to upgrade, each would wait for the other forever. Instead,
+// UpgradeKey fails while other readers are present, and the caller should
+// release its read lock, acquire the write lock and check again whatever it
+// read, since it may have changed in between.
+type KeyUpgrader interface {
+	// Turns the caller's read lock associated with the specified ID into the
+	// write lock. Returns false, keeping the read lock, if other readers
+	// hold the lock or a writer waits for it. The caller must hold a read
+	// lock on the ID.
+	UpgradeKey(id string) bool
+
+	// Turns the caller's write lock associated with the specified ID into a
+	// read lock, without letting any other writer in between.
+	DowngradeKey(id string) error
+}
+
+// RWContextLocker is implemented by RWKeyMutex instances whose read and write
+// locks can be acquired with a context bounding the wait, such as those
+// returned by NewRWHashed. Since a waiting writer keeps new readers out,
+// readers may otherwise wait long for a contended key, and writers for its
+// readers.
+type RWContextLocker interface {
+	// Acquires the write lock associated with the specified ID, giving up
+	// once ctx is done. Returns true if the lock was acquired, false if ctx
+	// was done first. If ctx is already done, the lock is not acquired even
+	// if it is free.
+	LockKeyWithContext(ctx context.Context, id string) bool
+
+	// Acquires a read lock associated with the specified ID, giving up once
+	// ctx is done, as LockKeyWithContext does.
+	RLockKeyWithContext(ctx context.Context, id string) bool
+}
+
+// LockKeyWithContention acquires the lock associated with id, reporting
+// whether the caller had to wait for it. It first attempts a non-blocking
+// acquisition and only blocks if that fails.
+func LockKeyWithContention(km KeyMutex, id string) (contended bool) {
+	if TryLockKey(km, id) {
+		return false
+	}
+	km.LockKey(id)
+	return true
+}
+
+// TryLockKeyWithContext acquires the lock associated with id, giving up once
+// ctx is done. It first attempts a non-blocking acquisition and only waits if
+// that fails, reporting both whether the lock was acquired and whether the
+// caller had to wait, so that callers can measure how often the fast path is
+// taken. If ctx is already done, the lock is not acquired.
+func TryLockKeyWithContext(ctx context.Context, km KeyMutex, id string) (acquired, blocked bool) {
+	if ctx.Err() != nil {
+		return false, false
+	}
+	if TryLockKey(km, id) {
+		return true, false
+	}
+	return LockKeyWithContext(ctx, km, id), true
+}
+
+// NoDeadline is the remaining time LockKeyWithHeadroom reports for a context
+// without a deadline.
+const NoDeadline time.Duration = -1
+
+// LockKeyWithHeadroom acquires the lock associated with id, giving up once ctx
+// is done, as LockKeyWithContext does. Once acquired, it also reports how much
+// time was left until the deadline of ctx, so that callers can decide whether
+// the work the lock protects is still worth starting. remaining is NoDeadline
+// if ctx has no deadline, and 0 if the lock wasn't acquired.
+func LockKeyWithHeadroom(ctx context.Context, km KeyMutex, id string) (acquired bool, remaining time.Duration) {
+	if !LockKeyWithContext(ctx, km, id) {
+		return false, 0
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true, NoDeadline
+	}
+	if remaining = time.Until(deadline); remaining < 0 {
+		// The deadline passed right after acquiring.
+		remaining = 0
+	}
+	return true, remaining
+}
+
+// LockKeyOrElse acquires the lock associated with id if it is free, and
+// otherwise calls busy instead of waiting. It reports whether the lock was
+// acquired, in which case the caller must release it and busy is not called.
+func LockKeyOrElse(km KeyMutex, id string, busy func()) (acquired bool) {
+	if TryLockKey(km, id) {
+		return true
+	}
+	busy()
+	return false
+}
+
+// LockKeyIf acquires the lock associated with id and then calls cond while
+// holding it, for checking under the lock a condition which was only seen to
+// hold before acquiring it. If cond returns false, or panics, the lock is
+// released again before LockKeyIf returns. It reports whether the caller
+// holds the lock, in which case the caller must release it.
+func LockKeyIf(km KeyMutex, id string, cond func() bool) (acquired bool) {
+	km.LockKey(id)
+	defer func() {
+		if !acquired {
+			km.UnlockKey(id)
+		}
+	}()
+	return cond()
+}
+
+// LockKeyWithBackoff acquires the lock associated with id by polling it with
+// TryLockKey rather than waiting for it, giving up once ctx is done. The
+// delay between attempts starts at base and doubles after each failed
+// attempt up to maxDelay, and each delay is jittered randomly between half
+// and all of it, so that goroutines which failed together don't all retry
+// together. It reports whether the lock was acquired. If ctx is already done,
+// the lock is not acquired.
+func LockKeyWithBackoff(ctx context.Context, km KeyMutex, id string, base, maxDelay time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if base <= 0 {
+		base = 1
+	}
+	if maxDelay < base {
+		maxDelay = base
+	}
+	var timer *time.Timer
+	for delay := base; ; {
+		if TryLockKey(km, id) {
+			return true
+		}
+		sleep := delay/2 + time.Duration(rand.Int63n(int64(delay-delay/2)+1))
+		if timer == nil {
+			timer = time.NewTimer(sleep)
+			defer timer.Stop()
+		} else {
+			timer.Reset(sleep)
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+		}
+		if delay > maxDelay/2 {
+			delay = maxDelay
+		} else {
+			delay *= 2
+		}
+	}
+}
+
+// TryLockKeys attempts to acquire the lock associated with each of the
+// specified IDs without blocking, and returns the IDs it acquired, in the
+// order given. The caller must release exactly those, for example with
+// UnlockAll. Since locks are only tried, IDs may be given in any order.
+// If trying an ID panics, the IDs already acquired are released.
+func TryLockKeys(km KeyMutex, ids ...string) (acquired []string) {
+	acquired = make([]string, 0, len(ids))
+	done := false
+	defer func() {
+		if !done {
+			for _, id := range acquired {
+				km.UnlockKey(id)
+			}
+		}
+	}()
+	for _, id := range ids {
+		if TryLockKey(km, id) {
+			acquired = append(acquired, id)
+		}
+	}
+	done = true
+	return acquired
+}
+
+// BatchTryLocker is implemented by KeyMutex instances which can try to
+// acquire several keys at once, such as those returned by NewHashed, where
+// keys may share a lock which TryLockKey would then find held by the batch
+// itself.
+type BatchTryLocker interface {
+	// Attempts to acquire the locks associated with all of the specified IDs
+	// without blocking, as TryLockAll does.
+	TryLockAll(ids ...string) bool
+}
+
+// TryLockAll attempts to acquire the locks associated with all of the
+// specified IDs without blocking, and either acquires all of them or none.
+// IDs are tried in the same global order as LockKeys takes them, and
+// duplicates are only tried once. If any of them is held, those already
+// acquired are released again and false is returned. Otherwise the caller
+// must release them with UnlockKeys and the same IDs.
+func TryLockAll(km KeyMutex, ids ...string) bool {
+	if batch, ok := km.(BatchTryLocker); ok {
+		return batch.TryLockAll(ids...)
+	}
+	ids = sortedUnique(ids)
+	for i, id := range ids {
+		if !TryLockKey(km, id) {
+			for _, acquired := range ids[:i] {
+				km.UnlockKey(acquired)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// ContextBatchLocker is implemented by KeyMutex instances which can acquire
+// several keys at once while giving up once a context is done, such as those
+// returned by NewHashed.
+type ContextBatchLocker interface {
+	// Acquires the locks associated with all of the specified IDs, giving up
+	// once ctx is done, as LockKeysWithContext does.
+	LockKeysWithContext(ctx context.Context, ids ...string) bool
+}
+
+// LockKeysWithContext acquires the locks associated with all of the specified
+// IDs, giving up once ctx is done, and either acquires all of them or none.
+// IDs are locked in the same global order as LockKeys takes them, and
+// duplicates are only locked once. If ctx is done while waiting for any of
+// them, those already acquired are released again and false is returned.
+// Otherwise the caller must release them with UnlockKeys and the same IDs.
+// If ctx is already done, no lock is acquired even if all of them are free.
+func LockKeysWithContext(ctx context.Context, km KeyMutex, ids ...string) bool {
+	if batch, ok := km.(ContextBatchLocker); ok {
+		return batch.LockKeysWithContext(ctx, ids...)
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	ids = sortedUnique(ids)
+	for i, id := range ids {
+		if !LockKeyWithContext(ctx, km, id) {
+			for _, acquired := range ids[:i] {
+				km.UnlockKey(acquired)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// UnlockAll releases the locks associated with each of the specified IDs
+// with UnlockKey, in the given order, for cleaning up after a batch of
+// locks which failed midway. Every ID is released even if unlocking some of
+// them fails: the first error is returned, and if any unlock panicked,
+// UnlockAll panics once all IDs have been released, naming each ID which
+// failed.
+func UnlockAll(km KeyMutex, ids ...string) error {
+	var firstErr error
+	var panics []string
+	for _, id := range ids {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					panics = append(panics, fmt.Sprintf("%q: %v", id, r))
+				}
+			}()
+			if err := km.UnlockKey(id); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+	if len(panics) > 0 {
+		panic(fmt.Sprintf("keymutex: unlock of %d keys failed: %s", len(panics), strings.Join(panics, "; ")))
+	}
+	return firstErr
+}
+
+// lockKeyWithTimeout implements LockKeyWithTimeout in terms of the other
+// KeyMutex methods.
+func lockKeyWithTimeout[K comparable](km KeyMutexOf[K], id K, d time.Duration) bool {
+	if d <= 0 {
+		return km.TryLockKey(id)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return km.LockKeyWithContext(ctx, id)
+}
+
+// isStopped reports whether stop has been closed. A nil stop never is.
+func isStopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sortedUnique returns a sorted copy of ids with duplicates removed.
+func sortedUnique(ids []string) []string {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, id := range sorted {
+		if i == 0 || id != sorted[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
 }
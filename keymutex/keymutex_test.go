@@ -17,6 +17,13 @@ limitations under the License.
 package keymutex
 
 import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -31,6 +38,10 @@ func newKeyMutexes() []KeyMutex {
 		NewHashed(1),
 		NewHashed(2),
 		NewHashed(4),
+		NewPerKey(),
+		NewFairHashed(2),
+		NewHashedWithStrategy(2, MutexStrategy),
+		NewHashedWithStrategy(2, SpinStrategy),
 	}
 }
 
@@ -79,6 +90,799 @@ func Test_DoubleLock_DoubleUnlock(t *testing.T) {
 	}
 }
 
+func Test_Lock_EmptyKey(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := ""
+		callbackCh := make(chan interface{})
+		// The other key must not share a lock with the empty key, which
+		// it can't avoid with a single lock.
+		other, independent := "fakeid", true
+		if indexer, ok := km.(ShardIndexer); ok {
+			independent = km.(ShardCounter).ShardCount() > 1
+			for i := 0; independent && indexer.ShardIndex(other) == indexer.ShardIndex(key); i++ {
+				other = fmt.Sprintf("fakeid%d", i)
+			}
+		}
+
+		// Act & Assert
+		go lockAndCallback(km, key, callbackCh)
+		verifyCallbackHappens(t, callbackCh)
+		if TryLockKey(km, key) {
+			t.Fatalf("Expected TryLockKey to fail on the held empty key.")
+		}
+		if independent {
+			if !TryLockKey(km, other) {
+				t.Fatalf("Expected TryLockKey to acquire %q while the empty key is held.", other)
+			}
+			km.UnlockKey(other)
+		}
+		go lockAndCallback(km, key, callbackCh)
+		verifyCallbackDoesntHappens(t, callbackCh)
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackCh)
+		km.UnlockKey(key)
+	}
+}
+
+func Test_TryLock(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+
+		// Act & Assert
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected TryLockKey to acquire a free key.")
+		}
+		if TryLockKey(km, key) {
+			t.Fatalf("Expected TryLockKey to fail on a held key.")
+		}
+		km.UnlockKey(key)
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected TryLockKey to acquire a released key.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_TryLock_LockKey(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		callbackCh := make(chan interface{})
+
+		// Act & Assert
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected TryLockKey to acquire a free key.")
+		}
+		go lockAndCallback(km, key, callbackCh)
+		verifyCallbackDoesntHappens(t, callbackCh)
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackCh)
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockWithContext_Cancel(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		ctx, cancel := context.WithCancel(context.Background())
+		resultCh := make(chan bool)
+		km.LockKey(key)
+
+		// Act
+		go func() {
+			resultCh <- LockKeyWithContext(ctx, km, key)
+		}()
+		cancel()
+
+		// Assert
+		select {
+		case acquired := <-resultCh:
+			if acquired {
+				t.Fatalf("Expected LockKeyWithContext to give up on a held key.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for LockKeyWithContext to return.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockWithContext_AlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	key := "fakeid"
+	for _, km := range newKeyMutexes() {
+		// Act
+		// Retry, since a free lock and a done context could otherwise race.
+		for i := 0; i < 100; i++ {
+			if LockKeyWithContext(ctx, km, key) {
+				t.Fatalf("Expected LockKeyWithContext not to acquire a free key with a done context.")
+			}
+			if err := LockKeyWithContextErr(ctx, km, key); err != context.Canceled {
+				t.Fatalf("Expected LockKeyWithContextErr to return context.Canceled, got %v.", err)
+			}
+		}
+
+		// Assert
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected the key not to be held.")
+		}
+		km.UnlockKey(key)
+	}
+
+	of := NewHashedOf[int](1)
+	expiring := NewExpiringHashed(1, time.Minute)
+	for i := 0; i < 100; i++ {
+		if of.LockKeyWithContext(ctx, 1) {
+			t.Fatalf("Expected NewHashedOf not to acquire a free key with a done context.")
+		}
+		if _, acquired := expiring.LockKeyWithContext(ctx, key); acquired {
+			t.Fatalf("Expected NewExpiringHashed not to acquire a free key with a done context.")
+		}
+	}
+	if !of.TryLockKey(1) {
+		t.Fatalf("Expected the key of NewHashedOf not to be held.")
+	}
+}
+
+func Test_LockWithContext_Acquire(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		resultCh := make(chan bool)
+		km.LockKey(key)
+
+		// Act
+		go func() {
+			resultCh <- LockKeyWithContext(context.Background(), km, key)
+		}()
+		km.UnlockKey(key)
+
+		// Assert
+		select {
+		case acquired := <-resultCh:
+			if !acquired {
+				t.Fatalf("Expected LockKeyWithContext to acquire a released key.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for LockKeyWithContext to return.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockWithContextErr(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		// Act & Assert
+		if err := LockKeyWithContextErr(context.Background(), km, key); err != nil {
+			t.Fatalf("Expected LockKeyWithContextErr to acquire a free key, got %v.", err)
+		}
+		if err := LockKeyWithContextErr(cancelled, km, key); err != context.Canceled {
+			t.Fatalf("Expected context.Canceled for a held key, got %v.", err)
+		}
+		if err := LockKeyWithContextErr(expired, km, key); err != context.DeadlineExceeded {
+			t.Fatalf("Expected context.DeadlineExceeded for a held key, got %v.", err)
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockWithStop(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		stop := make(chan struct{})
+		resultCh := make(chan bool)
+		stopped := make(chan struct{})
+		close(stopped)
+
+		// Act & Assert
+		if LockKeyWithStop(km, key, stopped) {
+			t.Fatalf("Expected LockKeyWithStop not to acquire a free key once stopped.")
+		}
+		if !LockKeyWithStop(km, key, stop) {
+			t.Fatalf("Expected LockKeyWithStop to acquire a free key.")
+		}
+		go func() {
+			resultCh <- LockKeyWithStop(km, key, stop)
+		}()
+		close(stop)
+		select {
+		case acquired := <-resultCh:
+			if acquired {
+				t.Fatalf("Expected LockKeyWithStop to give up on a held key once stopped.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for LockKeyWithStop to return.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockWithTimeout(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+
+		// Act & Assert
+		if !LockKeyWithTimeout(km, key, 0) {
+			t.Fatalf("Expected LockKeyWithTimeout to acquire a free key.")
+		}
+		if LockKeyWithTimeout(km, key, 0) {
+			t.Fatalf("Expected LockKeyWithTimeout(0) to fail on a held key.")
+		}
+		start := time.Now()
+		if LockKeyWithTimeout(km, key, 10*time.Millisecond) {
+			t.Fatalf("Expected LockKeyWithTimeout to fail on a held key.")
+		}
+		if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+			t.Fatalf("Expected LockKeyWithTimeout to wait for the timeout, returned after %v.", elapsed)
+		}
+		km.UnlockKey(key)
+		if !LockKeyWithTimeout(km, key, callbackTimeout) {
+			t.Fatalf("Expected LockKeyWithTimeout to acquire a released key.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockKeyWithContention(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		callbackCh := make(chan interface{}, 1)
+
+		// Act & Assert
+		if LockKeyWithContention(km, key) {
+			t.Fatalf("Expected acquiring a free key to be uncontended.")
+		}
+		go func() {
+			callbackCh <- LockKeyWithContention(km, key)
+		}()
+		verifyCallbackDoesntHappens(t, callbackCh)
+		km.UnlockKey(key)
+		select {
+		case contended := <-callbackCh:
+			if !contended.(bool) {
+				t.Fatalf("Expected acquiring a held key to be contended.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for LockKeyWithContention.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_TryLockKeyWithContext(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act & Assert
+		if acquired, blocked := TryLockKeyWithContext(ctx, km, key); !acquired || blocked {
+			t.Fatalf("Expected a free key to be acquired without blocking, got acquired=%v blocked=%v.", acquired, blocked)
+		}
+		if acquired, blocked := TryLockKeyWithContext(ctx, km, key); acquired || !blocked {
+			t.Fatalf("Expected a held key to block until the deadline, got acquired=%v blocked=%v.", acquired, blocked)
+		}
+		callbackCh := make(chan interface{}, 1)
+		go func() {
+			acquired, blocked := TryLockKeyWithContext(context.Background(), km, key)
+			callbackCh <- acquired && blocked
+		}()
+		verifyCallbackDoesntHappens(t, callbackCh)
+		km.UnlockKey(key)
+		select {
+		case ok := <-callbackCh:
+			if !ok.(bool) {
+				t.Fatalf("Expected a released key to be acquired after blocking.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for TryLockKeyWithContext.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockKeyWithHeadroom(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		budget := callbackTimeout
+		held := 10 * time.Millisecond
+		ctx, cancel := context.WithTimeout(context.Background(), budget)
+		km.LockKey(key)
+		go func() {
+			time.Sleep(held)
+			km.UnlockKey(key)
+		}()
+
+		// Act
+		acquired, remaining := LockKeyWithHeadroom(ctx, km, key)
+		cancel()
+
+		// Assert
+		if !acquired {
+			t.Fatalf("Expected LockKeyWithHeadroom to acquire the released key.")
+		}
+		if remaining <= 0 || remaining > budget-held {
+			t.Fatalf("Expected the remaining time to be positive and at most %v, got %v.", budget-held, remaining)
+		}
+		km.UnlockKey(key)
+		if acquired, remaining := LockKeyWithHeadroom(context.Background(), km, key); !acquired || remaining != NoDeadline {
+			t.Fatalf("Expected NoDeadline for a context without a deadline, got %v, %v.", acquired, remaining)
+		}
+		km.UnlockKey(key)
+		if acquired, remaining := LockKeyWithHeadroom(expiredContext(), km, key); acquired || remaining != 0 {
+			t.Fatalf("Expected a done context not to acquire the key, got %v, %v.", acquired, remaining)
+		}
+	}
+}
+
+func Test_LockKeyOrElse(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		busyCalls := 0
+		busy := func() { busyCalls++ }
+
+		// Act & Assert
+		if !LockKeyOrElse(km, key, busy) {
+			t.Fatalf("Expected LockKeyOrElse to acquire a free key.")
+		}
+		if busyCalls != 0 {
+			t.Fatalf("Expected busy not to be called when the lock is acquired.")
+		}
+		if LockKeyOrElse(km, key, busy) {
+			t.Fatalf("Expected LockKeyOrElse not to acquire a held key.")
+		}
+		if busyCalls != 1 {
+			t.Fatalf("Expected busy to be called once for a held key, got %d.", busyCalls)
+		}
+		km.UnlockKey(key)
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected the failed LockKeyOrElse not to leave the key held.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockKeyWithContext_NoGoroutineLeak(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		before := runtime.NumGoroutine()
+
+		// Act
+		for i := 0; i < 1000; i++ {
+			ctx, cancel := context.WithCancel(context.Background())
+			if !LockKeyWithContext(ctx, km, key) {
+				t.Fatalf("Expected LockKeyWithContext to acquire a free key.")
+			}
+			cancel()
+			km.UnlockKey(key)
+		}
+		// Waits which give up have to stop watching their context as well.
+		km.LockKey(key)
+		for i := 0; i < 100; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
+			if LockKeyWithContext(ctx, km, key) {
+				t.Fatalf("Expected LockKeyWithContext not to acquire a held key.")
+			}
+			cancel()
+		}
+
+		// Assert
+		// Goroutines left waiting for the key would only exit once it is
+		// released, so count them while it is still held.
+		verifyEventually(t, func() bool { return runtime.NumGoroutine() <= before })
+		km.UnlockKey(key)
+		verifyEventually(t, func() bool { return runtime.NumGoroutine() <= before })
+	}
+}
+
+func Test_LockKeyIf(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		var locked bool
+		cond := func(result bool) func() bool {
+			return func() bool {
+				locked = km.(LockInspector).IsLocked(key)
+				return result
+			}
+		}
+
+		// Act & Assert
+		if LockKeyIf(km, key, cond(false)) {
+			t.Fatalf("Expected LockKeyIf to fail when cond is false.")
+		}
+		if !locked {
+			t.Fatalf("Expected cond to be called with the key held.")
+		}
+		if km.(LockInspector).IsLocked(key) {
+			t.Fatalf("Expected LockKeyIf to release the key when cond is false.")
+		}
+		if !LockKeyIf(km, key, cond(true)) {
+			t.Fatalf("Expected LockKeyIf to succeed when cond is true.")
+		}
+		if !km.(LockInspector).IsLocked(key) {
+			t.Fatalf("Expected LockKeyIf to keep the key held when cond is true.")
+		}
+		if err := km.UnlockKey(key); err != nil {
+			t.Fatalf("Expected the caller to hold the key, got %v.", err)
+		}
+	}
+}
+
+func Test_LockKeyIf_Panic(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+
+		// Act
+		r := recoverPanic(func() {
+			LockKeyIf(km, key, func() bool { panic("fake panic") })
+		})
+
+		// Assert
+		if r == nil {
+			t.Fatalf("Expected the panic in cond to be propagated.")
+		}
+		if km.(LockInspector).IsLocked(key) {
+			t.Fatalf("Expected LockKeyIf to release the key when cond panics.")
+		}
+	}
+}
+
+func Test_LockKeyWithBackoff(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		km.LockKey(key)
+		callbackCh := make(chan interface{}, 1)
+
+		// Act
+		go func() {
+			callbackCh <- LockKeyWithBackoff(context.Background(), km, key, time.Millisecond, 10*time.Millisecond)
+		}()
+
+		// Assert
+		verifyCallbackDoesntHappens(t, callbackCh)
+		km.UnlockKey(key)
+		select {
+		case ok := <-callbackCh:
+			if !ok.(bool) {
+				t.Fatalf("Expected LockKeyWithBackoff to acquire the released key.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for LockKeyWithBackoff.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockKeyWithBackoff_Cancelled(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		km.LockKey(key)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		start := time.Now()
+		acquired := LockKeyWithBackoff(ctx, km, key, time.Millisecond, time.Hour)
+
+		// Assert
+		if acquired {
+			t.Fatalf("Expected LockKeyWithBackoff not to acquire a held key.")
+		}
+		if elapsed := time.Since(start); elapsed > callbackTimeout {
+			t.Fatalf("Expected LockKeyWithBackoff to give up once ctx was done, took %v.", elapsed)
+		}
+		km.UnlockKey(key)
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected the failed LockKeyWithBackoff not to leave the key held.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_TryLockKeys(t *testing.T) {
+	for _, km := range []KeyMutex{NewHashed(64), NewPerKey(), NewFairHashed(64)} {
+		// Arrange
+		// These keys hash to distinct locks of 64.
+		km.LockKey("b")
+		km.LockKey("y")
+
+		// Act
+		acquired := TryLockKeys(km, "a", "b", "x", "y")
+
+		// Assert
+		if expected := []string{"a", "x"}; !reflect.DeepEqual(acquired, expected) {
+			t.Fatalf("Expected the free keys %v to be acquired, got %v.", expected, acquired)
+		}
+		for _, key := range []string{"b", "y"} {
+			if err := km.UnlockKey(key); err != nil {
+				t.Fatalf("Unexpected error unlocking pre-held key %q: %v", key, err)
+			}
+		}
+		if err := UnlockAll(km, acquired...); err != nil {
+			t.Fatalf("Unexpected error unlocking the acquired keys: %v", err)
+		}
+		if locked := TryLockKeys(km, "a", "b", "x", "y"); len(locked) != 4 {
+			t.Fatalf("Expected every key to be free again, acquired %v.", locked)
+		}
+		UnlockAll(km, "a", "b", "x", "y")
+	}
+}
+
+func Test_TryLockAll(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		keys := []string{"a", "b", "c", "d"}
+		km.LockKey("c")
+
+		// Act
+		acquired := TryLockAll(km, keys...)
+
+		// Assert
+		if acquired {
+			t.Fatalf("Expected TryLockAll to fail while %q is held.", "c")
+		}
+		km.UnlockKey("c")
+		for _, key := range keys {
+			if !TryLockKey(km, key) {
+				t.Fatalf("Expected the failed TryLockAll to leave %q free.", key)
+			}
+			km.UnlockKey(key)
+		}
+		if !TryLockAll(km, append(keys, "a")...) {
+			t.Fatalf("Expected TryLockAll to acquire free keys, even those sharing a lock.")
+		}
+		for _, key := range keys {
+			if TryLockKey(km, key) {
+				t.Fatalf("Expected TryLockAll to hold %q.", key)
+			}
+		}
+		if err := UnlockKeys(km, keys...); err != nil {
+			t.Fatalf("Unexpected error from UnlockKeys: %v", err)
+		}
+		if !TryLockAll(km, keys...) {
+			t.Fatalf("Expected every key to be free again.")
+		}
+		UnlockKeys(km, keys...)
+	}
+}
+
+func Test_LockKeysWithContext(t *testing.T) {
+	// Each key gets a lock of its own, locked in the order of the keys.
+	indexes := map[string]uint32{"a": 0, "b": 1, "c": 2}
+	hashed := NewHashedWithHasher(3, func(id string) uint32 { return indexes[id] })
+	for _, km := range []KeyMutex{hashed, NewPerKey()} {
+		// Arrange
+		keys := []string{"c", "a", "b"}
+		km.LockKey("c")
+		ctx, cancel := context.WithCancel(context.Background())
+		resultCh := make(chan interface{})
+
+		// Act
+		go func() {
+			resultCh <- LockKeysWithContext(ctx, km, keys...)
+		}()
+
+		// Assert
+		verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount("c") > 0 })
+		for _, key := range []string{"a", "b"} {
+			if !km.(LockInspector).IsLocked(key) {
+				t.Fatalf("Expected %q to be held while waiting for %q.", key, "c")
+			}
+		}
+		cancel()
+		select {
+		case acquired := <-resultCh:
+			if acquired.(bool) {
+				t.Fatalf("Expected LockKeysWithContext to give up once ctx was cancelled.")
+			}
+		case <-time.After(callbackTimeout):
+			t.Fatalf("Timed out waiting for LockKeysWithContext to give up.")
+		}
+		for _, key := range []string{"a", "b"} {
+			if !TryLockKey(km, key) {
+				t.Fatalf("Expected the cancelled LockKeysWithContext to release %q.", key)
+			}
+			km.UnlockKey(key)
+		}
+		km.UnlockKey("c")
+		if !LockKeysWithContext(context.Background(), km, append(keys, "a")...) {
+			t.Fatalf("Expected LockKeysWithContext to acquire free keys.")
+		}
+		UnlockKeys(km, keys...)
+		if LockKeysWithContext(expiredContext(), km, keys...) {
+			t.Fatalf("Expected LockKeysWithContext with a done context to acquire nothing.")
+		}
+		if km.(LockInspector).IsLocked("a") {
+			t.Fatalf("Expected LockKeysWithContext with a done context to leave %q free.", "a")
+		}
+	}
+}
+
+func Test_UnlockAll(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		km.LockKey("a")
+
+		// Act
+		var err error
+		recovered := recoverPanic(func() {
+			err = UnlockAll(km, "unlocked", "a")
+		})
+
+		// Assert
+		if recovered == nil && err == nil {
+			t.Fatalf("Expected UnlockAll to report the unlocked key.")
+		}
+		if recovered != nil && !strings.Contains(fmt.Sprint(recovered), `"unlocked"`) {
+			t.Fatalf("Expected the panic to name the unlocked key, got %v.", recovered)
+		}
+		if !TryLockKey(km, "a") {
+			t.Fatalf("Expected UnlockAll to release the keys after a failed one.")
+		}
+		km.UnlockKey("a")
+	}
+}
+
+func Test_UnlockAll_AggregatesPanics(t *testing.T) {
+	// Arrange
+	km := NewHashed(64)
+	km.LockKey("a")
+	km.LockKey("b")
+
+	// Act
+	recovered := recoverPanic(func() {
+		UnlockAll(km, "a", "x", "b", "y")
+	})
+
+	// Assert
+	message := fmt.Sprint(recovered)
+	if !strings.Contains(message, "2 keys") || !strings.Contains(message, `"x"`) || !strings.Contains(message, `"y"`) {
+		t.Fatalf("Expected the panic to name both unlocked keys, got %v.", recovered)
+	}
+	for _, key := range []string{"a", "b"} {
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected UnlockAll to release %q.", key)
+		}
+	}
+}
+
+func Test_IsLocked(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		inspector := km.(LockInspector)
+
+		// Act & Assert
+		if inspector.IsLocked(key) {
+			t.Fatalf("Expected %q to be reported free.", key)
+		}
+		km.LockKey(key)
+		if !inspector.IsLocked(key) {
+			t.Fatalf("Expected %q to be reported locked.", key)
+		}
+		km.UnlockKey(key)
+		if inspector.IsLocked(key) {
+			t.Fatalf("Expected %q to be reported free after unlock.", key)
+		}
+	}
+}
+
+func Test_WaitersCount(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		inspector := km.(LockInspector)
+		callbackCh := make(chan interface{})
+		km.LockKey(key)
+
+		// Act & Assert
+		if n := inspector.WaitersCount(key); n != 0 {
+			t.Fatalf("Expected no waiters, got %d.", n)
+		}
+		go lockAndCallback(km, key, callbackCh)
+		go lockAndCallback(km, key, callbackCh)
+		verifyEventually(t, func() bool { return inspector.WaitersCount(key) == 2 })
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackCh)
+		verifyEventually(t, func() bool { return inspector.WaitersCount(key) == 1 })
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackCh)
+		km.UnlockKey(key)
+		if n := inspector.WaitersCount(key); n != 0 {
+			t.Fatalf("Expected no waiters after all unlocks, got %d.", n)
+		}
+	}
+}
+
+func Test_LockKeys_Duplicates(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		keys := []string{"b", "a", "b", "c", "a"}
+		callbackCh := make(chan interface{})
+
+		// Act & Assert
+		go func() {
+			LockKeys(km, keys...)
+			callbackCh <- true
+		}()
+		verifyCallbackHappens(t, callbackCh)
+		for _, key := range keys {
+			if TryLockKey(km, key) {
+				t.Fatalf("Expected %q to be held after LockKeys.", key)
+			}
+		}
+		if err := UnlockKeys(km, keys...); err != nil {
+			t.Fatalf("Unexpected error from UnlockKeys: %v", err)
+		}
+		for _, key := range keys {
+			if !TryLockKey(km, key) {
+				t.Fatalf("Expected %q to be free after UnlockKeys.", key)
+			}
+			km.UnlockKey(key)
+		}
+	}
+}
+
+func Test_LockKeys_NoDeadlock(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		const goroutines, iterations = 16, 100
+		keys := []string{"a", "b", "c", "d", "e", "f"}
+		doneCh := make(chan interface{})
+		var wg sync.WaitGroup
+
+		// Act
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				r := rand.New(rand.NewSource(seed))
+				for i := 0; i < iterations; i++ {
+					subset := make([]string, 1+r.Intn(3))
+					for j := range subset {
+						subset[j] = keys[r.Intn(len(keys))]
+					}
+					LockKeys(km, subset...)
+					UnlockKeys(km, subset...)
+				}
+			}(int64(g))
+		}
+		go func() {
+			wg.Wait()
+			doneCh <- true
+		}()
+
+		// Assert
+		select {
+		case <-doneCh:
+		case <-time.After(10 * callbackTimeout):
+			t.Fatalf("Timed out waiting for LockKeys, likely deadlocked.")
+		}
+	}
+}
+
 func lockAndCallback(km KeyMutex, id string, callbackCh chan<- interface{}) {
 	km.LockKey(id)
 	callbackCh <- true
@@ -103,3 +907,144 @@ func verifyCallbackDoesntHappens(t *testing.T, callbackCh <-chan interface{}) bo
 		return true
 	}
 }
+
+func verifyEventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(callbackTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for condition.")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func recoverPanic(f func()) (recovered interface{}) {
+	defer func() {
+		recovered = recover()
+	}()
+	f()
+	return nil
+}
+
+func Test_OptionalInterfaces(t *testing.T) {
+	for _, km := range append(newKeyMutexes(), NewNoop()) {
+		if _, ok := km.(interface {
+			TryLocker
+			ContextLocker
+			TimeoutLocker
+		}); !ok {
+			t.Errorf("Expected %T to implement TryLocker, ContextLocker and TimeoutLocker.", km)
+		}
+	}
+
+	hashed := []KeyMutex{
+		NewHashed(2),
+		NewHashedWithOptions(2),
+		NewFairHashed(2),
+		NewHashedPadded(2),
+		NewHashedPow2(2),
+		NewHashedWithStrategy(2, SpinStrategy),
+		NewHashedWithNormalizer(2, strings.ToLower),
+		NewWatchdogHashed(2, time.Minute, func(string, time.Duration) {}),
+		NewReentrantHashed(2),
+		NewHashedReentrantSafe(2),
+	}
+	for _, km := range hashed {
+		if _, ok := km.(Introspectable); !ok {
+			t.Errorf("Expected %T to implement Introspectable.", km)
+		}
+		if _, ok := km.(interface {
+			ByteKeyMutex
+			HeldKeysReporter
+			WaitLatencyReporter
+			WaiterDumper
+			WaiterCanceller
+			PriorityLocker
+			Closer
+			Resetter
+			IdleWaiter
+			Resizer
+			Snapshotter
+			KeyTransferrer
+			HolderLabeler
+			BatchTryLocker
+			ExpvarPublisher
+			FreeWaiter
+			ContextBatchLocker
+			TokenLocker
+			BatchLocker
+			ContextErrLocker
+			StopLocker
+		}); !ok {
+			t.Errorf("Expected %T to implement every optional interface of NewHashed.", km)
+		}
+	}
+
+	perKey := NewPerKey()
+	if _, ok := perKey.(interface {
+		LockInspector
+		WaiterCanceller
+		Resetter
+		Warmer
+		BatchLocker
+		ContextErrLocker
+		StopLocker
+	}); !ok {
+		t.Errorf("Expected NewPerKey to implement LockInspector, WaiterCanceller, Resetter, Warmer, BatchLocker, ContextErrLocker and StopLocker.")
+	}
+	for _, km := range []KeyMutex{NewNoop(), NewOptimisticHashed(2)} {
+		if _, ok := km.(interface {
+			BatchLocker
+			ContextErrLocker
+			StopLocker
+		}); !ok {
+			t.Errorf("Expected %T to implement BatchLocker, ContextErrLocker and StopLocker.", km)
+		}
+	}
+	if _, ok := perKey.(ShardCounter); ok {
+		t.Errorf("Expected NewPerKey not to implement ShardCounter, since it has no fixed set of locks.")
+	}
+	if _, ok := NewNoop().(LockInspector); ok {
+		t.Errorf("Expected NewNoop not to implement LockInspector.")
+	}
+	if _, ok := NewHashedOf[int](2).(ShardCounter); !ok {
+		t.Errorf("Expected NewHashedOf to implement ShardCounter.")
+	}
+	if _, ok := NewRWHashed(2).(KeyUpgrader); !ok {
+		t.Errorf("Expected NewRWHashed to implement KeyUpgrader.")
+	}
+	if _, ok := NewRWHashed(2).(RWContextLocker); !ok {
+		t.Errorf("Expected NewRWHashed to implement RWContextLocker.")
+	}
+}
+
+// lockOnlyKeyMutex implements KeyMutex and none of the optional interfaces.
+type lockOnlyKeyMutex struct {
+	KeyMutex
+}
+
+func Test_OptionalInterfaces_Fallbacks(t *testing.T) {
+	// Arrange
+	km := lockOnlyKeyMutex{NewHashed(1)}
+	key := "fakeid"
+
+	// Act
+	tryPanic := recoverPanic(func() { TryLockKey(km, key) })
+	contextPanic := recoverPanic(func() { LockKeyWithContext(context.Background(), km, key) })
+	LockKeys(km, key, key)
+	unlockErr := UnlockKeys(km, key, key)
+
+	// Assert
+	expected := "keymutex: keymutex.lockOnlyKeyMutex doesn't implement TryLocker"
+	if tryPanic != expected {
+		t.Errorf("Expected TryLockKey to panic with %q, got %v.", expected, tryPanic)
+	}
+	expected = "keymutex: keymutex.lockOnlyKeyMutex doesn't implement ContextLocker"
+	if contextPanic != expected {
+		t.Errorf("Expected LockKeyWithContext to panic with %q, got %v.", expected, contextPanic)
+	}
+	if unlockErr != nil {
+		t.Errorf("Expected UnlockKeys to succeed, got %v.", unlockErr)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+// HolderLabeler is implemented by KeyMutex instances whose holders can tag
+// themselves with a label, such as a request ID or handler name, so that
+// debugging can tell who holds a key rather than just that it is held. Such
+// KeyMutex instances include those returned by NewHashed.
+type HolderLabeler interface {
+	// Acquires the lock associated with the specified ID, as LockKey does,
+	// and records label as its holder until it is unlocked.
+	LockKeyTagged(id, label string)
+
+	// Returns the label recorded by the holder of the lock associated with
+	// the specified ID, or false if the ID isn't held, or was locked without
+	// a label. This is best-effort: the lock may be released or acquired
+	// again by the time the caller looks at the result.
+	HolderOf(id string) (label string, ok bool)
+}
+
+// Acquires the lock associated with the specified ID and records label as
+// its holder.
+func (km *hashedKeyMutex) LockKeyTagged(id, label string) {
+	km.LockKey(id)
+	id = km.normalized(id)
+	_, s := km.heldShard(id)
+	if s == nil {
+		// A re-entrant lock may be held on behalf of another ID hashing to
+		// it.
+		_, s = km.ownedShard(id)
+	}
+	s.setLabel(id, label)
+}
+
+// Returns the label recorded by the holder of the lock associated with the
+// specified ID.
+func (km *hashedKeyMutex) HolderOf(id string) (string, bool) {
+	id = km.normalized(id)
+	var buf [4]*generation
+	for _, g := range km.generations(buf[:0]) {
+		if label, ok := km.shardOf(g, id).labelOf(id); ok {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// setLabel records label as the holder of s, which the caller holds on
+// behalf of id.
+func (s *shard) setLabel(id, label string) {
+	s.labelled = true
+	s.meta.Lock()
+	defer s.meta.Unlock()
+	s.labelKey = append(s.labelKey[:0], id...)
+	s.label = label
+}
+
+// clearLabel forgets the label of the holder of s, which the caller holds.
+func (s *shard) clearLabel() {
+	s.labelled = false
+	s.meta.Lock()
+	defer s.meta.Unlock()
+	s.labelKey = s.labelKey[:0]
+	s.label = ""
+}
+
+// labelOf returns the label of the holder of s if it holds s on behalf of
+// id.
+func (s *shard) labelOf(id string) (string, bool) {
+	s.meta.Lock()
+	defer s.meta.Unlock()
+	if s.label == "" || string(s.labelKey) != id {
+		return "", false
+	}
+	return s.label, true
+}
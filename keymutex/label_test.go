@@ -0,0 +1,81 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"testing"
+)
+
+func Test_HolderOf(t *testing.T) {
+	for _, km := range []KeyMutex{NewHashed(1), NewHashed(64), NewReentrantHashed(64)} {
+		// Arrange
+		labeler := km.(HolderLabeler)
+		key := "order-42"
+
+		// Act
+		labeler.LockKeyTagged(key, "request abc123")
+		label, ok := labeler.HolderOf(key)
+		_, otherOK := labeler.HolderOf("order-43")
+		km.UnlockKey(key)
+
+		// Assert
+		if !ok || label != "request abc123" {
+			t.Fatalf("Expected %q to be held by %q, got %q, %v.", key, "request abc123", label, ok)
+		}
+		if otherOK {
+			t.Fatalf("Expected another key sharing the lock not to report the label.")
+		}
+		if label, ok := labeler.HolderOf(key); ok {
+			t.Fatalf("Expected no holder after release, got %q.", label)
+		}
+	}
+}
+
+func Test_HolderOf_Untagged(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	labeler := km.(HolderLabeler)
+	key := "fakeid"
+	labeler.LockKeyTagged(key, "first")
+	km.UnlockKey(key)
+
+	// Act
+	km.LockKey(key)
+	label, ok := labeler.HolderOf(key)
+	km.UnlockKey(key)
+
+	// Assert
+	if ok {
+		t.Fatalf("Expected a key locked without a label to have no holder label, got %q.", label)
+	}
+}
+
+func Test_HolderOf_HeldKeys(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(4, WithHeldKeyTracking())
+	key := "fakeid"
+
+	// Act
+	km.(HolderLabeler).LockKeyTagged(key, "handler")
+	held := km.(HeldKeysReporter).HeldKeys()
+	km.UnlockKey(key)
+
+	// Assert
+	if len(held) != 1 || held[0].Label != "handler" {
+		t.Fatalf("Expected HeldKeys to report the holder's label, got %v.", held)
+	}
+}
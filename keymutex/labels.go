@@ -0,0 +1,55 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// ProfileLabelKey is the profiler label under which WithProfileLabels labels
+// goroutines waiting for a lock.
+const ProfileLabelKey = "keymutex"
+
+// WithProfileLabels labels goroutines blocked waiting for a lock in
+// LockKeyWithContext, LockKeyWithContextErr or LockKeyWithPriority with the
+// profiler label ProfileLabelKey, whose value is label applied to the key,
+// so that goroutine and CPU profiles show which kind of key they are waiting
+// for. label should map keys to few values, for example by their prefix.
+// Go's mutex and block profiles don't record labels.
+// As with pprof.Do, the label is added to the labels of the context passed
+// in, and the goroutine's labels are set back to those of the context once
+// it stops waiting, so only waits with a context are labelled. By default
+// nothing is labelled, at no cost to locking.
+func WithProfileLabels(label func(key string) string) Option {
+	return func(km *hashedKeyMutex) {
+		km.profileLabel = label
+	}
+}
+
+// waitContended waits for s, which was just found to be held, on behalf of
+// id, labelling the wait for profiles on top of labels if it is set.
+func (km *hashedKeyMutex) waitContended(labels context.Context, s *shard, id string, prio int, done, abort <-chan struct{}) bool {
+	if labels == nil || km.profileLabel == nil {
+		return s.lockContended(prio, done, abort)
+	}
+	var acquired bool
+	pprof.Do(labels, pprof.Labels(ProfileLabelKey, km.profileLabel(id)), func(context.Context) {
+		acquired = s.lockContended(prio, done, abort)
+	})
+	return acquired
+}
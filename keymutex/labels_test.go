@@ -0,0 +1,97 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+// goroutineProfile returns the goroutine profile, which lists the labels of
+// each goroutine.
+func goroutineProfile(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatalf("Unexpected error writing the goroutine profile: %v", err)
+	}
+	return buf.String()
+}
+
+func Test_ProfileLabels(t *testing.T) {
+	// Arrange
+	prefix := func(key string) string { return strings.SplitN(key, "-", 2)[0] }
+	km := NewHashedWithOptions(4, WithProfileLabels(prefix))
+	key := "order-1"
+	acquiredCh := make(chan interface{})
+	releaseCh := make(chan struct{})
+	km.LockKey(key)
+
+	// Act
+	go pprof.Do(context.Background(), pprof.Labels("request", "fake"), func(ctx context.Context) {
+		LockKeyWithContext(ctx, km, key)
+		acquiredCh <- true
+		<-releaseCh
+		km.UnlockKey(key)
+	})
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+
+	// Assert
+	profile := goroutineProfile(t)
+	if !strings.Contains(profile, `"keymutex":"order"`) {
+		t.Errorf("Expected the waiting goroutine to be labelled with the key prefix, got profile:\n%s", profile)
+	}
+	if !strings.Contains(profile, `"request":"fake"`) {
+		t.Errorf("Expected the waiting goroutine to keep the labels of its context, got profile:\n%s", profile)
+	}
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, acquiredCh)
+	profile = goroutineProfile(t)
+	if strings.Contains(profile, `"keymutex":`) {
+		t.Errorf("Expected the label to be removed once the wait is over, got profile:\n%s", profile)
+	}
+	if !strings.Contains(profile, `"request":"fake"`) {
+		t.Errorf("Expected the labels of the context to be restored, got profile:\n%s", profile)
+	}
+	close(releaseCh)
+}
+
+func Test_ProfileLabels_Disabled(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+	key := "order-1"
+	doneCh := make(chan interface{})
+	km.LockKey(key)
+
+	// Act
+	go func() {
+		LockKeyWithContext(context.Background(), km, key)
+		doneCh <- true
+	}()
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+
+	// Assert
+	if profile := goroutineProfile(t); strings.Contains(profile, `"keymutex":`) {
+		t.Errorf("Expected no goroutine to be labelled by default, got profile:\n%s", profile)
+	}
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, doneCh)
+	km.UnlockKey(key)
+}
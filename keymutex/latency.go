@@ -0,0 +1,164 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets is the number of buckets of a wait latency histogram. The
+// bucket at index i counts waits of up to 2^i microseconds, and the last one
+// counts all longer waits.
+const latencyBuckets = 28
+
+// LatencyBucket is one bucket of a wait latency histogram.
+type LatencyBucket struct {
+	// UpperBound is the longest wait counted in the bucket. The waits it
+	// counts are longer than the UpperBound of the previous bucket. The last
+	// bucket has no bound and counts all waits which are longer still.
+	UpperBound time.Duration
+	// Count is the number of waits counted in the bucket.
+	Count uint64
+}
+
+// WaitLatencyReporter is implemented by KeyMutex instances which can record
+// how long acquisitions wait, such as those returned by NewHashed, for
+// services which want latency percentiles without a metrics library.
+type WaitLatencyReporter interface {
+	// Returns the number of acquisitions by each length of wait since the
+	// KeyMutex was created or reset, with buckets in increasing order of
+	// UpperBound, or nil if the KeyMutex was not created with
+	// WithWaitLatencyHistogram. Only methods which may block are counted.
+	// Buckets are read one at a time while acquisitions may still be
+	// counted, so their total may lag slightly behind.
+	WaitLatencySnapshot() []LatencyBucket
+}
+
+// WithWaitLatencyHistogram records how long each blocking acquisition waits,
+// for WaitLatencySnapshot to report. Recording reads the clock twice and
+// increments one counter per acquisition. By default nothing is recorded.
+func WithWaitLatencyHistogram() Option {
+	return func(km *hashedKeyMutex) {
+		km.latency = &latencyHistogram{}
+	}
+}
+
+// LatencyQuantile returns the UpperBound of the bucket which holds the
+// quantile q, between 0 and 1, of the waits counted in buckets, e.g. 0.99
+// for the 99th percentile, or 0 if no waits were counted. The true quantile
+// is at most the returned bound and above the bound of the previous bucket.
+func LatencyQuantile(buckets []LatencyBucket, q float64) time.Duration {
+	var total uint64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for _, b := range buckets {
+		seen += b.Count
+		if seen >= rank {
+			return b.UpperBound
+		}
+	}
+	return buckets[len(buckets)-1].UpperBound
+}
+
+// latencyHistogram counts waits by their length.
+type latencyHistogram struct {
+	counts [latencyBuckets]uint64
+}
+
+// observe counts a wait of d.
+func (h *latencyHistogram) observe(d time.Duration) {
+	atomic.AddUint64(&h.counts[latencyBucket(d)], 1)
+}
+
+// snapshot returns the current counts.
+func (h *latencyHistogram) snapshot() []LatencyBucket {
+	buckets := make([]LatencyBucket, latencyBuckets)
+	for i := range buckets {
+		buckets[i] = LatencyBucket{
+			UpperBound: latencyBound(i),
+			Count:      atomic.LoadUint64(&h.counts[i]),
+		}
+	}
+	return buckets
+}
+
+// reset clears the counts.
+func (h *latencyHistogram) reset() {
+	for i := range h.counts {
+		atomic.StoreUint64(&h.counts[i], 0)
+	}
+}
+
+// latencyBucket returns the index of the bucket counting a wait of d.
+func latencyBucket(d time.Duration) int {
+	if d <= time.Microsecond {
+		return 0
+	}
+	i := bits.Len64(uint64((d - 1) / time.Microsecond))
+	if i >= latencyBuckets {
+		return latencyBuckets - 1
+	}
+	return i
+}
+
+// latencyBound returns the UpperBound of the bucket at index i.
+func latencyBound(i int) time.Duration {
+	if i == latencyBuckets-1 {
+		return math.MaxInt64
+	}
+	return time.Microsecond << uint(i)
+}
+
+// Returns how long acquisitions have waited, if the KeyMutex was created
+// with WithWaitLatencyHistogram, or nil otherwise.
+func (km *hashedKeyMutex) WaitLatencySnapshot() []LatencyBucket {
+	if km.latency == nil {
+		return nil
+	}
+	return km.latency.snapshot()
+}
+
+// timesWaits reports whether acquisitions need to measure how long they
+// wait.
+func (km *hashedKeyMutex) timesWaits() bool {
+	return km.observer != nil || km.classObserver != nil || km.latency != nil
+}
+
+// observeWait records that an acquisition of s on behalf of id waited for d.
+func (km *hashedKeyMutex) observeWait(s *shard, id string, d time.Duration) {
+	if km.observer != nil {
+		km.observer.ObserveWaitDuration(s.index, d)
+	}
+	if km.classObserver != nil {
+		km.classObserver.ObserveWaitDuration(km.classify(id), d)
+	}
+	if km.latency != nil {
+		km.latency.observe(d)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func Test_WaitLatencySnapshot(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(4, WithWaitLatencyHistogram())
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+	const wait = 20 * time.Millisecond
+
+	// Act
+	km.LockKey(key)
+	go lockAndCallback(km, key, callbackCh)
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+	time.Sleep(wait)
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+	if TryLockKey(km, key) {
+		km.UnlockKey(key)
+	}
+	buckets := km.(WaitLatencyReporter).WaitLatencySnapshot()
+
+	// Assert
+	if len(buckets) != latencyBuckets {
+		t.Fatalf("Expected %d buckets, got %d.", latencyBuckets, len(buckets))
+	}
+	var total uint64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if total != 2 {
+		t.Errorf("Expected 2 waits to be counted, got %d.", total)
+	}
+	if p50 := LatencyQuantile(buckets, 0.5); p50 >= wait {
+		t.Errorf("Expected the uncontended wait to be counted below %v, got %v.", wait, p50)
+	}
+	if p100 := LatencyQuantile(buckets, 1); p100 < wait {
+		t.Errorf("Expected the contended wait to be counted at or above %v, got %v.", wait, p100)
+	}
+}
+
+func Test_WaitLatencySnapshot_Disabled(t *testing.T) {
+	// Arrange
+	km := NewHashed(4)
+
+	// Act
+	km.LockKey("fakeid")
+	km.UnlockKey("fakeid")
+
+	// Assert
+	if buckets := km.(WaitLatencyReporter).WaitLatencySnapshot(); buckets != nil {
+		t.Errorf("Expected no histogram, got %v.", buckets)
+	}
+}
+
+func Test_WaitLatencySnapshot_Reset(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(4, WithWaitLatencyHistogram())
+	km.LockKey("fakeid")
+	km.UnlockKey("fakeid")
+
+	// Act
+	km.(Resetter).Reset()
+
+	// Assert
+	for _, b := range km.(WaitLatencyReporter).WaitLatencySnapshot() {
+		if b.Count != 0 {
+			t.Errorf("Expected reset to clear the histogram, got %d waits up to %v.", b.Count, b.UpperBound)
+		}
+	}
+}
+
+func Test_latencyBucket(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want time.Duration
+	}{
+		{0, time.Microsecond},
+		{time.Microsecond, time.Microsecond},
+		{time.Microsecond + 1, 2 * time.Microsecond},
+		{3 * time.Microsecond, 4 * time.Microsecond},
+		{4 * time.Microsecond, 4 * time.Microsecond},
+		{20 * time.Millisecond, 32768 * time.Microsecond},
+		{time.Hour, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		if got := latencyBound(latencyBucket(tt.d)); got != tt.want {
+			t.Errorf("Expected a wait of %v to be counted up to %v, got %v.", tt.d, tt.want, got)
+		}
+	}
+}
+
+func Test_LatencyQuantile(t *testing.T) {
+	// Arrange
+	buckets := []LatencyBucket{
+		{UpperBound: time.Millisecond, Count: 90},
+		{UpperBound: 2 * time.Millisecond, Count: 9},
+		{UpperBound: 4 * time.Millisecond, Count: 1},
+	}
+
+	// Act & Assert
+	for q, want := range map[float64]time.Duration{
+		0:    time.Millisecond,
+		0.9:  time.Millisecond,
+		0.95: 2 * time.Millisecond,
+		0.99: 2 * time.Millisecond,
+		1:    4 * time.Millisecond,
+	} {
+		if got := LatencyQuantile(buckets, q); got != want {
+			t.Errorf("Expected quantile %v to be %v, got %v.", q, want, got)
+		}
+	}
+	if got := LatencyQuantile(nil, 0.5); got != 0 {
+		t.Errorf("Expected no quantile without waits, got %v.", got)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// LeasedKeyMutex is a thread-safe interface for acquiring locks on arbitrary
+// strings which are forcibly released once held for longer than a budget.
+// Unlike ExpiringKeyMutex, each acquisition returns a Lease, through which
+// the holder can find out that its lock has been reclaimed.
+type LeasedKeyMutex interface {
+	// Acquires a lock associated with the specified ID, returning the lease
+	// which must be passed to UnlockKey.
+	LockKey(id string) *Lease
+
+	// Acquires a lock associated with the specified ID, giving up once ctx is
+	// done. Returns false if ctx was done first, or is already done.
+	LockKeyWithContext(ctx context.Context, id string) (*Lease, bool)
+
+	// Releases the lock held by lease. If the lock has been reclaimed in the
+	// meantime, nothing is released and ErrLockExpired is returned, so that
+	// a holder which overran its budget can't release a lock which has been
+	// acquired by someone else since.
+	UnlockKey(lease *Lease) error
+}
+
+// Values of Lease.state.
+const (
+	leaseHeld int32 = iota
+	leaseReleased
+	leaseReclaimed
+)
+
+// Lease is a single acquisition of a lock of a LeasedKeyMutex.
+type Lease struct {
+	id    string
+	token Token
+	// state is one of leaseHeld, leaseReleased and leaseReclaimed.
+	state int32
+	// reclaimed is closed once the lock has been forcibly released.
+	reclaimed chan struct{}
+}
+
+// Key returns the key the lease was acquired for.
+func (l *Lease) Key() string {
+	return l.id
+}
+
+// Valid reports whether the lease still holds its lock, that is, it has
+// neither been unlocked nor reclaimed.
+func (l *Lease) Valid() bool {
+	return atomic.LoadInt32(&l.state) == leaseHeld
+}
+
+// Reclaimed returns a channel which is closed once the lock has been
+// forcibly released because it was held for too long. It is never closed if
+// the lease is unlocked in time.
+func (l *Lease) Reclaimed() <-chan struct{} {
+	return l.reclaimed
+}
+
+// reclaim marks the lease as reclaimed, once its lock has been released.
+func (l *Lease) reclaim() {
+	atomic.StoreInt32(&l.state, leaseReclaimed)
+	close(l.reclaimed)
+}
+
+// NewLeasedHashed returns a new instance of LeasedKeyMutex which hashes
+// arbitrary keys to a fixed set of locks, like NewHashed. A lock which is not
+// unlocked within maxHold of being acquired is forcibly released and its
+// lease is invalidated, so a goroutine which gets stuck while holding a lock
+// cannot deadlock the key indefinitely. Unlike with NewWatchdogHashed, such
+// a lock is not only reported: the next waiter acquires it, and the stuck
+// holder must check its lease before relying on the lock any further.
+// `n` specifies number of locks, if n <= 0, a single lock is shared by all
+// keys, as with NewHashed.
+func NewLeasedHashed(n int, maxHold time.Duration) LeasedKeyMutex {
+	return NewLeasedHashedWithClock(n, maxHold, clock.RealClock{})
+}
+
+// NewLeasedHashedWithClock is like NewLeasedHashed, but measures maxHold with
+// clk instead of the real clock, as NewExpiringHashedWithClock does.
+func NewLeasedHashedWithClock(n int, maxHold time.Duration, clk clock.WithDelayedExecution) LeasedKeyMutex {
+	return &leasedKeyMutex{
+		expiring: NewExpiringHashedWithClock(n, maxHold, clk).(*expiringKeyMutex),
+	}
+}
+
+var _ LeasedKeyMutex = (*leasedKeyMutex)(nil)
+
+// leasedKeyMutex hands out the locks of an expiringKeyMutex as leases.
+type leasedKeyMutex struct {
+	expiring *expiringKeyMutex
+}
+
+// Acquires a lock associated with the specified ID.
+func (km *leasedKeyMutex) LockKey(id string) *Lease {
+	s := km.expiring.shard(id)
+	s.mutex.lock()
+	return km.acquired(s, id)
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is done.
+func (km *leasedKeyMutex) LockKeyWithContext(ctx context.Context, id string) (*Lease, bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	s := km.expiring.shard(id)
+	if !s.mutex.lockOrDone(ctx.Done()) {
+		return nil, false
+	}
+	return km.acquired(s, id), true
+}
+
+// Releases the lock held by lease, unless it has been reclaimed.
+func (km *leasedKeyMutex) UnlockKey(lease *Lease) error {
+	if lease == nil {
+		return ErrLockExpired
+	}
+	if err := km.expiring.UnlockKey(lease.id, lease.token); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&lease.state, leaseReleased)
+	return nil
+}
+
+// acquired returns the lease of s, which must have just been locked for id.
+func (km *leasedKeyMutex) acquired(s *expiringShard, id string) *Lease {
+	lease := &Lease{id: id, reclaimed: make(chan struct{})}
+	lease.token = km.expiring.acquired(s, lease.reclaim)
+	return lease
+}
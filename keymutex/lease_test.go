@@ -0,0 +1,95 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_Leased_Unlock(t *testing.T) {
+	// Arrange
+	km := NewLeasedHashed(4, time.Hour)
+	key := "fakeid"
+
+	// Act
+	lease := km.LockKey(key)
+	err := km.UnlockKey(lease)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error from UnlockKey: %v", err)
+	}
+	if lease.Valid() {
+		t.Fatalf("Expected an unlocked lease to be invalid.")
+	}
+	select {
+	case <-lease.Reclaimed():
+		t.Fatalf("Expected a lease unlocked in time not to be reclaimed.")
+	default:
+	}
+	if err := km.UnlockKey(lease); err != ErrLockExpired {
+		t.Fatalf("Expected a second UnlockKey to return ErrLockExpired, got %v.", err)
+	}
+}
+
+func Test_Leased_Reclaims(t *testing.T) {
+	// Arrange
+	clk := newFakeClock()
+	maxHold := time.Minute
+	km := NewLeasedHashedWithClock(1, maxHold, clk)
+	key := "fakeid"
+	stale := km.LockKey(key)
+	callbackCh := make(chan interface{}, 1)
+	go func() {
+		lease, _ := km.LockKeyWithContext(context.Background(), key)
+		callbackCh <- lease
+	}()
+
+	// Act & Assert
+	clk.Step(maxHold - time.Second)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	if !stale.Valid() {
+		t.Fatalf("Expected the lease to be valid within its budget.")
+	}
+	clk.Step(time.Second)
+	var lease *Lease
+	select {
+	case l := <-callbackCh:
+		lease = l.(*Lease)
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Expected the waiter to acquire the reclaimed lock.")
+	}
+	select {
+	case <-stale.Reclaimed():
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Expected the overrunning lease to be reclaimed.")
+	}
+	if stale.Valid() {
+		t.Fatalf("Expected the reclaimed lease to be invalid.")
+	}
+	if err := km.UnlockKey(stale); err != ErrLockExpired {
+		t.Fatalf("Expected the original holder to see ErrLockExpired, got %v.", err)
+	}
+	if !lease.Valid() {
+		t.Fatalf("Expected the original holder's unlock to leave the new lease alone.")
+	}
+	if err := km.UnlockKey(lease); err != nil {
+		t.Fatalf("Unexpected error from the current holder's UnlockKey: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// KeyedLimiter is a thread-safe interface for limiting the rate of events
+// for each of arbitrary strings, like a token bucket per key.
+type KeyedLimiter interface {
+	// Reports whether an event for the specified key may happen now, and if
+	// so, takes a token for it.
+	Allow(key string) bool
+
+	// Blocks until an event for the specified key may happen and takes a
+	// token for it, returning ctx.Err() if ctx is done first, in which case
+	// no token is taken.
+	Wait(ctx context.Context, key string) error
+}
+
+// minLimiterSweep is the number of keys a shard of a KeyedLimiter may track
+// before it first looks for idle ones to drop.
+const minLimiterSweep = 64
+
+// NewKeyedLimiter returns a new instance of KeyedLimiter which gives each key
+// a bucket of up to burst tokens, refilled at r tokens per second, with one
+// token taken for each event. Keys hash to a fixed set of shards, which
+// guard the buckets of their keys. `shards` specifies number of shards, if
+// shards <= 0, a single shard is used, as with NewHashed. If burst <= 0, a
+// burst of 1 is used.
+// If r <= 0, tokens are never refilled, so each key allows burst events.
+// Buckets are created when a key is first used, and dropped once they are
+// refilled completely, since a full bucket is the same as a new one, so
+// memory use is bounded by the number of keys recently used.
+func NewKeyedLimiter(shards int, r float64, burst int) KeyedLimiter {
+	return newKeyedLimiter(shards, r, burst, clock.RealClock{})
+}
+
+// newKeyedLimiter is NewKeyedLimiter with the time taken from clk.
+func newKeyedLimiter(shards int, r float64, burst int, clk clock.Clock) *keyedLimiter {
+	if shards <= 0 {
+		shards = 1
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	l := &keyedLimiter{
+		shards: make([]limiterShard, shards),
+		rate:   r,
+		burst:  float64(burst),
+		clock:  clk,
+	}
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]*tokenBucket)
+		l.shards[i].sweepAt = minLimiterSweep
+	}
+	return l
+}
+
+var _ KeyedLimiter = (*keyedLimiter)(nil)
+
+type keyedLimiter struct {
+	shards []limiterShard
+	rate   float64
+	burst  float64
+	clock  clock.Clock
+}
+
+type limiterShard struct {
+	// lock guards the fields below.
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	// sweepAt is the number of buckets at which full ones are dropped next.
+	sweepAt int
+}
+
+// tokenBucket holds the tokens of a key as of last. Waits take their token
+// in advance, so tokens may be negative while waits are in progress.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Reports whether an event for the specified key may happen now.
+func (l *keyedLimiter) Allow(key string) bool {
+	s := l.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	b := l.bucket(s, key)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Blocks until an event for the specified key may happen or ctx is done.
+func (l *keyedLimiter) Wait(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s := l.shard(key)
+	s.lock.Lock()
+	b := l.bucket(s, key)
+	b.tokens--
+	if b.tokens >= 0 {
+		s.lock.Unlock()
+		return nil
+	}
+	var expired <-chan time.Time
+	if l.rate > 0 {
+		wait := time.Duration(-b.tokens / l.rate * float64(time.Second))
+		timer := l.clock.NewTimer(wait)
+		defer timer.Stop()
+		expired = timer.C()
+	}
+	s.lock.Unlock()
+
+	select {
+	case <-expired:
+		return nil
+	case <-ctx.Done():
+	}
+	// Give the token back for other waits. The bucket can't have been
+	// dropped in the meantime, since it isn't full while this wait holds a
+	// token.
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	l.refill(b)
+	b.tokens++
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	return ctx.Err()
+}
+
+func (l *keyedLimiter) shard(key string) *limiterShard {
+	return &l.shards[hash(key)%uint32(len(l.shards))]
+}
+
+// bucket returns the bucket of key, refilled as of now, creating it if
+// needed. s.lock must be held.
+func (l *keyedLimiter) bucket(s *limiterShard, key string) *tokenBucket {
+	if b, ok := s.buckets[key]; ok {
+		l.refill(b)
+		return b
+	}
+	if len(s.buckets) >= s.sweepAt {
+		l.sweep(s)
+	}
+	b := &tokenBucket{tokens: l.burst, last: l.clock.Now()}
+	s.buckets[key] = b
+	return b
+}
+
+// refill adds the tokens accrued since b was last refilled.
+func (l *keyedLimiter) refill(b *tokenBucket) {
+	now := l.clock.Now()
+	if l.rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+	}
+	b.last = now
+}
+
+// sweep drops the buckets of s which are full, and so idle. Sweeping again
+// only once the number of buckets has doubled keeps its cost proportional to
+// the number of keys created. s.lock must be held.
+func (l *keyedLimiter) sweep(s *limiterShard) {
+	for key, b := range s.buckets {
+		if l.refill(b); b.tokens >= l.burst {
+			delete(s.buckets, key)
+		}
+	}
+	s.sweepAt = 2 * len(s.buckets)
+	if s.sweepAt < minLimiterSweep {
+		s.sweepAt = minLimiterSweep
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func Test_KeyedLimiter_Allow(t *testing.T) {
+	// Arrange
+	clk := testingclock.NewFakeClock(time.Now())
+	l := newKeyedLimiter(1, 10, 2, clk)
+	key := "fakeid"
+
+	// Act & Assert
+	if !l.Allow(key) || !l.Allow(key) {
+		t.Fatalf("Expected a new key to allow a burst of 2 events.")
+	}
+	if l.Allow(key) {
+		t.Fatalf("Expected the key to be limited once its burst is used up.")
+	}
+	if !l.Allow("otherid") {
+		t.Fatalf("Expected another key not to be limited by the first.")
+	}
+	clk.Step(50 * time.Millisecond)
+	if l.Allow(key) {
+		t.Fatalf("Expected half a token not to allow an event.")
+	}
+	clk.Step(50 * time.Millisecond)
+	if !l.Allow(key) {
+		t.Fatalf("Expected a token to be refilled after 100ms at 10 per second.")
+	}
+	if l.Allow(key) {
+		t.Fatalf("Expected only one token to be refilled.")
+	}
+}
+
+func Test_KeyedLimiter_Wait(t *testing.T) {
+	// Arrange
+	clk := testingclock.NewFakeClock(time.Now())
+	l := newKeyedLimiter(1, 10, 1, clk)
+	key := "fakeid"
+	errCh := make(chan error, 1)
+	if err := l.Wait(context.Background(), key); err != nil {
+		t.Fatalf("Expected the first Wait to take the burst token, got %v.", err)
+	}
+
+	// Act
+	go func() {
+		errCh <- l.Wait(context.Background(), key)
+	}()
+	verifyEventually(t, clk.HasWaiters)
+
+	// Assert
+	select {
+	case err := <-errCh:
+		t.Fatalf("Expected Wait to block until a token is refilled, got %v.", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Step(100 * time.Millisecond)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Expected Wait to succeed once a token was refilled, got %v.", err)
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for Wait.")
+	}
+	if l.Allow(key) {
+		t.Fatalf("Expected the refilled token to have been taken by Wait.")
+	}
+}
+
+func Test_KeyedLimiter_WaitCancelled(t *testing.T) {
+	// Arrange
+	clk := testingclock.NewFakeClock(time.Now())
+	l := newKeyedLimiter(1, 10, 1, clk)
+	key := "fakeid"
+	l.Allow(key)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- l.Wait(ctx, key)
+	}()
+	verifyEventually(t, clk.HasWaiters)
+
+	// Act
+	cancel()
+
+	// Assert
+	select {
+	case err := <-errCh:
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got %v.", err)
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for the cancelled Wait.")
+	}
+	clk.Step(100 * time.Millisecond)
+	if !l.Allow(key) {
+		t.Fatalf("Expected the cancelled Wait to give its token back.")
+	}
+}
+
+func Test_KeyedLimiter_DropsIdleKeys(t *testing.T) {
+	// Arrange
+	clk := testingclock.NewFakeClock(time.Now())
+	l := newKeyedLimiter(1, 10, 1, clk)
+	l.Allow("active")
+
+	// Act
+	for i := 0; i < 10*minLimiterSweep; i++ {
+		l.Allow(fmt.Sprint(i))
+		clk.Step(10 * time.Millisecond)
+		l.Allow("active")
+	}
+
+	// Assert
+	if keys := len(l.shards[0].buckets); keys > 2*minLimiterSweep {
+		t.Fatalf("Expected idle keys to be dropped, got %d keys.", keys)
+	}
+	if _, ok := l.shards[0].buckets["active"]; !ok {
+		t.Fatalf("Expected a key which is still limited to be kept.")
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsObserver receives measurements of lock usage, so callers can export
+// them to a metrics system such as Prometheus without this package depending
+// on it. Locks are identified by their shard index, which keeps the
+// cardinality bounded by the number of locks.
+// Methods are called synchronously while locking and unlocking and must be
+// cheap and safe for concurrent use.
+type MetricsObserver interface {
+	// ObserveWaitDuration is called when a blocking acquisition of the lock
+	// at index shard succeeds, with how long it waited.
+	ObserveWaitDuration(shard int, d time.Duration)
+
+	// IncHeld is called when the lock at index shard is acquired.
+	IncHeld(shard int)
+
+	// DecHeld is called when the lock at index shard is released.
+	DecHeld(shard int)
+}
+
+// HoldDurationObserver is implemented by MetricsObserver instances which also
+// want to know how long locks are held. Measuring this reads the clock on
+// every acquisition, so it is only done if the observer implements it.
+type HoldDurationObserver interface {
+	// ObserveHoldDuration is called when the lock at index shard is
+	// released, with how long it was held since it was acquired.
+	ObserveHoldDuration(shard int, d time.Duration)
+}
+
+// ContextFailureObserver is implemented by MetricsObserver instances which
+// also want to count the context-aware acquisitions which give up because
+// their context is done, e.g. to alert on a rising ratio of them to the
+// acquisitions reported by ObserveWaitDuration. Acquisitions which give up
+// because the KeyMutex was closed or CancelWaiters was called are not
+// counted.
+type ContextFailureObserver interface {
+	// IncContextCancelled is called when an acquisition of the lock at index
+	// shard gives up because its context was cancelled.
+	IncContextCancelled(shard int)
+
+	// IncContextDeadlineExceeded is called when an acquisition of the lock
+	// at index shard gives up because its context's deadline passed,
+	// including in LockKeyWithTimeout.
+	IncContextDeadlineExceeded(shard int)
+}
+
+// NewHashedWithObserver is like NewHashed, but reports lock usage to
+// observer. If observer is also a HoldDurationObserver, it is told how long
+// each lock was held as well, and if it is a ContextFailureObserver, how many
+// acquisitions gave up because their context was done.
+func NewHashedWithObserver(n int, observer MetricsObserver) KeyMutex {
+	return NewHashedWithOptions(n, WithObserver(observer))
+}
+
+// WithObserver configures observer to receive measurements of lock usage.
+// By default nothing is observed, at no cost to locking.
+func WithObserver(observer MetricsObserver) Option {
+	return func(km *hashedKeyMutex) {
+		km.observer = observer
+		km.holdObserver, _ = observer.(HoldDurationObserver)
+		km.contextObserver, _ = observer.(ContextFailureObserver)
+	}
+}
+
+// observeContextFailure reports that an acquisition of the lock id hashes to
+// gave up with err, the error of its context.
+func (km *hashedKeyMutex) observeContextFailure(id string, err error) {
+	shard := km.shardIndex(km.current(), id)
+	if err == context.Canceled {
+		km.contextObserver.IncContextCancelled(shard)
+	} else {
+		km.contextObserver.IncContextDeadlineExceeded(shard)
+	}
+}
+
+// DefaultKeyClass is the class of every key when no classifier is given, so
+// that a ClassObserver then receives a single series.
+const DefaultKeyClass = "default"
+
+// ClassObserver receives measurements of lock usage like MetricsObserver,
+// but segmented by a class derived from each key, such as "user" or "order",
+// rather than by shard. This keeps the cardinality bounded by the number of
+// classes while grouping measurements in a way meaningful to the caller.
+// Methods are called synchronously while locking and unlocking and must be
+// cheap and safe for concurrent use.
+type ClassObserver interface {
+	// ObserveWaitDuration is called when a blocking acquisition of a key of
+	// the class succeeds, with how long it waited.
+	ObserveWaitDuration(class string, d time.Duration)
+
+	// IncHeld is called when a key of the class is locked.
+	IncHeld(class string)
+
+	// DecHeld is called when a key of the class is unlocked.
+	DecHeld(class string)
+}
+
+// NewHashedWithClassifiedObserver is like NewHashed, but reports lock usage
+// to observer, segmented by the class classify returns for each key. If
+// classify is nil, every key is of DefaultKeyClass.
+func NewHashedWithClassifiedObserver(n int, classify func(key string) string, observer ClassObserver) KeyMutex {
+	return NewHashedWithOptions(n, WithClassifiedObserver(classify, observer))
+}
+
+// WithClassifiedObserver configures observer to receive measurements of lock
+// usage, segmented by the class classify returns for each key. If classify is
+// nil, every key is of DefaultKeyClass. classify is called on every
+// acquisition and release, so it must be cheap, and it must return the same
+// class for a key every time. Keys are classified after normalization, if a
+// Normalizer is configured.
+func WithClassifiedObserver(classify func(key string) string, observer ClassObserver) Option {
+	return func(km *hashedKeyMutex) {
+		if classify == nil {
+			classify = func(string) string { return DefaultKeyClass }
+		}
+		km.classify = classify
+		km.classObserver = observer
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeObserver struct {
+	lock  sync.Mutex
+	waits map[int][]time.Duration
+	held  map[int]int
+}
+
+func newFakeObserver() *fakeObserver {
+	return &fakeObserver{
+		waits: map[int][]time.Duration{},
+		held:  map[int]int{},
+	}
+}
+
+func (o *fakeObserver) ObserveWaitDuration(shard int, d time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.waits[shard] = append(o.waits[shard], d)
+}
+
+func (o *fakeObserver) IncHeld(shard int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.held[shard]++
+}
+
+func (o *fakeObserver) DecHeld(shard int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.held[shard]--
+}
+
+func (o *fakeObserver) heldCount(shard int) int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.held[shard]
+}
+
+func Test_Observer(t *testing.T) {
+	// Arrange
+	observer := newFakeObserver()
+	km := NewHashedWithObserver(4, observer)
+	key := "fakeid"
+	index := int(hash(key) % 4)
+	callbackCh := make(chan interface{})
+
+	// Act & Assert
+	km.LockKey(key)
+	if held := observer.heldCount(index); held != 1 {
+		t.Fatalf("Expected shard %d to be held once, got %d.", index, held)
+	}
+	go lockAndCallback(km, key, callbackCh)
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount(key) == 1 })
+	time.Sleep(10 * time.Millisecond)
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+	if TryLockKey(km, key) {
+		km.UnlockKey(key)
+	}
+
+	observer.lock.Lock()
+	defer observer.lock.Unlock()
+	if held := observer.held[index]; held != 0 {
+		t.Errorf("Expected shard %d to be released, got %d held.", index, held)
+	}
+	waits := observer.waits[index]
+	if len(waits) != 2 {
+		t.Fatalf("Expected 2 observed waits on shard %d, got %v.", index, waits)
+	}
+	if waits[1] < 10*time.Millisecond {
+		t.Errorf("Expected the contended wait to take at least 10ms, got %v.", waits[1])
+	}
+}
+
+type fakeHoldObserver struct {
+	*fakeObserver
+	holds map[int][]time.Duration
+}
+
+func (o *fakeHoldObserver) ObserveHoldDuration(shard int, d time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.holds[shard] = append(o.holds[shard], d)
+}
+
+func Test_Observer_HoldDuration(t *testing.T) {
+	// Arrange
+	observer := &fakeHoldObserver{fakeObserver: newFakeObserver(), holds: map[int][]time.Duration{}}
+	km := NewHashedWithObserver(4, observer)
+	key := "fakeid"
+	index := int(hash(key) % 4)
+	const hold = 20 * time.Millisecond
+
+	// Act
+	km.LockKey(key)
+	time.Sleep(hold)
+	km.UnlockKey(key)
+	LockKeys(km, key)
+	UnlockKeys(km, key)
+
+	// Assert
+	observer.lock.Lock()
+	defer observer.lock.Unlock()
+	holds := observer.holds[index]
+	if len(holds) != 2 {
+		t.Fatalf("Expected 2 hold durations for shard %d, got %v.", index, observer.holds)
+	}
+	if holds[0] < hold {
+		t.Errorf("Expected the first hold to last at least %v, got %v.", hold, holds[0])
+	}
+	if holds[1] >= holds[0] {
+		t.Errorf("Expected the second hold to be shorter than the first, got %v.", holds)
+	}
+}
+
+type fakeContextObserver struct {
+	*fakeObserver
+	cancelled        map[int]int
+	deadlineExceeded map[int]int
+}
+
+func (o *fakeContextObserver) IncContextCancelled(shard int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.cancelled[shard]++
+}
+
+func (o *fakeContextObserver) IncContextDeadlineExceeded(shard int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.deadlineExceeded[shard]++
+}
+
+func Test_Observer_ContextFailures(t *testing.T) {
+	// Arrange
+	observer := &fakeContextObserver{fakeObserver: newFakeObserver(), cancelled: map[int]int{}, deadlineExceeded: map[int]int{}}
+	km := NewHashedWithObserver(4, observer)
+	key := "fakeid"
+	index := int(hash(key) % 4)
+	km.LockKey(key)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act
+	LockKeyWithContext(cancelled, km, key)
+	LockKeyWithTimeout(km, key, time.Millisecond)
+	km.UnlockKey(key)
+	LockKeyWithContext(context.Background(), km, key)
+	km.UnlockKey(key)
+
+	// Assert
+	observer.lock.Lock()
+	defer observer.lock.Unlock()
+	if got := observer.cancelled[index]; got != 1 {
+		t.Errorf("Expected 1 cancelled acquisition on shard %d, got %d.", index, got)
+	}
+	if got := observer.deadlineExceeded[index]; got != 1 {
+		t.Errorf("Expected 1 acquisition past its deadline on shard %d, got %d.", index, got)
+	}
+}
+
+type fakeClassObserver struct {
+	lock  sync.Mutex
+	waits map[string]int
+	held  map[string]int
+}
+
+func newFakeClassObserver() *fakeClassObserver {
+	return &fakeClassObserver{
+		waits: map[string]int{},
+		held:  map[string]int{},
+	}
+}
+
+func (o *fakeClassObserver) ObserveWaitDuration(class string, d time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.waits[class]++
+}
+
+func (o *fakeClassObserver) IncHeld(class string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.held[class]++
+}
+
+func (o *fakeClassObserver) DecHeld(class string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.held[class]--
+}
+
+func Test_ClassifiedObserver(t *testing.T) {
+	// Arrange
+	observer := newFakeClassObserver()
+	classify := func(key string) string { return strings.SplitN(key, ":", 2)[0] }
+	km := NewHashedWithClassifiedObserver(64, classify, observer)
+
+	// Act
+	km.LockKey("user:1")
+	km.LockKey("user:2")
+	km.LockKey("order:1")
+	observer.lock.Lock()
+	userHeld, orderHeld := observer.held["user"], observer.held["order"]
+	observer.lock.Unlock()
+	km.UnlockKey("user:1")
+	km.UnlockKey("user:2")
+	km.UnlockKey("order:1")
+
+	// Assert
+	if userHeld != 2 || orderHeld != 1 {
+		t.Fatalf("Expected 2 held keys of class user and 1 of class order, got %d and %d.", userHeld, orderHeld)
+	}
+	observer.lock.Lock()
+	defer observer.lock.Unlock()
+	if len(observer.waits) != 2 || observer.waits["user"] != 2 || observer.waits["order"] != 1 {
+		t.Fatalf("Expected waits to aggregate under classes user and order, got %v.", observer.waits)
+	}
+	if observer.held["user"] != 0 || observer.held["order"] != 0 {
+		t.Fatalf("Expected all keys to be released, got %v.", observer.held)
+	}
+}
+
+func Test_ClassifiedObserver_DefaultClass(t *testing.T) {
+	// Arrange
+	observer := newFakeClassObserver()
+	km := NewHashedWithClassifiedObserver(64, nil, observer)
+
+	// Act
+	LockKeys(km, "a", "b")
+	UnlockKeys(km, "a", "b")
+
+	// Assert
+	observer.lock.Lock()
+	defer observer.lock.Unlock()
+	if len(observer.waits) != 1 || observer.waits[DefaultKeyClass] != 2 {
+		t.Fatalf("Expected waits to aggregate under %q, got %v.", DefaultKeyClass, observer.waits)
+	}
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// mutex is a mutual exclusion lock built on a sync.Mutex, which waits that
+// can't give up, such as LockKey's, block on directly. Since a sync.Mutex
+// can't be waited for with a way to give up, which is what the context aware
+// methods of KeyMutex are built on, those waits instead retry the sync.Mutex
+// each time it is released. Unlocking only announces releases while such
+// waits are in progress, so it costs next to nothing otherwise. The zero
+// value is an unlocked mutex.
+type mutex struct {
+	m sync.Mutex
+	releases
+}
+
+func (m *mutex) lock() {
+	m.m.Lock()
+}
+
+func (m *mutex) tryLock() bool {
+	return m.m.TryLock()
+}
+
+// lockOrDone blocks until the lock is acquired or done is closed. A nil done
+// channel waits forever.
+func (m *mutex) lockOrDone(done <-chan struct{}) bool {
+	return m.lockOrAbort(done, nil)
+}
+
+// lockOrAbort is like lockOrDone, but also gives up once abort is closed.
+// Waits which can give up compete with the waits in lock for each release,
+// rather than queueing with them.
+func (m *mutex) lockOrAbort(done, abort <-chan struct{}) bool {
+	if done == nil && abort == nil {
+		m.m.Lock()
+		return true
+	}
+	if m.m.TryLock() {
+		return true
+	}
+	return m.retry(m.m.TryLock, done, abort)
+}
+
+// unlock releases the lock, which must be held: like sync.Mutex, unlocking an
+// unlocked mutex is a fatal error.
+func (m *mutex) unlock() {
+	m.m.Unlock()
+	m.announce()
+}
+
+// releases announces the releases of a lock which can't be waited for with a
+// way to give up, such as a sync.Mutex, to the waits which can, while there
+// are any. The zero value is ready to use.
+type releases struct {
+	// aborters counts the waits in retry.
+	aborters int32
+	// wake guards released.
+	wake sync.Mutex
+	// released, if set, is closed by announce while aborters > 0.
+	released chan struct{}
+}
+
+// retry calls try each time the lock is released, until it returns true or
+// either done or abort is closed.
+func (r *releases) retry(try func() bool, done, abort <-chan struct{}) bool {
+	atomic.AddInt32(&r.aborters, 1)
+	defer atomic.AddInt32(&r.aborters, -1)
+	for {
+		released := r.nextRelease()
+		// Now that the next release is sure to be announced, make sure the
+		// lock wasn't released before.
+		if try() {
+			return true
+		}
+		select {
+		case <-released:
+		case <-done:
+			return false
+		case <-abort:
+			return false
+		}
+	}
+}
+
+// nextRelease returns a channel which is closed once the lock is next
+// released, as long as aborters is positive.
+func (r *releases) nextRelease() <-chan struct{} {
+	r.wake.Lock()
+	defer r.wake.Unlock()
+	if r.released == nil {
+		r.released = make(chan struct{})
+	}
+	return r.released
+}
+
+// announce wakes the waits in retry, if any. It must be called after each
+// release of the lock.
+func (r *releases) announce() {
+	if atomic.LoadInt32(&r.aborters) != 0 {
+		r.wake.Lock()
+		if r.released != nil {
+			close(r.released)
+			r.released = nil
+		}
+		r.wake.Unlock()
+	}
+}
+
+// trackedMutex is a mutex which also keeps track of whether it is held, for
+// callers which have to detect unlocking an unlocked lock.
+type trackedMutex struct {
+	m mutex
+	// held mirrors whether m is locked, which sync.Mutex doesn't report.
+	held int32
+}
+
+func (m *trackedMutex) lock() {
+	m.m.lock()
+	atomic.StoreInt32(&m.held, 1)
+}
+
+func (m *trackedMutex) tryLock() bool {
+	if !m.m.tryLock() {
+		return false
+	}
+	atomic.StoreInt32(&m.held, 1)
+	return true
+}
+
+// lockOrAbort is like mutex.lockOrAbort.
+func (m *trackedMutex) lockOrAbort(done, abort <-chan struct{}) bool {
+	if !m.m.lockOrAbort(done, abort) {
+		return false
+	}
+	atomic.StoreInt32(&m.held, 1)
+	return true
+}
+
+// locked reports whether the lock is currently held, without blocking.
+func (m *trackedMutex) locked() bool {
+	return atomic.LoadInt32(&m.held) == 1
+}
+
+// tryUnlock releases the lock, returning false if it was not held.
+func (m *trackedMutex) tryUnlock() bool {
+	if !atomic.CompareAndSwapInt32(&m.held, 1, 0) {
+		return false
+	}
+	m.m.unlock()
+	return true
+}
+
+// shardLocker is a mutual exclusion lock which a shard can be built on instead
+// of a mutex, such as a fairMutex or the locks chosen by a Strategy.
+type shardLocker interface {
+	tryLock() bool
+	// lockOrAbort blocks until the lock is acquired or either done or abort
+	// is closed. Locks which grant waiters in order do so by decreasing prio,
+	// others ignore it.
+	lockOrAbort(prio int, done, abort <-chan struct{}) bool
+	// unlock panics if the lock isn't held.
+	unlock()
+	locked() bool
+}
+
+// shard is a single lock of a hashed KeyMutex along with contention counters
+// which are maintained with atomics, so reading them never blocks the lock.
+type shard struct {
+	// The 64-bit fields are kept first so that they are 64-bit aligned on
+	// 32-bit platforms.
+	acquisitions uint64
+	contended    uint64
+	// sameKeyContended and otherKeyContended split contended by whether
+	// the lock was held for the same key as the waiter's, if attributed.
+	sameKeyContended  uint64
+	otherKeyContended uint64
+	// token is the Token the lock is held under, or 0 if the holder has none.
+	// It is cleared when the lock is released, so a stale Token can't claim
+	// it.
+	token uint64
+	// owner is the ID of the goroutine holding the lock, if tracked.
+	owner uint64
+	// depth is the number of times the owner has locked the lock, if
+	// tracked. It is only accessed by the owner.
+	depth   int
+	waiters int32
+	// state is one of shardFree, shardEntering and shardHeld.
+	state int32
+	// index is the position of the shard among its KeyMutex's locks.
+	index int
+	mutex mutex
+	// locker, if set, is locked instead of mutex, such as a fairMutex
+	// granting the lock to waiters in the order they started waiting.
+	locker shardLocker
+	// holder is the key the lock was last acquired for. It is written by the
+	// holder right after locking and read when unlocking, which the mutex
+	// orders against the next acquisition. The key is copied into a buffer
+	// which is reused, so that keys passed in need not outlive the
+	// call and acquiring doesn't allocate.
+	holder []byte
+	// acquiredAt is when the lock was last acquired, if hold durations are
+	// observed. Like holder, it is written by the holder right after locking.
+	acquiredAt time.Time
+	// limited is set while the holder has taken a slot of the KeyMutex's
+	// global limit. Like holder, it is only accessed by the holder.
+	limited bool
+	// labelled is set while the holder has recorded a label by
+	// LockKeyTagged. Like holder, it is only accessed by the holder.
+	labelled bool
+
+	// meta guards the fields below, which describe the current holder for
+	// HeldKeys and are only maintained when tracking is enabled.
+	meta      sync.Mutex
+	held      bool
+	heldKey   []byte
+	heldSince time.Time
+	// reported is set once a watchdog has reported the current hold.
+	reported bool
+	// label is the label recorded by the holder, which holds the lock on
+	// behalf of labelKey, or empty if there is none.
+	label    string
+	labelKey []byte
+}
+
+func newShards(n int) []shard {
+	shards := make([]shard, n)
+	for i := range shards {
+		shards[i].index = i
+	}
+	return shards
+}
+
+// newShardRefs returns n new shards laid out next to each other, as
+// newShards does, for the callers which refer to shards by pointer.
+func newShardRefs(n int) []*shard {
+	shards := newShards(n)
+	refs := make([]*shard, n)
+	for i := range shards {
+		refs[i] = &shards[i]
+	}
+	return refs
+}
+
+func (s *shard) lock() {
+	s.lockOrDone(nil, nil)
+}
+
+func (s *shard) tryLock() bool {
+	if s.locker != nil {
+		return s.locker.tryLock()
+	}
+	return s.mutex.tryLock()
+}
+
+// lockOrDone blocks until s is acquired or either done or abort is closed.
+func (s *shard) lockOrDone(done, abort <-chan struct{}) bool {
+	return s.tryLock() || s.lockContended(0, done, abort)
+}
+
+// lockContended is like lockOrDone, for when s was just found to be held.
+// Fair locks are granted to waiters with a higher prio first.
+func (s *shard) lockContended(prio int, done, abort <-chan struct{}) bool {
+	atomic.AddUint64(&s.contended, 1)
+	atomic.AddInt32(&s.waiters, 1)
+	defer atomic.AddInt32(&s.waiters, -1)
+	return s.wait(prio, done, abort)
+}
+
+// wait is like lockOrDone, but doesn't count towards the contention
+// statistics.
+func (s *shard) wait(prio int, done, abort <-chan struct{}) bool {
+	if s.locker != nil {
+		return s.locker.lockOrAbort(prio, done, abort)
+	}
+	return s.mutex.lockOrAbort(done, abort)
+}
+
+func (s *shard) unlock() {
+	if s.locker != nil {
+		s.locker.unlock()
+		return
+	}
+	s.mutex.unlock()
+}
+
+// heldFor reports whether s is held on behalf of id.
+func (s *shard) heldFor(id string) bool {
+	return atomic.LoadInt32(&s.state) == shardHeld && string(s.holder) == id
+}
+
+func (s *shard) locked() bool {
+	if s.locker != nil {
+		return s.locker.locked()
+	}
+	// A sync.Mutex doesn't report whether it is held, but the shard's state
+	// does, apart from brief holds which don't acquire any key.
+	return atomic.LoadInt32(&s.state) != shardFree
+}
+
+func (s *shard) setHeld(id string, since time.Time) {
+	s.meta.Lock()
+	defer s.meta.Unlock()
+	s.held = true
+	s.heldKey = append(s.heldKey[:0], id...)
+	s.heldSince = since
+	s.reported = false
+}
+
+func (s *shard) clearHeld() {
+	s.meta.Lock()
+	defer s.meta.Unlock()
+	s.held = false
+	s.heldKey = s.heldKey[:0]
+	s.heldSince = time.Time{}
+	s.reported = false
+}
+
+func (s *shard) heldBy() (HeldKey, bool) {
+	s.meta.Lock()
+	defer s.meta.Unlock()
+	return HeldKey{Key: string(s.heldKey), Acquired: s.heldSince, Label: s.label}, s.held
+}
+
+func (s *shard) waitersCount() int {
+	return int(atomic.LoadInt32(&s.waiters))
+}
+
+func (s *shard) stat(index int) ShardStat {
+	return ShardStat{
+		Index:             index,
+		Acquired:          atomic.LoadUint64(&s.acquisitions),
+		Contended:         atomic.LoadUint64(&s.contended),
+		SameKeyContended:  atomic.LoadUint64(&s.sameKeyContended),
+		OtherKeyContended: atomic.LoadUint64(&s.otherKeyContended),
+		Waiters:           s.waitersCount(),
+		Held:              atomic.LoadInt32(&s.state) == shardHeld,
+	}
+}
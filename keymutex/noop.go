@@ -0,0 +1,89 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"time"
+)
+
+// NewNoop returns a new instance of KeyMutex which never locks anything:
+// every acquisition succeeds immediately and unlocking does nothing.
+// Acquisitions which can fail only do so if their context or stop channel is
+// already done.
+//
+// It provides NO mutual exclusion, so any number of goroutines can "hold"
+// the same key at once. It is only safe where nothing else runs concurrently,
+// such as in single-threaded tools, or to measure or test code without the
+// cost of locking. Code which is correct with NewNoop doesn't depend on
+// mutual exclusion at all.
+func NewNoop() KeyMutex {
+	return noopKeyMutex{}
+}
+
+var (
+	_ KeyMutex         = noopKeyMutex{}
+	_ TryLocker        = noopKeyMutex{}
+	_ ContextLocker    = noopKeyMutex{}
+	_ TimeoutLocker    = noopKeyMutex{}
+	_ BatchLocker      = noopKeyMutex{}
+	_ ContextErrLocker = noopKeyMutex{}
+	_ StopLocker       = noopKeyMutex{}
+)
+
+type noopKeyMutex struct{}
+
+// Does nothing.
+func (noopKeyMutex) LockKey(id string) {}
+
+// Always succeeds.
+func (noopKeyMutex) TryLockKey(id string) bool {
+	return true
+}
+
+// Succeeds unless ctx is already done.
+func (noopKeyMutex) LockKeyWithContext(ctx context.Context, id string) bool {
+	return ctx.Err() == nil
+}
+
+// Returns ctx.Err().
+func (noopKeyMutex) LockKeyWithContextErr(ctx context.Context, id string) error {
+	return ctx.Err()
+}
+
+// Succeeds unless stop is already closed.
+func (noopKeyMutex) LockKeyWithStop(id string, stop <-chan struct{}) bool {
+	return !isStopped(stop)
+}
+
+// Always succeeds.
+func (noopKeyMutex) LockKeyWithTimeout(id string, d time.Duration) bool {
+	return true
+}
+
+// Does nothing.
+func (noopKeyMutex) UnlockKey(id string) error {
+	return nil
+}
+
+// Does nothing.
+func (noopKeyMutex) LockKeys(ids ...string) {}
+
+// Does nothing.
+func (noopKeyMutex) UnlockKeys(ids ...string) error {
+	return nil
+}
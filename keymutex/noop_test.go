@@ -0,0 +1,73 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Noop_NeverBlocks(t *testing.T) {
+	// Arrange
+	km := NewNoop()
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+	km.LockKey(key)
+	LockKeys(km, key, "other")
+
+	// Act
+	go lockAndCallback(km, key, callbackCh)
+
+	// Assert
+	verifyCallbackHappens(t, callbackCh)
+	if !TryLockKey(km, key) {
+		t.Fatalf("Expected TryLockKey to succeed on a held key.")
+	}
+	if !LockKeyWithTimeout(km, key, 0) {
+		t.Fatalf("Expected LockKeyWithTimeout to succeed on a held key.")
+	}
+	if err := km.UnlockKey("never-locked"); err != nil {
+		t.Fatalf("Expected UnlockKey to do nothing, got %v.", err)
+	}
+	if err := UnlockKeys(km, key, "other"); err != nil {
+		t.Fatalf("Expected UnlockKeys to do nothing, got %v.", err)
+	}
+}
+
+func Test_Noop_Cancelled(t *testing.T) {
+	// Arrange
+	km := NewNoop()
+	key := "fakeid"
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := make(chan struct{})
+
+	// Act & Assert
+	if !LockKeyWithContext(ctx, km, key) || LockKeyWithContextErr(ctx, km, key) != nil || !LockKeyWithStop(km, key, stop) {
+		t.Fatalf("Expected acquisitions to succeed before cancellation.")
+	}
+	cancel()
+	close(stop)
+	if LockKeyWithContext(ctx, km, key) {
+		t.Fatalf("Expected LockKeyWithContext to fail once ctx is done.")
+	}
+	if err := LockKeyWithContextErr(ctx, km, key); err != context.Canceled {
+		t.Fatalf("Expected LockKeyWithContextErr to return context.Canceled, got %v.", err)
+	}
+	if LockKeyWithStop(km, key, stop) {
+		t.Fatalf("Expected LockKeyWithStop to fail once stop is closed.")
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+// NewHashedWithNormalizer is like NewHashed, but passes every key through
+// normalize before using it, so that keys which normalize to the same string
+// are the same key. normalize must be deterministic and safe for concurrent
+// use.
+func NewHashedWithNormalizer(n int, normalize func(string) string) KeyMutex {
+	return NewHashedWithOptions(n, WithNormalizer(normalize))
+}
+
+// WithNormalizer passes every key through normalize before using it, as
+// NewHashedWithNormalizer does. A nil normalize uses keys as they are.
+func WithNormalizer(normalize func(string) string) Option {
+	return func(km *hashedKeyMutex) {
+		km.normalize = normalize
+	}
+}
+
+// normalized returns the canonical form of id.
+func (km *hashedKeyMutex) normalized(id string) string {
+	if km.normalize == nil {
+		return id
+	}
+	return km.normalize(id)
+}
+
+// normalizedAll returns the canonical forms of ids, reusing ids itself if no
+// normalizer is configured.
+func (km *hashedKeyMutex) normalizedAll(ids []string) []string {
+	if km.normalize == nil {
+		return ids
+	}
+	normalized := make([]string, len(ids))
+	for i, id := range ids {
+		normalized[i] = km.normalize(id)
+	}
+	return normalized
+}
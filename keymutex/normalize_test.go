@@ -0,0 +1,77 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"strings"
+	"testing"
+)
+
+func normalizeKey(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+func Test_HashedWithNormalizer_Contends(t *testing.T) {
+	// Arrange
+	km := NewHashedWithNormalizer(64, normalizeKey)
+	callbackCh := make(chan interface{})
+	km.LockKey("Order-1 ")
+
+	// Act
+	go lockAndCallback(km, "order-1", callbackCh)
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	if TryLockKey(km, "ORDER-1") {
+		t.Fatalf("Expected keys normalizing to the same string to share a lock.")
+	}
+	if err := km.UnlockKey(" order-1"); err != nil {
+		t.Fatalf("Expected a normalized key to unlock its lock, got %v.", err)
+	}
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey("Order-1")
+}
+
+func Test_HashedWithNormalizer_AllMethods(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(64, WithNormalizer(normalizeKey), WithHeldKeyTracking())
+
+	// Act & Assert
+	LockKeys(km, "A", "b ", "C")
+	if !km.(LockInspector).IsLocked("a") {
+		t.Fatalf("Expected IsLocked to normalize its key.")
+	}
+	for _, held := range km.(HeldKeysReporter).HeldKeys() {
+		if held.Key != normalizeKey(held.Key) {
+			t.Errorf("Expected held keys to be normalized, got %q.", held.Key)
+		}
+	}
+	UnlockKeys(km, "a", "B", " c")
+	if !TryLockKey(km, "a") {
+		t.Fatalf("Expected UnlockKeys to normalize its keys.")
+	}
+	km.UnlockKey("a")
+	km.(ByteKeyMutex).LockKeyBytes([]byte("Key"))
+	if TryLockKey(km, "key") {
+		t.Fatalf("Expected LockKeyBytes to normalize its key.")
+	}
+	km.(ByteKeyMutex).UnlockKeyBytes([]byte(" KEY"))
+	if !TryLockKey(km, "key") {
+		t.Fatalf("Expected UnlockKeyBytes to normalize its key.")
+	}
+	km.UnlockKey("key")
+}
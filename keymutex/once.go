@@ -0,0 +1,85 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"sync"
+)
+
+// KeyedOnce is a thread-safe interface for performing an action exactly once
+// for each of arbitrary strings, like a sync.Once per key.
+type KeyedOnce interface {
+	// Calls fn if and only if Do is being called for the specified key for
+	// the first time, and returns the error fn returned. Concurrent calls for
+	// the same key wait until fn returns, and all calls for the key return
+	// its error. If fn panics, Do considers it returned: the panic propagates
+	// to the first caller, and later calls return an error reporting it.
+	Do(key string, fn func() error) error
+}
+
+// NewKeyedOnce returns a new instance of KeyedOnce which locks keys as
+// NewHashed does while calling their functions. `shards` specifies number of
+// locks, if shards <= 0, a single lock is shared by all keys, as with
+// NewHashed.
+// The result for each key is kept for the lifetime of the KeyedOnce.
+// Note that because it uses fixed set of locks, different keys may share
+// same lock, so calling Do for another key from within fn may deadlock.
+func NewKeyedOnce(shards int) KeyedOnce {
+	return &keyedOnce{
+		km:   NewHashed(shards),
+		done: make(map[string]error),
+	}
+}
+
+var _ KeyedOnce = (*keyedOnce)(nil)
+
+type keyedOnce struct {
+	km KeyMutex
+	// lock guards done, which holds the result of each key whose function
+	// has returned.
+	lock sync.Mutex
+	done map[string]error
+}
+
+// Calls fn the first time Do is called for the specified key.
+func (o *keyedOnce) Do(key string, fn func() error) error {
+	if done, err := o.result(key); done {
+		return err
+	}
+	o.km.LockKey(key)
+	defer o.km.UnlockKey(key)
+	if done, err := o.result(key); done {
+		return err
+	}
+	err := fmt.Errorf("keymutex: function for key %q panicked", key)
+	defer func() {
+		o.lock.Lock()
+		defer o.lock.Unlock()
+		o.done[key] = err
+	}()
+	err = fn()
+	return err
+}
+
+// result reports whether the function for key has returned, and if so what.
+func (o *keyedOnce) result(key string) (done bool, err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	err, done = o.done[key]
+	return done, err
+}
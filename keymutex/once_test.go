@@ -0,0 +1,108 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_KeyedOnce_RunsOnce(t *testing.T) {
+	for _, shards := range []int{0, 1, 4} {
+		// Arrange
+		once := NewKeyedOnce(shards)
+		key := "fakeid"
+		expected := errors.New("fake error")
+		var calls int32
+		const goroutines = 50
+		errs := make(chan error, goroutines)
+		var wg sync.WaitGroup
+
+		// Act
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- once.Do(key, func() error {
+					atomic.AddInt32(&calls, 1)
+					time.Sleep(10 * time.Millisecond)
+					return expected
+				})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		// Assert
+		if calls != 1 {
+			t.Fatalf("Expected the function to run once, ran %d times.", calls)
+		}
+		for err := range errs {
+			if err != expected {
+				t.Fatalf("Expected every caller to get %v, got %v.", expected, err)
+			}
+		}
+	}
+}
+
+func Test_KeyedOnce_PerKey(t *testing.T) {
+	// Arrange
+	once := NewKeyedOnce(4)
+	var calls int32
+	fn := func() error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+
+	// Act
+	for _, key := range []string{"a", "b", "a", "c", "b"} {
+		if err := once.Do(key, fn); err != nil {
+			t.Fatalf("Unexpected error from Do: %v", err)
+		}
+	}
+
+	// Assert
+	if calls != 3 {
+		t.Fatalf("Expected the function to run once for each of 3 keys, ran %d times.", calls)
+	}
+}
+
+func Test_KeyedOnce_Panic(t *testing.T) {
+	// Arrange
+	once := NewKeyedOnce(1)
+	key := "fakeid"
+
+	// Act
+	recovered := recoverPanic(func() {
+		once.Do(key, func() error { panic("fake panic") })
+	})
+
+	// Assert
+	if recovered == nil {
+		t.Fatalf("Expected the panic to propagate to the first caller.")
+	}
+	called := false
+	if err := once.Do(key, func() error { called = true; return nil }); err == nil {
+		t.Fatalf("Expected later calls to report the panic.")
+	}
+	if called {
+		t.Fatalf("Expected the function not to run again after panicking.")
+	}
+}
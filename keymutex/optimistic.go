@@ -0,0 +1,201 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// NewOptimisticHashed returns a new instance of KeyMutex which hashes
+// arbitrary keys to a fixed set of locks, like NewHashed, for workloads where
+// conflicts are rare. Each lock is a single atomic flag: TryLockKey, and
+// acquisitions which find their lock free, take it with one compare-and-swap
+// and nothing else, so the uncontended path costs little more than hashing
+// the key. Only acquisitions which find their lock held fall back to waiting,
+// parked on a channel until the holder wakes them.
+// `n` specifies number of locks, if n <= 0, a single lock is shared by all
+// keys, as with NewHashed.
+//
+// To keep that path short, the KeyMutex implements none of the optional
+// interfaces NewHashed does, and accepts no Options.
+func NewOptimisticHashed(n int) KeyMutex {
+	if n <= 0 {
+		n = 1
+	}
+	shards := make([]optimisticMutex, n)
+	for i := range shards {
+		shards[i].wake = make(chan struct{}, 1)
+	}
+	return &optimisticKeyMutex{shards: shards}
+}
+
+var (
+	_ KeyMutex         = (*optimisticKeyMutex)(nil)
+	_ TryLocker        = (*optimisticKeyMutex)(nil)
+	_ ContextLocker    = (*optimisticKeyMutex)(nil)
+	_ TimeoutLocker    = (*optimisticKeyMutex)(nil)
+	_ BatchLocker      = (*optimisticKeyMutex)(nil)
+	_ ContextErrLocker = (*optimisticKeyMutex)(nil)
+	_ StopLocker       = (*optimisticKeyMutex)(nil)
+)
+
+type optimisticKeyMutex struct {
+	shards []optimisticMutex
+}
+
+// Acquires a lock associated with the specified ID.
+func (km *optimisticKeyMutex) LockKey(id string) {
+	km.shard(id).lockOrDone(nil)
+}
+
+// Attempts to acquire the lock associated with the specified ID without blocking.
+func (km *optimisticKeyMutex) TryLockKey(id string) bool {
+	return km.shard(id).tryLock()
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is done.
+func (km *optimisticKeyMutex) LockKeyWithContext(ctx context.Context, id string) bool {
+	return km.LockKeyWithContextErr(ctx, id) == nil
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is done.
+// Returns ctx.Err() if ctx was done first.
+func (km *optimisticKeyMutex) LockKeyWithContextErr(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if km.shard(id).lockOrDone(ctx.Done()) {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// Acquires a lock associated with the specified ID, giving up when stop is
+// closed.
+func (km *optimisticKeyMutex) LockKeyWithStop(id string, stop <-chan struct{}) bool {
+	if isStopped(stop) {
+		return false
+	}
+	return km.shard(id).lockOrDone(stop)
+}
+
+// Acquires a lock associated with the specified ID, giving up after d.
+func (km *optimisticKeyMutex) LockKeyWithTimeout(id string, d time.Duration) bool {
+	return lockKeyWithTimeout[string](km, id, d)
+}
+
+// Releases the lock associated with the specified ID.
+// Panics if the specified ID is not locked.
+func (km *optimisticKeyMutex) UnlockKey(id string) error {
+	km.shard(id).unlock()
+	return nil
+}
+
+// Acquires the locks associated with all of the specified IDs. IDs are
+// locked in the order of the locks they hash to, and IDs sharing a lock only
+// lock it once.
+func (km *optimisticKeyMutex) LockKeys(ids ...string) {
+	for _, i := range km.shardIndexes(ids) {
+		km.shards[i].lockOrDone(nil)
+	}
+}
+
+// Releases the locks associated with all of the specified IDs.
+func (km *optimisticKeyMutex) UnlockKeys(ids ...string) error {
+	for _, i := range km.shardIndexes(ids) {
+		km.shards[i].unlock()
+	}
+	return nil
+}
+
+func (km *optimisticKeyMutex) shard(id string) *optimisticMutex {
+	return &km.shards[hash(id)%uint32(len(km.shards))]
+}
+
+// shardIndexes returns the distinct indexes of the locks ids hash to, in
+// ascending order.
+func (km *optimisticKeyMutex) shardIndexes(ids []string) []int {
+	indexes := make([]int, 0, len(ids))
+	for _, id := range ids {
+		indexes = append(indexes, int(hash(id)%uint32(len(km.shards))))
+	}
+	sort.Ints(indexes)
+	unique := indexes[:0]
+	for i, index := range indexes {
+		if i == 0 || index != indexes[i-1] {
+			unique = append(unique, index)
+		}
+	}
+	return unique
+}
+
+// optimisticMutex is a mutual exclusion lock which is acquired by a
+// compare-and-swap of a single flag, and waited for on a channel only once
+// that fails.
+type optimisticMutex struct {
+	held int32
+	// waiters counts the goroutines which may be waiting on wake, one of
+	// which unlocking has to wake.
+	waiters int32
+	// wake holds a token for a waiter to try again once the lock has been
+	// released. A token may outlive the waiter it was meant for, so being
+	// woken doesn't guarantee the lock is free.
+	wake chan struct{}
+}
+
+func (m *optimisticMutex) tryLock() bool {
+	return atomic.CompareAndSwapInt32(&m.held, 0, 1)
+}
+
+// lockOrDone blocks until the lock is acquired or done is closed. A nil done
+// channel waits forever.
+func (m *optimisticMutex) lockOrDone(done <-chan struct{}) bool {
+	for {
+		if m.tryLock() {
+			return true
+		}
+		atomic.AddInt32(&m.waiters, 1)
+		// Try again once registered, since the holder may have released the
+		// lock before it could see this waiter.
+		if m.tryLock() {
+			atomic.AddInt32(&m.waiters, -1)
+			return true
+		}
+		select {
+		case <-m.wake:
+		case <-done:
+			atomic.AddInt32(&m.waiters, -1)
+			return false
+		}
+		atomic.AddInt32(&m.waiters, -1)
+	}
+}
+
+func (m *optimisticMutex) unlock() {
+	if !atomic.CompareAndSwapInt32(&m.held, 1, 0) {
+		panic("keymutex: unlock of unlocked mutex")
+	}
+	if atomic.LoadInt32(&m.waiters) > 0 {
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	}
+}
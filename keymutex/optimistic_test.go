@@ -0,0 +1,119 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_OptimisticHashed_MutualExclusion(t *testing.T) {
+	// Arrange
+	km := NewOptimisticHashed(1)
+	const workers = 8
+	const rounds = 1000
+	counter := 0
+	var wg sync.WaitGroup
+
+	// Act
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				switch {
+				case i%2 == 0:
+					// Polling never waits, so waiters only wake up when a
+					// poller releases the lock.
+					for !TryLockKey(km, "a") {
+						runtime.Gosched()
+					}
+				case j%3 == 0:
+					km.LockKey("a")
+				case j%3 == 1:
+					LockKeys(km, "a", "b")
+					counter++
+					UnlockKeys(km, "a", "b")
+					continue
+				default:
+					if !LockKeyWithTimeout(km, "a", callbackTimeout) {
+						t.Error("Expected a waiter to be woken up within the timeout.")
+						return
+					}
+				}
+				counter++
+				km.UnlockKey("a")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert
+	if counter != workers*rounds {
+		t.Fatalf("Expected %d increments, got %d.", workers*rounds, counter)
+	}
+}
+
+func Test_OptimisticHashed_WaiterGivesUp(t *testing.T) {
+	// Arrange
+	km := NewOptimisticHashed(1)
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+	km.LockKey(key)
+
+	// Act
+	acquired := LockKeyWithContext(expiredContext(), km, key)
+
+	// Assert
+	if acquired {
+		t.Fatalf("Expected LockKeyWithContext to give up on a held key.")
+	}
+	go lockAndCallback(km, key, callbackCh)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+}
+
+func BenchmarkOptimisticHashed_TryLockKey(b *testing.B) {
+	km := NewOptimisticHashed(1)
+	for i := 0; i < b.N; i++ {
+		TryLockKey(km, "fakeid")
+		km.UnlockKey("fakeid")
+	}
+}
+
+func BenchmarkHashed_TryLockKey(b *testing.B) {
+	km := NewHashed(1)
+	for i := 0; i < b.N; i++ {
+		TryLockKey(km, "fakeid")
+		km.UnlockKey("fakeid")
+	}
+}
+
+// BenchmarkCompareAndSwap measures a bare compare-and-swap acquiring a flag
+// and another releasing it, which BenchmarkOptimisticHashed_TryLockKey should
+// stay close to.
+func BenchmarkCompareAndSwap(b *testing.B) {
+	var held int32
+	for i := 0; i < b.N; i++ {
+		atomic.CompareAndSwapInt32(&held, 0, 1)
+		atomic.CompareAndSwapInt32(&held, 1, 0)
+	}
+}
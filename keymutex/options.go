@@ -0,0 +1,64 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"k8s.io/utils/clock"
+)
+
+// Option configures optional behavior of a KeyMutex created by
+// NewHashedWithOptions.
+type Option func(*hashedKeyMutex)
+
+// NewHashedWithOptions is like NewHashed, with optional behavior configured
+// by opts.
+func NewHashedWithOptions(n int, opts ...Option) KeyMutex {
+	km := newHashed(n, nil)
+	for _, opt := range opts {
+		opt(km)
+	}
+	return km
+}
+
+// WithTracer configures tracer to be notified whenever LockKeyWithContext
+// or LockKeyWithTimeout waits for a lock. By default nothing is traced.
+func WithTracer(tracer TracerHook) Option {
+	return func(km *hashedKeyMutex) {
+		km.tracer = tracer
+	}
+}
+
+// WithHeldKeyTracking records which key holds each lock and since when, so
+// that HeldKeys can report them. This adds a little bookkeeping to every
+// acquisition and release.
+func WithHeldKeyTracking() Option {
+	return func(km *hashedKeyMutex) {
+		km.trackHeld = true
+	}
+}
+
+// WithClock takes the time keys are acquired and held for, as reported by
+// HeldKeys and HoldDurationObserver and checked by WithWatchdog, from clk
+// instead of the real clock, so that tests can advance it deterministically.
+// Waits for locks are still measured in real time. clk must call the
+// functions passed to AfterFunc without holding any lock of its own, since
+// they read clk in turn.
+func WithClock(clk clock.WithDelayedExecution) Option {
+	return func(km *hashedKeyMutex) {
+		km.clock = clk
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+// LockOrderHook is notified around every acquisition and release of a lock
+// together with the goroutine doing it, so that an external deadlock
+// detector can track which locks each goroutine holds and waits for, and
+// build a wait-for graph across keymutex and other locks, without this
+// package depending on it. Methods are called synchronously on the goroutine
+// acquiring or releasing the lock, so they must be safe for concurrent use,
+// and must not lock or unlock keys of the same KeyMutex.
+//
+// Goroutines are identified by the ID printed in their stack traces, as the
+// runtime offers no other. Keys which share a lock and are locked together by
+// LockKeys are reported once, under the smallest of them, and reentrant
+// acquisitions by the holder of a lock are not reported at all.
+type LockOrderHook interface {
+	// BeforeAcquire is called when goroutine starts waiting for the lock
+	// for key. TryLockKey, which never waits, doesn't call it.
+	BeforeAcquire(goroutine uint64, key string)
+
+	// AfterAcquire is called when goroutine has acquired the lock for key,
+	// or has given up waiting for it, after a call to BeforeAcquire. It is
+	// also called when TryLockKey acquires a lock.
+	AfterAcquire(goroutine uint64, key string, acquired bool)
+
+	// BeforeRelease is called when goroutine is about to release the lock
+	// for key.
+	BeforeRelease(goroutine uint64, key string)
+}
+
+// WithLockOrderHook configures hook to be notified around every acquisition
+// and release of a lock. Identifying the calling goroutine is slow, so this
+// is meant for debugging and testing. By default nothing is notified, at no
+// cost to locking.
+func WithLockOrderHook(hook LockOrderHook) Option {
+	return func(km *hashedKeyMutex) {
+		km.orderHook = hook
+	}
+}
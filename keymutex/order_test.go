@@ -0,0 +1,159 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type fakeOrderHook struct {
+	lock       sync.Mutex
+	goroutines map[uint64]bool
+	calls      []string
+}
+
+func (h *fakeOrderHook) record(goroutine uint64, call string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.goroutines == nil {
+		h.goroutines = make(map[uint64]bool)
+	}
+	h.goroutines[goroutine] = true
+	h.calls = append(h.calls, call)
+}
+
+func (h *fakeOrderHook) BeforeAcquire(goroutine uint64, key string) {
+	h.record(goroutine, "before "+key)
+}
+
+func (h *fakeOrderHook) AfterAcquire(goroutine uint64, key string, acquired bool) {
+	h.record(goroutine, fmt.Sprintf("after %s %t", key, acquired))
+}
+
+func (h *fakeOrderHook) BeforeRelease(goroutine uint64, key string) {
+	h.record(goroutine, "release "+key)
+}
+
+func Test_LockOrderHook(t *testing.T) {
+	// Arrange
+	hook := &fakeOrderHook{}
+	km := NewHashedWithOptions(64, WithLockOrderHook(hook))
+
+	// Act
+	km.LockKey("a")
+	LockKeys(km, "b", "x")
+	TryLockKey(km, "y")
+	LockKeyWithContext(expiredContext(), km, "y")
+	km.UnlockKey("y")
+	UnlockKeys(km, "b", "x")
+	km.UnlockKey("a")
+
+	// Assert
+	want := []string{
+		"before a", "after a true",
+		// LockKeys locks in the order of the locks the keys hash to.
+		"before x", "after x true", "before b", "after b true",
+		"after y true",
+		"release y",
+		"release b", "release x",
+		"release a",
+	}
+	if !reflect.DeepEqual(hook.calls, want) {
+		t.Fatalf("Expected calls %v, got %v.", want, hook.calls)
+	}
+	if len(hook.goroutines) != 1 || hook.goroutines[0] {
+		t.Fatalf("Expected every call to name the calling goroutine, got %v.", hook.goroutines)
+	}
+}
+
+func Test_LockOrderHook_GiveUp(t *testing.T) {
+	// Arrange
+	hook := &fakeOrderHook{}
+	km := NewHashedWithOptions(1, WithLockOrderHook(hook))
+	km.LockKey("a")
+	ctx, cancel := context.WithCancel(context.Background())
+	callbackCh := make(chan interface{}, 1)
+	go func() {
+		callbackCh <- LockKeyWithContext(ctx, km, "b")
+	}()
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount("b") == 1 })
+
+	// Act
+	cancel()
+
+	// Assert
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey("a")
+	want := []string{"before a", "after a true", "before b", "after b false", "release a"}
+	if !reflect.DeepEqual(hook.calls, want) {
+		t.Fatalf("Expected calls %v, got %v.", want, hook.calls)
+	}
+	if len(hook.goroutines) != 2 {
+		t.Fatalf("Expected calls from 2 goroutines, got %v.", hook.goroutines)
+	}
+}
+
+// orderLogger prints the keys each goroutine acquires while holding others.
+type orderLogger struct {
+	lock sync.Mutex
+	held map[uint64][]string
+}
+
+func (l *orderLogger) BeforeAcquire(goroutine uint64, key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if held := l.held[goroutine]; len(held) > 0 {
+		fmt.Printf("locking %s after %v\n", key, held)
+	}
+}
+
+func (l *orderLogger) AfterAcquire(goroutine uint64, key string, acquired bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if acquired {
+		l.held[goroutine] = append(l.held[goroutine], key)
+	}
+}
+
+func (l *orderLogger) BeforeRelease(goroutine uint64, key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	held := l.held[goroutine]
+	for i := range held {
+		if held[i] == key {
+			l.held[goroutine] = append(held[:i], held[i+1:]...)
+			break
+		}
+	}
+}
+
+func ExampleWithLockOrderHook() {
+	km := NewHashedWithOptions(64, WithLockOrderHook(&orderLogger{held: make(map[uint64][]string)}))
+	km.LockKey("a")
+	km.LockKey("b")
+	km.UnlockKey("a")
+	km.LockKey("x")
+	km.UnlockKey("x")
+	km.UnlockKey("b")
+	// Output:
+	// locking b after [a]
+	// locking x after [b]
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// NewHashedReentrantSafe is like NewHashed, but records which goroutine holds
+// each lock so that a goroutine locking a key whose lock it already holds
+// panics with a message naming the key, rather than deadlocking silently.
+// TryLockKey simply returns false in that case.
+// This is a diagnostic aid: capturing the goroutine on every acquisition and
+// release, by formatting its stack trace, makes them take microseconds rather
+// than nanoseconds.
+func NewHashedReentrantSafe(n int) KeyMutex {
+	km := newHashed(n, nil)
+	km.owners = ownerPanicOnReentry
+	return km
+}
+
+// NewReentrantHashed is like NewHashed, but a goroutine may lock a key whose
+// lock it already holds. Each LockKey by the holding goroutine must be matched
+// by an UnlockKey, and the lock is only released by the last of them.
+// UnlockKey panics if the calling goroutine does not hold the lock. As with
+// NewHashedReentrantSafe, tracking the holder makes locking slower.
+// Since keys share locks, a goroutine holding one key may also lock other
+// keys hashing to the same lock without blocking.
+func NewReentrantHashed(n int) KeyMutex {
+	km := newHashed(n, nil)
+	km.owners = ownerReentrant
+	return km
+}
+
+// KeyTransferrer is implemented by KeyMutex instances whose held locks can be
+// handed over from the goroutine which locked them to another one, such as
+// those returned by NewHashed. This supports pipelines where one stage locks
+// a key and a later stage, running on another goroutine, unlocks it, even when
+// the KeyMutex tracks the goroutine holding each lock, as those returned by
+// NewHashedReentrantSafe and NewReentrantHashed do.
+type KeyTransferrer interface {
+	// Detaches the lock associated with the specified ID, which the caller
+	// holds, from the calling goroutine, so that any goroutine may unlock it
+	// without tripping owner checks. Until then, the calling goroutine is no
+	// longer treated as holding it, so locking it again blocks rather than
+	// panicking or re-entering. Panics if the calling goroutine doesn't hold
+	// the lock, or holds it more than once on a re-entrant KeyMutex.
+	TransferKey(id string)
+}
+
+// detachedOwner is the owner of a lock passed on by TransferKey, which
+// matches no goroutine.
+const detachedOwner = ^uint64(0)
+
+// Detaches the lock associated with the specified ID from the calling
+// goroutine.
+func (km *hashedKeyMutex) TransferKey(id string) {
+	id = km.normalized(id)
+	if km.owners == ownerUntracked {
+		if _, s := km.heldShard(id); s == nil {
+			panic(fmt.Sprintf("keymutex: transfer of unlocked key %q", id))
+		}
+		return
+	}
+	_, s := km.ownedShard(id)
+	if s == nil {
+		panic(fmt.Sprintf("keymutex: transfer of key %q by goroutine which does not hold it", id))
+	}
+	if km.owners == ownerReentrant && s.depth > 1 {
+		panic(fmt.Sprintf("keymutex: transfer of key %q held more than once", id))
+	}
+	s.setOwner(detachedOwner)
+}
+
+// ownerMode controls how a hashed KeyMutex tracks the goroutine holding each
+// of its locks.
+type ownerMode int
+
+const (
+	// ownerUntracked doesn't track holders, which is the fast default.
+	ownerUntracked ownerMode = iota
+	// ownerPanicOnReentry panics when a holder locks its own lock again.
+	ownerPanicOnReentry
+	// ownerPanicOnDeadlock panics when a holder locks its own lock again in
+	// a way which can't give up, so that it would otherwise wait forever.
+	ownerPanicOnDeadlock
+	// ownerReentrant lets a holder lock its own lock again.
+	ownerReentrant
+)
+
+// checkReentrant panics if the calling goroutine already holds the lock id
+// hashes to, naming the key it holds the lock for if that is another key
+// sharing the lock.
+func (km *hashedKeyMutex) checkReentrant(id string) {
+	_, s := km.ownedShard(id)
+	if s == nil {
+		return
+	}
+	// The caller holds s, so it wrote the holder itself.
+	if held := string(s.holder); held != id {
+		panic(fmt.Sprintf("keymutex: lock on key %q while holding key %q, which shares its lock", id, held))
+	}
+	panic(fmt.Sprintf("keymutex: re-entrant lock on key %q", id))
+}
+
+// reenter increments the hold depth of the lock id hashes to if the calling
+// goroutine holds it, returning false otherwise.
+func (km *hashedKeyMutex) reenter(id string) bool {
+	_, s := km.ownedShard(id)
+	if s == nil {
+		return false
+	}
+	s.depth++
+	return true
+}
+
+// ownedShard returns the lock id hashes to which the calling goroutine holds,
+// and the generation it belongs to, or nil if there is none. Older
+// generations are searched first, so that keys locked before a resize keep
+// resolving to the same lock until they are unlocked.
+func (km *hashedKeyMutex) ownedShard(id string) (*generation, *shard) {
+	goroutine := goroutineID()
+	var buf [4]*generation
+	for _, g := range km.generations(buf[:0]) {
+		if s := km.shardOf(g, id); atomic.LoadUint64(&s.owner) == goroutine {
+			return g, s
+		}
+	}
+	return nil, nil
+}
+
+func (s *shard) setOwner(goroutine uint64) {
+	atomic.StoreUint64(&s.owner, goroutine)
+}
+
+// exit decrements the hold depth of s, returning true once the calling
+// goroutine no longer holds it and it must be unlocked. It panics if the
+// caller doesn't hold s and s wasn't detached by TransferKey.
+func (s *shard) exit(id string) bool {
+	if owner := atomic.LoadUint64(&s.owner); owner != goroutineID() && owner != detachedOwner {
+		panic(fmt.Sprintf("keymutex: unlock of key %q by goroutine which does not hold it", id))
+	}
+	s.depth--
+	if s.depth > 0 {
+		return false
+	}
+	s.setOwner(0)
+	return true
+}
+
+// goroutineID returns the ID of the calling goroutine, as printed in its stack
+// trace. The runtime deliberately doesn't expose it, so this is only meant for
+// diagnostics: formatting the trace takes microseconds, which each lock and
+// unlock of a KeyMutex tracking the goroutine holding its locks pays.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	return stackGoroutineID(buf[:n])
+}
+
+// stackGoroutineID returns the ID of the goroutine whose stack trace is
+// stack, or 0 if it can't be parsed.
+func stackGoroutineID(stack []byte) uint64 {
+	// The trace starts with "goroutine 123 [running]:".
+	b := bytes.TrimPrefix(stack, []byte("goroutine "))
+	var id uint64
+	for i, c := range b {
+		if c == ' ' && i > 0 {
+			return id
+		}
+		if c < '0' || c > '9' {
+			return 0
+		}
+		id = id*10 + uint64(c-'0')
+	}
+	return 0
+}
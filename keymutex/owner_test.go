@@ -0,0 +1,275 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_ReentrantSafe_Panics(t *testing.T) {
+	// Arrange
+	km := NewHashedReentrantSafe(4)
+	key := "fakeid"
+	km.LockKey(key)
+	defer km.UnlockKey(key)
+
+	// Act
+	recovered := recoverPanic(func() {
+		km.LockKey(key)
+	})
+
+	// Assert
+	expected := fmt.Sprintf("keymutex: re-entrant lock on key %q", key)
+	if recovered != expected {
+		t.Errorf("Expected panic %q, got %v.", expected, recovered)
+	}
+	if TryLockKey(km, key) {
+		t.Errorf("Expected TryLockKey to fail on a key held by the caller.")
+	}
+}
+
+func Test_ReentrantSafe_SharedLock(t *testing.T) {
+	// Arrange
+	km := NewHashedReentrantSafe(1)
+	km.LockKey("a")
+	defer km.UnlockKey("a")
+
+	// Act
+	recovered := recoverPanic(func() {
+		km.LockKey("b")
+	})
+
+	// Assert
+	expected := `keymutex: lock on key "b" while holding key "a", which shares its lock`
+	if recovered != expected {
+		t.Errorf("Expected panic %q, got %v.", expected, recovered)
+	}
+}
+
+func Test_StackGoroutineID(t *testing.T) {
+	for _, tc := range []struct {
+		stack    string
+		expected uint64
+	}{
+		{"goroutine 123 [running]:\nmain.main()", 123},
+		{"goroutine 7 [running]:", 7},
+		{"goroutine  [running]:", 0},
+		{"goroutine 12x [running]:", 0},
+		{"thread 5 [running]:", 0},
+	} {
+		// Act
+		id := stackGoroutineID([]byte(tc.stack))
+
+		// Assert
+		if id != tc.expected {
+			t.Errorf("Expected ID %d for stack %q, got %d.", tc.expected, tc.stack, id)
+		}
+	}
+}
+
+func Test_ReentrantSafe_LockKeys(t *testing.T) {
+	// Arrange
+	km := NewHashedReentrantSafe(1)
+	LockKeys(km, "a", "b")
+	UnlockKeys(km, "a", "b")
+
+	// Act
+	recovered := recoverPanic(func() {
+		km.LockKey("a")
+	})
+
+	// Assert
+	if recovered != nil {
+		t.Fatalf("Expected UnlockKeys to release the caller's hold, got panic %v.", recovered)
+	}
+	km.UnlockKey("a")
+}
+
+func Test_ReentrantSafe_OtherGoroutineBlocks(t *testing.T) {
+	// Arrange
+	km := NewHashedReentrantSafe(4)
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+
+	// Act & Assert
+	km.LockKey(key)
+	go lockAndCallback(km, key, callbackCh)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+	km.LockKey(key)
+	km.UnlockKey(key)
+}
+
+func Test_Reentrant_ReleasedAfterMatchingUnlocks(t *testing.T) {
+	// Arrange
+	km := NewReentrantHashed(4)
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+
+	// Act & Assert
+	km.LockKey(key)
+	if !TryLockKey(km, key) {
+		t.Fatalf("Expected TryLockKey to reenter a key held by the caller.")
+	}
+	LockKeys(km, key)
+	go lockAndCallback(km, key, callbackCh)
+	UnlockKeys(km, key)
+	km.UnlockKey(key)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+}
+
+func Test_Reentrant_UnlockByOtherGoroutinePanics(t *testing.T) {
+	// Arrange
+	km := NewReentrantHashed(4)
+	key := "fakeid"
+	km.LockKey(key)
+	recoveredCh := make(chan interface{})
+
+	// Act
+	go func() {
+		defer func() {
+			recoveredCh <- recover()
+		}()
+		km.UnlockKey(key)
+	}()
+
+	// Assert
+	expected := fmt.Sprintf("keymutex: unlock of key %q by goroutine which does not hold it", key)
+	if recovered := <-recoveredCh; recovered != expected {
+		t.Errorf("Expected panic %q, got %v.", expected, recovered)
+	}
+	km.UnlockKey(key)
+	if !TryLockKey(km, key) {
+		t.Errorf("Expected %q to be free after the holder unlocked it.", key)
+	}
+}
+
+func Test_TransferKey(t *testing.T) {
+	for _, km := range []KeyMutex{NewHashed(4), NewHashedReentrantSafe(4), NewReentrantHashed(4)} {
+		// Arrange
+		key := "fakeid"
+		callbackCh := make(chan interface{})
+		km.LockKey(key)
+
+		// Act
+		km.(KeyTransferrer).TransferKey(key)
+		go func() {
+			defer func() {
+				callbackCh <- recover()
+			}()
+			km.UnlockKey(key)
+		}()
+
+		// Assert
+		if recovered := <-callbackCh; recovered != nil {
+			t.Fatalf("Expected another goroutine to unlock a transferred key of %T, got panic %v.", km, recovered)
+		}
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected %q to be free after the transferred lock was unlocked.", key)
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_TransferKey_LockAgainBlocks(t *testing.T) {
+	for _, km := range []KeyMutex{NewHashedReentrantSafe(4), NewReentrantHashed(4)} {
+		// Arrange
+		key := "fakeid"
+		callbackCh := make(chan interface{})
+		km.LockKey(key)
+		km.(KeyTransferrer).TransferKey(key)
+
+		// Act
+		go func() {
+			km.LockKey(key)
+			km.(KeyTransferrer).TransferKey(key)
+			callbackCh <- true
+		}()
+
+		// Assert
+		verifyCallbackDoesntHappens(t, callbackCh)
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackCh)
+		km.UnlockKey(key)
+	}
+}
+
+func Test_TransferKey_NotHeld(t *testing.T) {
+	for _, km := range []KeyMutex{NewHashed(4), NewHashedReentrantSafe(4), NewReentrantHashed(4)} {
+		// Arrange
+		key := "fakeid"
+
+		// Act
+		recovered := recoverPanic(func() {
+			km.(KeyTransferrer).TransferKey(key)
+		})
+
+		// Assert
+		if recovered == nil {
+			t.Fatalf("Expected TransferKey of %T to panic for a key which isn't held.", km)
+		}
+	}
+}
+
+func Test_TransferKey_ReentrantHeldTwice(t *testing.T) {
+	// Arrange
+	km := NewReentrantHashed(4)
+	key := "fakeid"
+	km.LockKey(key)
+	km.LockKey(key)
+
+	// Act
+	recovered := recoverPanic(func() {
+		km.(KeyTransferrer).TransferKey(key)
+	})
+
+	// Assert
+	expected := fmt.Sprintf("keymutex: transfer of key %q held more than once", key)
+	if recovered != expected {
+		t.Errorf("Expected panic %q, got %v.", expected, recovered)
+	}
+	km.UnlockKey(key)
+	km.UnlockKey(key)
+}
+
+func Test_GoroutineID(t *testing.T) {
+	// Arrange
+	idCh := make(chan uint64)
+
+	// Act
+	id := goroutineID()
+	go func() {
+		idCh <- goroutineID()
+	}()
+	other := <-idCh
+
+	// Assert
+	if id == 0 || other == 0 {
+		t.Fatalf("Expected non-zero goroutine IDs, got %d and %d.", id, other)
+	}
+	if id != goroutineID() {
+		t.Errorf("Expected goroutineID to be stable within a goroutine.")
+	}
+	if id == other {
+		t.Errorf("Expected distinct goroutines to have distinct IDs.")
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"unsafe"
+)
+
+// cacheLineSize is the size of a CPU cache line on common platforms.
+const cacheLineSize = 64
+
+// NewHashedPadded is like NewHashed, but pads each lock to cache lines of its
+// own, so that goroutines locking keys which hash to adjacent locks on
+// different cores don't keep invalidating each other's caches. This trades
+// memory for throughput under heavy contention from many cores.
+func NewHashedPadded(n int) KeyMutex {
+	return NewHashedWithOptions(n, WithPadding())
+}
+
+// WithPadding pads each lock, along with the state and counters kept for it,
+// to cache lines of its own, as NewHashedPadded does. It doesn't change the
+// kind of lock used: combined with WithFairness, the fair locks are padded
+// too.
+func WithPadding() Option {
+	return func(km *hashedKeyMutex) {
+		km.padded = true
+		km.rebuild()
+	}
+}
+
+// paddedShard is a shard padded to a multiple of the cache line size, so that
+// adjacent shards in a slice never share a cache line.
+type paddedShard struct {
+	shard
+	_ [cacheLineSize - unsafe.Sizeof(shard{})%cacheLineSize]byte
+}
+
+// newPaddedShards returns n new shards which are cache line padded.
+func newPaddedShards(n int) []*shard {
+	padded := make([]paddedShard, n)
+	shards := make([]*shard, n)
+	for i := range padded {
+		padded[i].index = i
+		shards[i] = &padded[i].shard
+	}
+	return shards
+}
+
+// paddedFairMutex is a fairMutex padded to fill a whole cache line, so that
+// adjacent locks in a slice never share one.
+type paddedFairMutex struct {
+	fairMutex
+	_ [cacheLineSize - unsafe.Sizeof(fairMutex{})%cacheLineSize]byte
+}
+
+// newPaddedFairMutexes returns n fairMutexes which are cache line padded.
+func newPaddedFairMutexes(n int) []*fairMutex {
+	padded := make([]paddedFairMutex, n)
+	mutexes := make([]*fairMutex, n)
+	for i := range padded {
+		mutexes[i] = &padded[i].fairMutex
+	}
+	return mutexes
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+func Test_HashedPadded_Layout(t *testing.T) {
+	// Arrange
+	km := NewHashedPadded(4).(*hashedKeyMutex)
+	shards := km.current().shards
+
+	// Act & Assert
+	for i := 1; i < len(shards); i++ {
+		distance := uintptr(unsafe.Pointer(shards[i])) - uintptr(unsafe.Pointer(shards[i-1]))
+		if distance < cacheLineSize || distance%cacheLineSize != 0 {
+			t.Fatalf("Expected shards %d and %d to be whole cache lines apart, got %d bytes.", i-1, i, distance)
+		}
+		if shards[i].locker != nil {
+			t.Fatalf("Expected shard %d to keep the default lock, got %T.", i, shards[i].locker)
+		}
+	}
+}
+
+func Test_HashedPadded_Fair(t *testing.T) {
+	// Arrange
+	km := NewHashedWithOptions(4, WithFairness(), WithPadding()).(*hashedKeyMutex)
+	shards := km.current().shards
+
+	// Act & Assert
+	for i := 1; i < len(shards); i++ {
+		prev, _ := shards[i-1].locker.(*fairMutex)
+		m, ok := shards[i].locker.(*fairMutex)
+		if !ok {
+			t.Fatalf("Expected shard %d to use a fair lock.", i)
+		}
+		distance := uintptr(unsafe.Pointer(m)) - uintptr(unsafe.Pointer(prev))
+		if distance < cacheLineSize {
+			t.Fatalf("Expected locks %d and %d to be at least a cache line apart, got %d bytes.", i-1, i, distance)
+		}
+	}
+}
+
+func Test_HashedPadded_Resize(t *testing.T) {
+	// Arrange
+	km := NewHashedPadded(2)
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+	km.LockKey(key)
+
+	// Act
+	km.(Resizer).Resize(8)
+
+	// Assert
+	go lockAndCallback(km, key, callbackCh)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+	shards := km.(*hashedKeyMutex).current().shards
+	distance := uintptr(unsafe.Pointer(shards[1])) - uintptr(unsafe.Pointer(shards[0]))
+	if distance%cacheLineSize != 0 {
+		t.Fatalf("Expected resized shards to be padded, got %d bytes apart.", distance)
+	}
+}
+
+// benchmarkDistinctShards has each goroutine lock a key of its own, on a
+// lock of its own, so that goroutines only interfere through the memory
+// their locks share. Run it with e.g. -cpu=8 to compare layouts.
+func benchmarkDistinctShards(b *testing.B, km KeyMutex) {
+	indexer := km.(ShardIndexer)
+	n := km.(ShardCounter).ShardCount()
+	keys := make([]string, n)
+	for found, i := 0, 0; found < n; i++ {
+		key := fmt.Sprint(i)
+		if index := indexer.ShardIndex(key); keys[index] == "" {
+			keys[index] = key
+			found++
+		}
+	}
+	var next int32
+	b.RunParallel(func(pb *testing.PB) {
+		key := keys[int(atomic.AddInt32(&next, 1)-1)%n]
+		for pb.Next() {
+			km.LockKey(key)
+			km.UnlockKey(key)
+		}
+	})
+}
+
+func BenchmarkHashed_DistinctShards(b *testing.B) {
+	benchmarkDistinctShards(b, NewHashed(64))
+}
+
+func BenchmarkHashedPadded_DistinctShards(b *testing.B) {
+	benchmarkDistinctShards(b, NewHashedPadded(64))
+}
@@ -0,0 +1,398 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NewPerKey returns a new instance of KeyMutex which allocates a separate
+// lock for every key, so unrelated keys never wait on each other.
+// The lock for a key is allocated when it is first needed and freed once no
+// goroutine holds or waits for it, so memory use is bounded by the number of
+// keys in use rather than the number of keys ever seen.
+func NewPerKey() KeyMutex {
+	return NewPerKeyBounded(0)
+}
+
+// NewPerKeyBounded is like NewPerKey, but at most maxActive keys are held or
+// waited for at once, so that a flood of distinct keys can't exhaust memory.
+// Acquiring a key which is not held or waited for already waits until fewer
+// than maxActive keys are, and TryLockKey fails instead; keys which are
+// already held or waited for are always admitted, so the work in progress
+// for them can finish. Waits which can give up, such as LockKeyWithContext,
+// also give up waiting for admission, although CancelWaiters doesn't affect
+// it. LockKeys waits until all of its keys can be admitted at once, and
+// panics if they are more than maxActive. If maxActive <= 0, the number of
+// keys is not limited.
+func NewPerKeyBounded(maxActive int) KeyMutex {
+	return &perKeyMutex{
+		entries:   make(map[string]*perKeyEntry),
+		maxActive: maxActive,
+	}
+}
+
+var (
+	_ KeyMutex         = (*perKeyMutex)(nil)
+	_ TryLocker        = (*perKeyMutex)(nil)
+	_ ContextLocker    = (*perKeyMutex)(nil)
+	_ TimeoutLocker    = (*perKeyMutex)(nil)
+	_ LockInspector    = (*perKeyMutex)(nil)
+	_ WaiterCanceller  = (*perKeyMutex)(nil)
+	_ Resetter         = (*perKeyMutex)(nil)
+	_ Warmer           = (*perKeyMutex)(nil)
+	_ BatchLocker      = (*perKeyMutex)(nil)
+	_ ContextErrLocker = (*perKeyMutex)(nil)
+	_ StopLocker       = (*perKeyMutex)(nil)
+)
+
+type perKeyMutex struct {
+	lock    sync.Mutex
+	entries map[string]*perKeyEntry
+	// maxActive, if positive, bounds the number of entries.
+	maxActive int
+	// released, if set, is closed when an entry is removed or becomes
+	// evictable, to wake the goroutines waiting to admit a new key. It is
+	// guarded by lock.
+	released chan struct{}
+}
+
+type perKeyEntry struct {
+	mutex trackedMutex
+	// refs counts the goroutines holding or waiting for mutex. It is guarded
+	// by perKeyMutex.lock, and the entry is removed when it drops to zero.
+	refs int
+	// cancel, if set, is closed by CancelWaiters to abort the current
+	// cancellable waits for mutex. It is guarded by perKeyMutex.lock.
+	cancel chan struct{}
+	// warm is set if the entry was created by Warm, in which case it is kept
+	// while unused until it has to make room for another key. It is guarded
+	// by perKeyMutex.lock.
+	warm bool
+}
+
+// Acquires a lock associated with the specified ID.
+func (km *perKeyMutex) LockKey(id string) {
+	e, _ := km.ref(id, nil)
+	e.mutex.lock()
+}
+
+// Attempts to acquire the lock associated with the specified ID without blocking.
+func (km *perKeyMutex) TryLockKey(id string) bool {
+	e, ok := km.tryRef(id)
+	if !ok {
+		return false
+	}
+	if e.mutex.tryLock() {
+		return true
+	}
+	km.unref(id, e)
+	return false
+}
+
+// Acquires a lock associated with the specified ID, giving up when ctx is done.
+func (km *perKeyMutex) LockKeyWithContext(ctx context.Context, id string) bool {
+	return km.LockKeyWithContextErr(ctx, id) == nil
+}
+
+// Acquires a lock associated with the specified ID, returning ctx.Err() if
+// ctx is done first.
+func (km *perKeyMutex) LockKeyWithContextErr(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e, cancel, ok := km.refCancellable(id, ctx.Done())
+	if !ok {
+		return ctx.Err()
+	}
+	if e.mutex.lockOrAbort(ctx.Done(), cancel) {
+		return nil
+	}
+	km.unref(id, e)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrWaitCancelled
+}
+
+// Acquires a lock associated with the specified ID, giving up when stop is
+// closed.
+func (km *perKeyMutex) LockKeyWithStop(id string, stop <-chan struct{}) bool {
+	if isStopped(stop) {
+		return false
+	}
+	e, cancel, ok := km.refCancellable(id, stop)
+	if !ok {
+		return false
+	}
+	if e.mutex.lockOrAbort(stop, cancel) {
+		return true
+	}
+	km.unref(id, e)
+	return false
+}
+
+// Acquires a lock associated with the specified ID, giving up after d.
+func (km *perKeyMutex) LockKeyWithTimeout(id string, d time.Duration) bool {
+	return lockKeyWithTimeout[string](km, id, d)
+}
+
+// Releases the lock associated with the specified ID.
+// Returns an error if the specified ID is not locked.
+func (km *perKeyMutex) UnlockKey(id string) error {
+	km.lock.Lock()
+	e, ok := km.entries[id]
+	km.lock.Unlock()
+	if !ok || !e.mutex.tryUnlock() {
+		return fmt.Errorf("keymutex: unlock of unlocked key %q", id)
+	}
+	km.unref(id, e)
+	return nil
+}
+
+// Acquires the locks associated with all of the specified IDs, in sorted
+// order.
+func (km *perKeyMutex) LockKeys(ids ...string) {
+	for _, e := range km.refAll(sortedUnique(ids)) {
+		e.mutex.lock()
+	}
+}
+
+// Releases the locks associated with all of the specified IDs.
+// Every ID is released even if some are not locked; the first such error is
+// returned.
+func (km *perKeyMutex) UnlockKeys(ids ...string) error {
+	var firstErr error
+	for _, id := range sortedUnique(ids) {
+		if err := km.UnlockKey(id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Returns the KeyMutex to its initial state. Since unused locks are freed
+// anyway, this only drops the locks kept by Warm, and checks that no key is
+// held or waited for, panicking otherwise.
+func (km *perKeyMutex) Reset() {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	km.evictLocked(len(km.entries))
+	if len(km.entries) > 0 {
+		panic(fmt.Sprintf("keymutex: reset with %d keys in use", len(km.entries)))
+	}
+}
+
+// Allocates the locks associated with the specified IDs ahead of time, so that
+// acquiring them for the first time doesn't allocate. A lock allocated by Warm
+// is kept while no goroutine holds or waits for it, until a new key has to
+// be admitted in its place under NewPerKeyBounded, or Reset is called.
+func (km *perKeyMutex) Warm(ids ...string) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	for _, id := range ids {
+		if e, ok := km.entries[id]; ok {
+			e.warm = true
+			continue
+		}
+		km.entries[id] = &perKeyEntry{warm: true}
+	}
+}
+
+// Makes the goroutines currently waiting for the lock associated with the
+// specified ID in a context-aware method give up.
+func (km *perKeyMutex) CancelWaiters(id string) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	if e, ok := km.entries[id]; ok && e.cancel != nil {
+		close(e.cancel)
+		e.cancel = nil
+	}
+}
+
+// Reports whether the lock associated with the specified ID is held.
+func (km *perKeyMutex) IsLocked(id string) bool {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	e, ok := km.entries[id]
+	return ok && e.mutex.locked()
+}
+
+// Returns the number of goroutines waiting for the lock associated with the
+// specified ID.
+func (km *perKeyMutex) WaitersCount(id string) int {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	e, ok := km.entries[id]
+	if !ok {
+		return 0
+	}
+	// Every reference which doesn't hold the lock is waiting for it.
+	waiters := e.refs
+	if e.mutex.locked() {
+		waiters--
+	}
+	if waiters < 0 {
+		return 0
+	}
+	return waiters
+}
+
+// ref returns the entry for id, creating it if needed, and takes a reference
+// on it. The reference is taken under km.lock, so the entry cannot be removed
+// between looking it up and waiting for its mutex. If the entry has to be
+// created, it waits until the key can be admitted, returning false if done is
+// closed first.
+func (km *perKeyMutex) ref(id string, done <-chan struct{}) (*perKeyEntry, bool) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	ids := [1]string{id}
+	if !km.admitLocked(ids[:], done) {
+		return nil, false
+	}
+	return km.refLocked(id), true
+}
+
+// tryRef is like ref, but fails instead of waiting to admit id.
+func (km *perKeyMutex) tryRef(id string) (*perKeyEntry, bool) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	if _, ok := km.entries[id]; !ok && km.maxActive > 0 && len(km.entries) >= km.maxActive {
+		km.evictLocked(len(km.entries) - km.maxActive + 1)
+		if len(km.entries) >= km.maxActive {
+			return nil, false
+		}
+	}
+	return km.refLocked(id), true
+}
+
+// refCancellable is like ref, but also returns a channel which CancelWaiters
+// closes to abort waiting for the entry.
+func (km *perKeyMutex) refCancellable(id string, done <-chan struct{}) (*perKeyEntry, <-chan struct{}, bool) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	ids := [1]string{id}
+	if !km.admitLocked(ids[:], done) {
+		return nil, nil, false
+	}
+	e := km.refLocked(id)
+	if e.cancel == nil {
+		e.cancel = make(chan struct{})
+	}
+	return e, e.cancel, true
+}
+
+// refAll is like ref for all of ids, admitting them all at once.
+func (km *perKeyMutex) refAll(ids []string) []*perKeyEntry {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	if km.maxActive > 0 && len(ids) > km.maxActive {
+		panic(fmt.Sprintf("keymutex: LockKeys of %d keys exceeds the limit of %d active keys", len(ids), km.maxActive))
+	}
+	km.admitLocked(ids, nil)
+	entries := make([]*perKeyEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = km.refLocked(id)
+	}
+	return entries
+}
+
+// admitLocked waits until the entries for ids which don't exist yet can be
+// created without exceeding maxActive, giving up once done is closed. km.lock
+// must be held, and is released while waiting.
+func (km *perKeyMutex) admitLocked(ids []string, done <-chan struct{}) bool {
+	for km.maxActive > 0 && len(km.entries)+km.freshLocked(ids) > km.maxActive {
+		if km.evictLocked(len(km.entries) + km.freshLocked(ids) - km.maxActive) {
+			continue
+		}
+		if km.released == nil {
+			km.released = make(chan struct{})
+		}
+		released := km.released
+		km.lock.Unlock()
+		select {
+		case <-released:
+		case <-done:
+			km.lock.Lock()
+			return false
+		}
+		km.lock.Lock()
+	}
+	return true
+}
+
+// evictLocked removes up to n of the entries kept by Warm which are unused,
+// reporting whether it removed any. km.lock must be held.
+func (km *perKeyMutex) evictLocked(n int) bool {
+	evicted := 0
+	for id, e := range km.entries {
+		if evicted == n {
+			break
+		}
+		if e.warm && e.refs == 0 {
+			delete(km.entries, id)
+			evicted++
+		}
+	}
+	return evicted > 0
+}
+
+// freshLocked returns the number of ids which have no entry. km.lock must be
+// held.
+func (km *perKeyMutex) freshLocked(ids []string) int {
+	fresh := 0
+	for _, id := range ids {
+		if _, ok := km.entries[id]; !ok {
+			fresh++
+		}
+	}
+	return fresh
+}
+
+// refLocked is ref for callers which hold km.lock.
+func (km *perKeyMutex) refLocked(id string) *perKeyEntry {
+	e, ok := km.entries[id]
+	if !ok {
+		e = &perKeyEntry{}
+		km.entries[id] = e
+	}
+	e.refs++
+	return e
+}
+
+// unref drops a reference taken by ref, removing the entry once it is unused
+// unless it was created by Warm.
+func (km *perKeyMutex) unref(id string, e *perKeyEntry) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	e.refs--
+	if e.refs > 0 {
+		return
+	}
+	if !e.warm {
+		delete(km.entries, id)
+	}
+	// A warm entry which is unused can be evicted to admit another key, so
+	// the goroutines waiting for admission have to check again either way.
+	if km.released != nil {
+		close(km.released)
+		km.released = nil
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_PerKey_Churn(t *testing.T) {
+	// Arrange
+	km := NewPerKey().(*perKeyMutex)
+	const goroutines, iterations = 16, 200
+	keys := []string{"a", "b"}
+	counters := make([]int, len(keys))
+	var wg sync.WaitGroup
+
+	// Act
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				k := (g + i) % len(keys)
+				key := keys[k]
+				switch i % 3 {
+				case 0:
+					km.LockKey(key)
+				case 1:
+					if !km.LockKeyWithContext(context.Background(), key) {
+						t.Errorf("Expected LockKeyWithContext to acquire %q.", key)
+						return
+					}
+				case 2:
+					for !km.TryLockKey(key) {
+						time.Sleep(time.Microsecond)
+					}
+				}
+				counters[k]++
+				if err := km.UnlockKey(key); err != nil {
+					t.Errorf("Unexpected error unlocking %q: %v", key, err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Assert
+	if total := counters[0] + counters[1]; total != goroutines*iterations {
+		t.Errorf("Expected %d critical sections, got %d.", goroutines*iterations, total)
+	}
+	if n := len(km.entries); n != 0 {
+		t.Errorf("Expected all entries to be freed, %d remain.", n)
+	}
+}
+
+func Test_PerKey_FreesOnContextCancel(t *testing.T) {
+	// Arrange
+	km := NewPerKey().(*perKeyMutex)
+	key := "fakeid"
+	km.LockKey(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act
+	if km.LockKeyWithContext(ctx, key) {
+		t.Fatalf("Expected LockKeyWithContext to give up on a held key.")
+	}
+	if km.TryLockKey(key) {
+		t.Fatalf("Expected TryLockKey to fail on a held key.")
+	}
+	km.UnlockKey(key)
+
+	// Assert
+	if n := len(km.entries); n != 0 {
+		t.Errorf("Expected all entries to be freed, %d remain.", n)
+	}
+}
+
+func Test_PerKey_UnlockUnlocked(t *testing.T) {
+	// Arrange
+	km := NewPerKey()
+	key := "fakeid"
+
+	// Act
+	err := km.UnlockKey(key)
+
+	// Assert
+	expected := fmt.Sprintf("keymutex: unlock of unlocked key %q", key)
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v.", expected, err)
+	}
+}
+
+func Test_PerKey_Reset(t *testing.T) {
+	// Arrange
+	km := NewPerKey()
+	key := "fakeid"
+	km.LockKey(key)
+
+	// Act & Assert
+	if recovered := recoverPanic(km.(Resetter).Reset); recovered == nil {
+		t.Fatalf("Expected Reset to panic while a key is held.")
+	}
+	km.UnlockKey(key)
+	km.(Resetter).Reset()
+	if !TryLockKey(km, key) {
+		t.Fatalf("Expected the key to be free after Reset.")
+	}
+	km.UnlockKey(key)
+}
+
+func Test_PerKey_Warm(t *testing.T) {
+	// Arrange
+	km := NewPerKey()
+	keys := []string{"a", "b"}
+
+	// Act
+	km.(Warmer).Warm(keys...)
+
+	// Assert
+	for _, key := range keys {
+		allocs := testing.AllocsPerRun(100, func() {
+			km.LockKey(key)
+			km.UnlockKey(key)
+		})
+		if allocs != 0 {
+			t.Fatalf("Expected locking warmed key %q not to allocate, got %v allocations.", key, allocs)
+		}
+	}
+	if n := len(km.(*perKeyMutex).entries); n != len(keys) {
+		t.Fatalf("Expected the warmed entries to be kept while unused, got %d.", n)
+	}
+	km.(Resetter).Reset()
+	if n := len(km.(*perKeyMutex).entries); n != 0 {
+		t.Fatalf("Expected Reset to free the warmed entries, %d remain.", n)
+	}
+}
+
+func Test_PerKeyBounded_EvictsWarm(t *testing.T) {
+	// Arrange
+	km := NewPerKeyBounded(2)
+	km.(Warmer).Warm("a", "b")
+	km.LockKey("a")
+
+	// Act
+	acquired := TryLockKey(km, "c")
+
+	// Assert
+	if !acquired {
+		t.Fatalf("Expected an unused warmed key to make room for a new key.")
+	}
+	if TryLockKey(km, "d") {
+		t.Fatalf("Expected a held warmed key not to be evicted.")
+	}
+	km.UnlockKey("a")
+	km.UnlockKey("c")
+	km.(Resetter).Reset()
+}
+
+func Test_PerKeyBounded_WaiterEvictsReleasedWarm(t *testing.T) {
+	// Arrange
+	km := NewPerKeyBounded(1)
+	km.(Warmer).Warm("a")
+	km.LockKey("a")
+	callbackCh := make(chan interface{})
+
+	// Act
+	go lockAndCallback(km, "b", callbackCh)
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	km.UnlockKey("a")
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey("b")
+}
+
+func Test_PerKeyBounded(t *testing.T) {
+	// Arrange
+	km := NewPerKeyBounded(2)
+	newCh := make(chan interface{}, 1)
+	knownCh := make(chan interface{}, 1)
+	km.LockKey("a")
+	km.LockKey("b")
+
+	// Act
+	go lockAndCallback(km, "c", newCh)
+	go lockAndCallback(km, "a", knownCh)
+	verifyEventually(t, func() bool { return km.(LockInspector).WaitersCount("a") == 1 })
+
+	// Assert
+	verifyCallbackDoesntHappens(t, newCh)
+	if TryLockKey(km, "d") {
+		t.Fatalf("Expected TryLockKey of a new key to fail while the maximum number of keys is active.")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := LockKeyWithContextErr(ctx, km, "d"); err != context.DeadlineExceeded {
+		t.Fatalf("Expected the wait for admission to give up with context.DeadlineExceeded, got %v.", err)
+	}
+	km.UnlockKey("a")
+	verifyCallbackHappens(t, knownCh)
+	verifyCallbackDoesntHappens(t, newCh)
+	km.UnlockKey("b")
+	verifyCallbackHappens(t, newCh)
+	km.UnlockKey("a")
+	km.UnlockKey("c")
+	km.(Resetter).Reset()
+}
+
+func Test_PerKeyBounded_LockKeys(t *testing.T) {
+	// Arrange
+	km := NewPerKeyBounded(3)
+	callbackCh := make(chan interface{}, 1)
+	km.LockKey("a")
+	km.LockKey("b")
+
+	// Act
+	go func() {
+		LockKeys(km, "a", "x", "y")
+		callbackCh <- true
+	}()
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	if km.(LockInspector).IsLocked("x") {
+		t.Fatalf("Expected LockKeys not to take any new key until all are admitted.")
+	}
+	km.UnlockKey("b")
+	km.UnlockKey("a")
+	verifyCallbackHappens(t, callbackCh)
+	if recoverPanic(func() { LockKeys(km, "1", "2", "3", "4") }) == nil {
+		t.Fatalf("Expected LockKeys of more keys than can be active to panic.")
+	}
+	UnlockKeys(km, "a", "x", "y")
+	km.(Resetter).Reset()
+}
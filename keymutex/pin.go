@@ -0,0 +1,76 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+)
+
+// NewHashedWithPinning is like NewHashed, but each key in pinned is locked on
+// the lock with the index it maps to, and all other keys hash onto the locks
+// no key is pinned to, so that known hot keys don't contend with the rest.
+// Several keys may be pinned to the same lock. If every lock has a key pinned
+// to it, the other keys hash onto all of them. Returns an error if any index
+// is not between 0 and the number of locks, which is chosen as NewHashed does
+// if n <= 0. pinned is copied, so later changes to it have no effect.
+//
+// After a Resize, keys pinned to indexes beyond the new number of locks hash
+// like any other key.
+func NewHashedWithPinning(n int, pinned map[string]int) (KeyMutex, error) {
+	if n <= 0 {
+		n = 1
+	}
+	km := newHashed(n, nil)
+	km.pinned = make(map[string]int, len(pinned))
+	for key, index := range pinned {
+		if index < 0 || index >= n {
+			return nil, fmt.Errorf("keymutex: key %q pinned to lock %d, must be between 0 and %d", key, index, n-1)
+		}
+		km.pinned[key] = index
+	}
+	km.rebuild()
+	return km, nil
+}
+
+// unpinnedShards returns the indexes of the n locks which no key is pinned to.
+func (km *hashedKeyMutex) unpinnedShards(n int) []int {
+	isPinned := make([]bool, n)
+	for _, index := range km.pinned {
+		if index < n {
+			isPinned[index] = true
+		}
+	}
+	var unpinned []int
+	for i := range isPinned {
+		if !isPinned[i] {
+			unpinned = append(unpinned, i)
+		}
+	}
+	return unpinned
+}
+
+// pinnedShardIndex returns the index of the lock of g which id maps to if
+// keys are pinned.
+func (km *hashedKeyMutex) pinnedShardIndex(g *generation, id string) int {
+	if index, ok := km.pinned[id]; ok && index < len(g.shards) {
+		return index
+	}
+	if len(g.unpinned) == 0 {
+		return int(km.hasher(id) % uint32(len(g.shards)))
+	}
+	return g.unpinned[km.hasher(id)%uint32(len(g.unpinned))]
+}
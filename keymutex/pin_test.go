@@ -0,0 +1,76 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_HashedWithPinning_PinnedKeys(t *testing.T) {
+	// Arrange
+	pinned := map[string]int{"hot": 0, "warm": 3}
+	km, err := NewHashedWithPinning(4, pinned)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewHashedWithPinning: %v", err)
+	}
+	indexer := km.(ShardIndexer)
+
+	// Act & Assert
+	for key, index := range pinned {
+		if got := indexer.ShardIndex(key); got != index {
+			t.Fatalf("Expected pinned key %q on lock %d, got %d.", key, index, got)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if index := indexer.ShardIndex(key); index == 0 || index == 3 {
+			t.Fatalf("Expected key %q not to share lock %d with a pinned key.", key, index)
+		}
+	}
+	km.LockKey("hot")
+	if !TryLockKey(km, "key0") {
+		t.Fatalf("Expected other keys not to contend with a pinned key.")
+	}
+	km.UnlockKey("key0")
+	if TryLockKey(km, "hot") {
+		t.Fatalf("Expected a held pinned key to stay locked.")
+	}
+	km.UnlockKey("hot")
+}
+
+func Test_HashedWithPinning_AllPinned(t *testing.T) {
+	// Arrange
+	km, err := NewHashedWithPinning(2, map[string]int{"a": 0, "b": 1})
+	if err != nil {
+		t.Fatalf("Unexpected error from NewHashedWithPinning: %v", err)
+	}
+
+	// Act & Assert
+	LockKeys(km, "a", "b", "c")
+	if err := UnlockKeys(km, "a", "b", "c"); err != nil {
+		t.Fatalf("Unexpected error from UnlockKeys: %v", err)
+	}
+}
+
+func Test_HashedWithPinning_InvalidIndex(t *testing.T) {
+	for _, index := range []int{-1, 4} {
+		if _, err := NewHashedWithPinning(4, map[string]int{"hot": index}); err == nil {
+			t.Fatalf("Expected an error for a key pinned to lock %d of 4.", index)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+// NewHashedPow2 is like NewHashed, but rounds the number of locks up to the
+// next power of two, so that a lock can be picked by masking the hash of a key
+// rather than with a slower modulo. Use ShardCount to find the number of
+// locks actually used. Resize rounds up the same way.
+func NewHashedPow2(n int) KeyMutex {
+	return NewHashedWithOptions(n, WithPow2Shards())
+}
+
+// WithPow2Shards rounds the number of locks up to the next power of two, as
+// NewHashedPow2 does.
+func WithPow2Shards() Option {
+	return func(km *hashedKeyMutex) {
+		km.pow2 = true
+		km.rebuild()
+	}
+}
+
+// nextPowerOfTwo returns the smallest power of two which is at least n, for
+// n > 0.
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_HashedPow2_ShardCount(t *testing.T) {
+	for _, tc := range []struct {
+		n        int
+		expected int
+	}{
+		{n: 1, expected: 1},
+		{n: 2, expected: 2},
+		{n: 3, expected: 4},
+		{n: 8, expected: 8},
+		{n: 9, expected: 16},
+	} {
+		// Arrange
+		km := NewHashedPow2(tc.n)
+
+		// Act
+		count := km.(ShardCounter).ShardCount()
+		stats := km.(StatsReporter).Stats()
+
+		// Assert
+		if count != tc.expected {
+			t.Errorf("Expected NewHashedPow2(%d) to use %d shards, got %d.", tc.n, tc.expected, count)
+		}
+		if len(stats) != tc.expected {
+			t.Errorf("Expected NewHashedPow2(%d) to report %d shard stats, got %d.", tc.n, tc.expected, len(stats))
+		}
+	}
+}
+
+func Test_HashedPow2_SameShardsAsModulo(t *testing.T) {
+	// Arrange
+	pow2 := NewHashedPow2(8).(*hashedKeyMutex)
+	modulo := NewHashed(8).(*hashedKeyMutex)
+
+	// Act & Assert
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprint(i)
+		if got, expected := pow2.shardIndex(pow2.current(), id), modulo.shardIndex(modulo.current(), id); got != expected {
+			t.Fatalf("Expected %q to hash to shard %d, got %d.", id, expected, got)
+		}
+	}
+}
+
+func Test_HashedPow2_Resize(t *testing.T) {
+	// Arrange
+	km := NewHashedPow2(2)
+
+	// Act
+	km.(Resizer).Resize(5)
+
+	// Assert
+	if count := km.(ShardCounter).ShardCount(); count != 8 {
+		t.Fatalf("Expected resizing to 5 shards to use 8, got %d.", count)
+	}
+}
+
+func benchmarkLockKey(b *testing.B, km KeyMutex) {
+	ids := make([]string, 64)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			id := ids[i%len(ids)]
+			km.LockKey(id)
+			km.UnlockKey(id)
+		}
+	})
+}
+
+func BenchmarkHashed(b *testing.B) {
+	benchmarkLockKey(b, NewHashed(64))
+}
+
+func BenchmarkHashedPow2(b *testing.B) {
+	benchmarkLockKey(b, NewHashedPow2(64))
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"runtime"
+	"sync/atomic"
+
+	"k8s.io/klog/v2"
+)
+
+// Reservation is a set of locks acquired together by Reserve, which the
+// caller either keeps with Commit or gives back with Abort once it has
+// decided whether to go ahead.
+type Reservation struct {
+	km   KeyMutex
+	keys []string
+	// resolved is set once Commit or Abort has been called.
+	resolved int32
+}
+
+// Reserve acquires the locks associated with all of the specified keys, as
+// LockKeys does, and returns them as a Reservation. The caller must call
+// either Commit or Abort on it; a Reservation which is garbage collected
+// without either is reported with a warning in the log, since its keys stay
+// locked forever.
+func Reserve(km KeyMutex, keys ...string) *Reservation {
+	LockKeys(km, keys...)
+	r := &Reservation{km: km, keys: append([]string(nil), keys...)}
+	runtime.SetFinalizer(r, func(r *Reservation) {
+		if atomic.LoadInt32(&r.resolved) == 0 {
+			onLeakedReservation(r.keys)
+		}
+	})
+	return r
+}
+
+// Commit keeps the locks of the reservation, which the caller then releases
+// as usual with UnlockKeys with the same keys. Only the first call to Commit
+// or Abort has an effect.
+func (r *Reservation) Commit() {
+	r.resolve()
+}
+
+// Abort releases all of the locks of the reservation. Only the first call to
+// Commit or Abort has an effect.
+func (r *Reservation) Abort() {
+	if r.resolve() {
+		UnlockKeys(r.km, r.keys...)
+	}
+}
+
+// resolve marks the reservation resolved, reporting whether it wasn't
+// already.
+func (r *Reservation) resolve() bool {
+	if !atomic.CompareAndSwapInt32(&r.resolved, 0, 1) {
+		return false
+	}
+	runtime.SetFinalizer(r, nil)
+	return true
+}
+
+// onLeakedReservation is called with the keys of a Reservation which was
+// garbage collected without being committed or aborted.
+var onLeakedReservation = func(keys []string) {
+	klog.Warningf("keymutex: reservation of keys %q was neither committed nor aborted, so they stay locked", keys)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func Test_Reservation_Abort(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		keys := []string{"b", "a", "c"}
+		r := Reserve(km, keys...)
+
+		// Act
+		r.Abort()
+		r.Abort()
+
+		// Assert
+		for _, key := range keys {
+			if !TryLockKey(km, key) {
+				t.Fatalf("Expected Abort to release %q.", key)
+			}
+			km.UnlockKey(key)
+		}
+	}
+}
+
+func Test_Reservation_Commit(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		keys := []string{"b", "a", "c"}
+		r := Reserve(km, keys...)
+
+		// Act
+		r.Commit()
+		r.Abort()
+
+		// Assert
+		for _, key := range keys {
+			if !km.(LockInspector).IsLocked(key) {
+				t.Fatalf("Expected Commit to keep %q locked.", key)
+			}
+		}
+		if err := UnlockKeys(km, keys...); err != nil {
+			t.Fatalf("Expected the committed keys to be released, got %v.", err)
+		}
+	}
+}
+
+func Test_Reservation_Leaked(t *testing.T) {
+	// Arrange
+	leakedCh := make(chan []string, 1)
+	defer func(f func([]string)) { onLeakedReservation = f }(onLeakedReservation)
+	onLeakedReservation = func(keys []string) { leakedCh <- keys }
+	km := NewHashed(4)
+	func() {
+		Reserve(km, "a", "b")
+		Reserve(km, "c").Abort()
+	}()
+
+	// Act
+	var leaked []string
+	verifyEventually(t, func() bool {
+		runtime.GC()
+		select {
+		case leaked = <-leakedCh:
+			return true
+		default:
+			return false
+		}
+	})
+
+	// Assert
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(leaked, expected) {
+		t.Fatalf("Expected the reservation of %v to be reported, got %v.", expected, leaked)
+	}
+	runtime.GC()
+	select {
+	case keys := <-leakedCh:
+		t.Fatalf("Expected only the unresolved reservation to be reported, got %v.", keys)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"sync/atomic"
+)
+
+// Values of shard.state.
+const (
+	// shardFree means the lock isn't held on behalf of any key, although it
+	// may briefly be locked by a caller which is about to give it up again.
+	shardFree int32 = iota
+	// shardEntering means the lock has just been acquired, and its holder is
+	// checking that the generation it belongs to is still current.
+	shardEntering
+	// shardHeld means the lock is held on behalf of shard.holder.
+	shardHeld
+)
+
+// generation is one set of locks of a hashed KeyMutex. Resize replaces the
+// current generation with a new one, and the old generation stays reachable
+// from its successors until none of its locks are held any more.
+//
+// A lock is only held once it has been acquired from the generation which is
+// still current afterwards, so no new holders appear on a generation once it
+// has been replaced. Before acquiring a key on the current generation, callers
+// wait for the locks the key hashes to on older generations, so that they
+// follow any holders those generations still have.
+type generation struct {
+	shards []*shard
+	// mask selects a lock from a hash if the number of locks is a power of
+	// two and the KeyMutex was created by NewHashedPow2.
+	mask uint32
+	// unpinned holds the indexes of the locks no key is pinned to, which the
+	// other keys hash onto, if the KeyMutex was created by
+	// NewHashedWithPinning.
+	unpinned []int
+	// prev holds the *generation preceding this one, or nil once none of the
+	// older generations has holders left.
+	prev atomic.Value
+	// retired is set once the generation has been replaced.
+	retired int32
+
+	// next and unlinked are guarded by the KeyMutex's resizeLock.
+	next     *generation
+	unlinked bool
+}
+
+// newGeneration returns a generation of n locks, which are built as the
+// options of the KeyMutex require.
+func (km *hashedKeyMutex) newGeneration(n int) *generation {
+	g := &generation{}
+	if km.padded {
+		g.shards = newPaddedShards(n)
+	} else {
+		g.shards = newShardRefs(n)
+	}
+	if km.pow2 {
+		g.mask = uint32(n - 1)
+	}
+	if km.pinned != nil {
+		g.unpinned = km.unpinnedShards(n)
+	}
+	if km.fair && km.padded {
+		for i, m := range newPaddedFairMutexes(n) {
+			g.shards[i].locker = m
+		}
+	} else if km.fair {
+		for i := range g.shards {
+			g.shards[i].locker = &fairMutex{}
+		}
+	} else if km.strategy != DefaultStrategy {
+		for i := range g.shards {
+			g.shards[i].locker = newStrategyLocker(km.strategy)
+		}
+	}
+	g.prev.Store((*generation)(nil))
+	return g
+}
+
+// rebuild replaces the initial generation of a KeyMutex which hasn't been
+// used yet, once an Option has changed how generations are built.
+func (km *hashedKeyMutex) rebuild() {
+	km.generation.Store(km.newGeneration(km.shardCount(len(km.current().shards))))
+}
+
+// shardCount returns the number of locks to use when n are asked for.
+func (km *hashedKeyMutex) shardCount(n int) int {
+	if n <= 0 {
+		n = 1
+	}
+	if km.pow2 {
+		n = nextPowerOfTwo(n)
+	}
+	return n
+}
+
+// older returns the generation preceding g which may still have holders.
+func (g *generation) older() *generation {
+	return g.prev.Load().(*generation)
+}
+
+// drained reports whether none of the locks of g are held.
+func (g *generation) drained() bool {
+	for i := range g.shards {
+		if atomic.LoadInt32(&g.shards[i].state) != shardFree {
+			return false
+		}
+	}
+	return true
+}
+
+// Replaces the locks of the KeyMutex with n new ones, or a single one if
+// n <= 0, as NewHashed chooses, without blocking callers. Keys are rehashed onto the new
+// locks, which all later acquisitions use, while keys which are already held
+// stay on their old lock until they are unlocked. A key is never held by more
+// than one caller at a time, even while a resize is in progress: acquiring a
+// key waits for any holder of it on the old locks first, so a key may wait
+// for both its old and its new lock until the old holders have drained.
+// Locks acquired before the resize are unlocked as usual.
+// The contention counters reported by Stats start over from zero after a
+// resize.
+func (km *hashedKeyMutex) Resize(n int) {
+	n = km.shardCount(n)
+	km.resizeLock.Lock()
+	defer km.resizeLock.Unlock()
+	old := km.current()
+	if n == len(old.shards) {
+		return
+	}
+	g := km.newGeneration(n)
+	g.prev.Store(old)
+	old.next = g
+	atomic.StoreInt32(&old.retired, 1)
+	km.generation.Store(g)
+	km.unlink(old)
+}
+
+// current returns the generation acquisitions have to use.
+func (km *hashedKeyMutex) current() *generation {
+	return km.generation.Load().(*generation)
+}
+
+// drain waits until none of the generations older than g hold the lock id
+// hashes to, giving up once either done or abort is closed. Since replaced
+// generations gain no new holders, a key which has been drained stays drained.
+func (km *hashedKeyMutex) drain(g *generation, id string, prio int, done, abort <-chan struct{}) bool {
+	for p := g.older(); p != nil; p = p.older() {
+		s := km.shardOf(p, id)
+		if !s.wait(prio, done, abort) {
+			return false
+		}
+		s.unlock()
+	}
+	return true
+}
+
+// tryDrain is like drain, but gives up rather than wait.
+func (km *hashedKeyMutex) tryDrain(g *generation, id string) bool {
+	for p := g.older(); p != nil; p = p.older() {
+		s := km.shardOf(p, id)
+		if !s.tryLock() {
+			return false
+		}
+		s.unlock()
+	}
+	return true
+}
+
+// enter is called right after acquiring s from g. It returns false if g has
+// been replaced in the meantime, in which case the caller must unlock s and
+// try again on the current generation.
+func (km *hashedKeyMutex) enter(g *generation, s *shard) bool {
+	// Resize replaces the generation before checking whether it has drained,
+	// so either it sees s entering, or s sees the new generation.
+	atomic.StoreInt32(&s.state, shardEntering)
+	if km.current() == g {
+		return true
+	}
+	km.leave(g, s)
+	return false
+}
+
+// leave is called before unlocking s, which was entered from g.
+func (km *hashedKeyMutex) leave(g *generation, s *shard) {
+	atomic.StoreInt32(&s.state, shardFree)
+	if atomic.LoadInt32(&km.idleWaiters) != 0 {
+		km.notifyIdle()
+	}
+	if atomic.LoadInt32(&km.freeWaiters) != 0 {
+		km.notifyFree()
+	}
+	if atomic.LoadInt32(&g.retired) != 0 {
+		km.resizeLock.Lock()
+		defer km.resizeLock.Unlock()
+		km.unlink(g)
+	}
+}
+
+// unlink stops newer generations from waiting for the retired generation g,
+// if it has drained. resizeLock must be held.
+func (km *hashedKeyMutex) unlink(g *generation) {
+	if g.unlinked || !g.drained() {
+		return
+	}
+	prev := g.older()
+	g.next.prev.Store(prev)
+	if prev != nil {
+		prev.next = g.next
+	}
+	g.unlinked = true
+}
+
+// heldShard returns the lock id is held on and the generation it belongs to,
+// or nil if id isn't held. Older generations are searched first: a holder of
+// id on a newer generation has waited for the older ones, so it can read
+// their locks, whereas reading the locks of a newer generation could race
+// with their holders.
+func (km *hashedKeyMutex) heldShard(id string) (*generation, *shard) {
+	if g := km.current(); g.older() == nil {
+		if s := km.shardOf(g, id); s.heldFor(id) {
+			return g, s
+		}
+		return nil, nil
+	}
+	var buf [4]*generation
+	for _, g := range km.generations(buf[:0]) {
+		if s := km.shardOf(g, id); s.heldFor(id) {
+			return g, s
+		}
+	}
+	return nil, nil
+}
+
+// generations appends the generations which may have holders to gens, oldest
+// first.
+func (km *hashedKeyMutex) generations(gens []*generation) []*generation {
+	for g := km.current(); g != nil; g = g.older() {
+		gens = append(gens, g)
+	}
+	for i, j := 0, len(gens)-1; i < j; i, j = i+1, j-1 {
+		gens[i], gens[j] = gens[j], gens[i]
+	}
+	return gens
+}
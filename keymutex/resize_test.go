@@ -0,0 +1,185 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Resize_HeldKeyExcludesNewLockers(t *testing.T) {
+	// Arrange
+	km := NewHashed(2)
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+	km.LockKey(key)
+
+	// Act
+	km.(Resizer).Resize(7)
+
+	// Assert
+	go lockAndCallback(km, key, callbackCh)
+	verifyCallbackDoesntHappens(t, callbackCh)
+	if TryLockKey(km, key) {
+		t.Fatalf("Expected TryLockKey to fail on a key held since before the resize.")
+	}
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+	if n := len(km.(StatsReporter).Stats()); n != 7 {
+		t.Fatalf("Expected 7 shards after resizing, got %d.", n)
+	}
+}
+
+func Test_Resize_Drains(t *testing.T) {
+	// Arrange
+	km := newHashed(2, hash)
+	km.LockKeys("a", "b", "c")
+
+	// Act
+	km.Resize(5)
+	km.Resize(3)
+	drainedEarly := km.current().older() == nil
+	km.UnlockKeys("a", "b", "c")
+
+	// Assert
+	if drainedEarly {
+		t.Fatalf("Expected older generations to be kept while their keys are held.")
+	}
+	if km.current().older() != nil {
+		t.Fatalf("Expected older generations to be dropped once their keys are unlocked.")
+	}
+	if km.IsLocked("a") || km.IsLocked("b") || km.IsLocked("c") {
+		t.Fatalf("Expected all keys to be unlocked.")
+	}
+}
+
+func Test_Resize_Reentrant(t *testing.T) {
+	// Arrange
+	km := NewReentrantHashed(2)
+	key := "fakeid"
+	callbackCh := make(chan interface{})
+	km.LockKey(key)
+	km.(Resizer).Resize(5)
+
+	// Act
+	km.LockKey(key)
+	km.UnlockKey(key)
+	km.UnlockKey(key)
+
+	// Assert
+	go lockAndCallback(km, key, callbackCh)
+	verifyCallbackHappens(t, callbackCh)
+}
+
+func Test_Resize_UnderLoad(t *testing.T) {
+	// Arrange
+	km := NewHashed(1)
+	const keys = 16
+	const goroutines = 8
+	const iterations = 2000
+	var holders [keys]int32
+	stop := make(chan struct{})
+	var failures int32
+	enter := func(ids ...int) {
+		for _, i := range ids {
+			if atomic.AddInt32(&holders[i], 1) != 1 {
+				atomic.AddInt32(&failures, 1)
+			}
+		}
+	}
+	exit := func(ids ...int) {
+		for _, i := range ids {
+			atomic.AddInt32(&holders[i], -1)
+		}
+	}
+
+	// Act
+	var resizes sync.WaitGroup
+	resizes.Add(1)
+	go func() {
+		defer resizes.Done()
+		for n := 2; ; n = n%9 + 1 {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			km.(Resizer).Resize(n)
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				a, b := (g+i)%keys, (g*i+1)%keys
+				idA, idB := fmt.Sprint(a), fmt.Sprint(b)
+				switch i % 4 {
+				case 0:
+					km.LockKey(idA)
+					enter(a)
+					exit(a)
+					km.UnlockKey(idA)
+				case 1:
+					if TryLockKey(km, idA) {
+						enter(a)
+						exit(a)
+						km.UnlockKey(idA)
+					}
+				case 2:
+					ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+					if LockKeyWithContext(ctx, km, idA) {
+						enter(a)
+						exit(a)
+						km.UnlockKey(idA)
+					}
+					cancel()
+				case 3:
+					if a == b {
+						continue
+					}
+					LockKeys(km, idA, idB)
+					enter(a, b)
+					exit(a, b)
+					UnlockKeys(km, idA, idB)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(stop)
+	resizes.Wait()
+
+	// Assert
+	if failures != 0 {
+		t.Fatalf("Expected keys to stay mutually exclusive while resizing, %d acquisitions overlapped.", failures)
+	}
+	for i := 0; i < keys; i++ {
+		id := fmt.Sprint(i)
+		if !TryLockKey(km, id) {
+			t.Fatalf("Expected key %q to be unlocked after resizing.", id)
+		}
+		km.UnlockKey(id)
+	}
+}
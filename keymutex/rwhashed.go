@@ -0,0 +1,243 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// NewRWHashed returns a new instance of RWKeyMutex which hashes arbitrary keys
+// to a fixed set of reader/writer locks. `n` specifies number of locks, if
+// n <= 0, a single lock is shared by all keys, as with NewHashed.
+// As with NewHashed, different keys may share the same lock, so a writer on
+// one key may wait on readers of another.
+// The returned RWKeyMutex is also a KeyUpgrader and a RWContextLocker.
+func NewRWHashed(n int) RWKeyMutex {
+	if n <= 0 {
+		n = 1
+	}
+	return &rwHashedKeyMutex{
+		mutexes: make([]rwMutex, n),
+	}
+}
+
+var (
+	_ RWKeyMutex      = (*rwHashedKeyMutex)(nil)
+	_ KeyUpgrader     = (*rwHashedKeyMutex)(nil)
+	_ RWContextLocker = (*rwHashedKeyMutex)(nil)
+)
+
+type rwHashedKeyMutex struct {
+	mutexes []rwMutex
+}
+
+// Acquires the write lock associated with the specified ID.
+func (km *rwHashedKeyMutex) LockKey(id string) {
+	km.mutex(id).writeLock()
+}
+
+// Releases the write lock associated with the specified ID.
+// Panics if the write lock is not held.
+func (km *rwHashedKeyMutex) UnlockKey(id string) error {
+	km.mutex(id).writeUnlock()
+	return nil
+}
+
+// Acquires the write lock associated with the specified ID, giving up once
+// ctx is done.
+func (km *rwHashedKeyMutex) LockKeyWithContext(ctx context.Context, id string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return km.mutex(id).writeLockWithStop(ctx.Done())
+}
+
+// Acquires a read lock associated with the specified ID.
+func (km *rwHashedKeyMutex) RLockKey(id string) {
+	km.mutex(id).readLock()
+}
+
+// Acquires a read lock associated with the specified ID, giving up once ctx
+// is done.
+func (km *rwHashedKeyMutex) RLockKeyWithContext(ctx context.Context, id string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return km.mutex(id).readLockWithStop(ctx.Done())
+}
+
+// Releases a read lock associated with the specified ID.
+// Panics if no read lock is held.
+func (km *rwHashedKeyMutex) RUnlockKey(id string) error {
+	km.mutex(id).readUnlock()
+	return nil
+}
+
+// Turns the caller's read lock associated with the specified ID into the
+// write lock, if no other reader holds the lock and no writer waits for it.
+// Since keys share locks, readers of other keys hashing to the same lock also
+// prevent upgrading.
+func (km *rwHashedKeyMutex) UpgradeKey(id string) bool {
+	return km.mutex(id).upgrade()
+}
+
+// Turns the caller's write lock associated with the specified ID into a read
+// lock. Panics if the write lock is not held.
+func (km *rwHashedKeyMutex) DowngradeKey(id string) error {
+	km.mutex(id).downgrade()
+	return nil
+}
+
+func (km *rwHashedKeyMutex) mutex(id string) *rwMutex {
+	return &km.mutexes[hash(id)%uint32(len(km.mutexes))]
+}
+
+// rwMutex is a reader/writer lock built on a sync.RWMutex, which, unlike a
+// sync.RWMutex, can turn a read lock into the write lock and back. As with
+// sync.RWMutex, a waiting writer keeps new readers from acquiring the lock,
+// so writers aren't starved. Waits which can give up retry the sync.RWMutex
+// each time it may have become available, as mutex does, so the other waits
+// block on it directly. The zero value is an unlocked rwMutex.
+type rwMutex struct {
+	rw sync.RWMutex
+	// w is held by a writer from before it waits for rw until it releases
+	// rw, and briefly by readers while a writer which can give up waits for
+	// rw, so that they queue behind it.
+	w mutex
+	// waiting is set while a writer which can give up waits for readers to
+	// release rw, which doesn't keep new readers out as a waiting Lock does.
+	waiting int32
+	// readers is the number of read locks held, and writer is 1 while the
+	// write lock is held, which sync.RWMutex doesn't report.
+	readers int32
+	writer  int32
+	// drained announces the read unlocks to the writer waiting for them.
+	drained releases
+}
+
+func (m *rwMutex) readLock() {
+	if atomic.LoadInt32(&m.waiting) == 0 {
+		m.rw.RLock()
+	} else {
+		// While w is held, rw isn't write locked, so RLock can't block.
+		m.w.lock()
+		m.rw.RLock()
+		m.w.unlock()
+	}
+	atomic.AddInt32(&m.readers, 1)
+}
+
+// readLockWithStop acquires a read lock, giving up once stop is closed.
+func (m *rwMutex) readLockWithStop(stop <-chan struct{}) bool {
+	if atomic.LoadInt32(&m.waiting) != 0 || !m.rw.TryRLock() {
+		// rw is held or waited for by a writer, which holds w until it
+		// releases rw.
+		if !m.w.lockOrDone(stop) {
+			return false
+		}
+		m.rw.RLock()
+		m.w.unlock()
+	}
+	atomic.AddInt32(&m.readers, 1)
+	return true
+}
+
+func (m *rwMutex) readUnlock() {
+	for {
+		readers := atomic.LoadInt32(&m.readers)
+		if readers == 0 {
+			panic("keymutex: read unlock of unlocked mutex")
+		}
+		if atomic.CompareAndSwapInt32(&m.readers, readers, readers-1) {
+			break
+		}
+	}
+	m.rw.RUnlock()
+	m.drained.announce()
+}
+
+func (m *rwMutex) writeLock() {
+	m.w.lock()
+	m.rw.Lock()
+	atomic.StoreInt32(&m.writer, 1)
+}
+
+// writeLockWithStop acquires the write lock, giving up once stop is closed.
+func (m *rwMutex) writeLockWithStop(stop <-chan struct{}) bool {
+	if !m.w.lockOrDone(stop) {
+		return false
+	}
+	if !m.rw.TryLock() {
+		atomic.StoreInt32(&m.waiting, 1)
+		acquired := m.drained.retry(m.rw.TryLock, stop, nil)
+		atomic.StoreInt32(&m.waiting, 0)
+		if !acquired {
+			m.w.unlock()
+			return false
+		}
+	}
+	atomic.StoreInt32(&m.writer, 1)
+	return true
+}
+
+func (m *rwMutex) writeUnlock() {
+	if !atomic.CompareAndSwapInt32(&m.writer, 1, 0) {
+		panic("keymutex: unlock of unlocked mutex")
+	}
+	m.rw.Unlock()
+	m.w.unlock()
+}
+
+// upgrade turns the caller's read lock into the write lock if it is the only
+// reader and no writer is waiting. It never waits, since two readers waiting
+// for each other to go would deadlock.
+func (m *rwMutex) upgrade() bool {
+	if !m.w.tryLock() {
+		return false
+	}
+	if atomic.LoadInt32(&m.readers) != 1 {
+		m.w.unlock()
+		return false
+	}
+	// No writer can take rw while w is held, so the read lock can be handed
+	// back if another reader got in after all.
+	atomic.AddInt32(&m.readers, -1)
+	m.rw.RUnlock()
+	if !m.rw.TryLock() {
+		m.rw.RLock()
+		atomic.AddInt32(&m.readers, 1)
+		m.w.unlock()
+		return false
+	}
+	atomic.StoreInt32(&m.writer, 1)
+	return true
+}
+
+// downgrade turns the caller's write lock into a read lock, letting other
+// readers in. Since w is held until the read lock is, no writer can get in
+// between.
+func (m *rwMutex) downgrade() {
+	if !atomic.CompareAndSwapInt32(&m.writer, 1, 0) {
+		panic("keymutex: downgrade of unlocked mutex")
+	}
+	m.rw.Unlock()
+	m.rw.RLock()
+	atomic.AddInt32(&m.readers, 1)
+	m.w.unlock()
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRWKeyMutexes() []RWKeyMutex {
+	return []RWKeyMutex{
+		NewRWHashed(0),
+		NewRWHashed(1),
+		NewRWHashed(2),
+		NewRWHashed(4),
+	}
+}
+
+func Test_DoubleRLock(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		callbackCh1stLock := make(chan interface{})
+		callbackCh2ndLock := make(chan interface{})
+
+		// Act & Assert
+		go rLockAndCallback(km, key, callbackCh1stLock)
+		verifyCallbackHappens(t, callbackCh1stLock)
+		go rLockAndCallback(km, key, callbackCh2ndLock)
+		verifyCallbackHappens(t, callbackCh2ndLock)
+		km.RUnlockKey(key)
+		km.RUnlockKey(key)
+	}
+}
+
+func Test_RLock_Lock(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		callbackChRLock1 := make(chan interface{})
+		callbackChRLock2 := make(chan interface{})
+		callbackChLock := make(chan interface{})
+
+		// Act & Assert
+		go rLockAndCallback(km, key, callbackChRLock1)
+		verifyCallbackHappens(t, callbackChRLock1)
+		go rLockAndCallback(km, key, callbackChRLock2)
+		verifyCallbackHappens(t, callbackChRLock2)
+		go rwLockAndCallback(km, key, callbackChLock)
+		verifyCallbackDoesntHappens(t, callbackChLock)
+		km.RUnlockKey(key)
+		verifyCallbackDoesntHappens(t, callbackChLock)
+		km.RUnlockKey(key)
+		verifyCallbackHappens(t, callbackChLock)
+		km.UnlockKey(key)
+	}
+}
+
+func Test_Lock_RLock(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		callbackChLock := make(chan interface{})
+		callbackChRLock1 := make(chan interface{})
+		callbackChRLock2 := make(chan interface{})
+
+		// Act & Assert
+		go rwLockAndCallback(km, key, callbackChLock)
+		verifyCallbackHappens(t, callbackChLock)
+		go rLockAndCallback(km, key, callbackChRLock1)
+		go rLockAndCallback(km, key, callbackChRLock2)
+		verifyCallbackDoesntHappens(t, callbackChRLock1)
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackChRLock1)
+		verifyCallbackHappens(t, callbackChRLock2)
+		km.RUnlockKey(key)
+		km.RUnlockKey(key)
+	}
+}
+
+func Test_DoubleLock_RW(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		callbackCh1stLock := make(chan interface{})
+		callbackCh2ndLock := make(chan interface{})
+
+		// Act & Assert
+		go rwLockAndCallback(km, key, callbackCh1stLock)
+		verifyCallbackHappens(t, callbackCh1stLock)
+		go rwLockAndCallback(km, key, callbackCh2ndLock)
+		verifyCallbackDoesntHappens(t, callbackCh2ndLock)
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackCh2ndLock)
+		km.UnlockKey(key)
+	}
+}
+
+func Test_UpgradeKey(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		upgrader := km.(KeyUpgrader)
+		callbackChRLock := make(chan interface{})
+		km.RLockKey(key)
+
+		// Act & Assert
+		if !upgrader.UpgradeKey(key) {
+			t.Fatalf("Expected the sole reader to upgrade.")
+		}
+		go rLockAndCallback(km, key, callbackChRLock)
+		verifyCallbackDoesntHappens(t, callbackChRLock)
+		km.UnlockKey(key)
+		verifyCallbackHappens(t, callbackChRLock)
+		km.RUnlockKey(key)
+	}
+}
+
+func Test_UpgradeKey_Contended(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		upgrader := km.(KeyUpgrader)
+		callbackChLock := make(chan interface{})
+		km.RLockKey(key)
+		km.RLockKey(key)
+
+		// Act & Assert
+		if upgrader.UpgradeKey(key) {
+			t.Fatalf("Expected upgrading to fail while another reader holds the lock.")
+		}
+		// The failed upgrade kept the read lock, so a writer still waits for
+		// both readers.
+		go rwLockAndCallback(km, key, callbackChLock)
+		verifyCallbackDoesntHappens(t, callbackChLock)
+		km.RUnlockKey(key)
+		verifyCallbackDoesntHappens(t, callbackChLock)
+		km.RUnlockKey(key)
+		verifyCallbackHappens(t, callbackChLock)
+		km.UnlockKey(key)
+	}
+}
+
+func Test_DowngradeKey(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		upgrader := km.(KeyUpgrader)
+		callbackChRLock := make(chan interface{})
+		callbackChLock := make(chan interface{})
+		km.LockKey(key)
+		go rLockAndCallback(km, key, callbackChRLock)
+		verifyCallbackDoesntHappens(t, callbackChRLock)
+
+		// Act
+		err := upgrader.DowngradeKey(key)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Unexpected error from DowngradeKey: %v", err)
+		}
+		verifyCallbackHappens(t, callbackChRLock)
+		go rwLockAndCallback(km, key, callbackChLock)
+		verifyCallbackDoesntHappens(t, callbackChLock)
+		km.RUnlockKey(key)
+		km.RUnlockKey(key)
+		verifyCallbackHappens(t, callbackChLock)
+		km.UnlockKey(key)
+	}
+}
+
+func Test_DowngradeKey_Unlocked(t *testing.T) {
+	// Arrange
+	km := NewRWHashed(1)
+
+	// Act
+	recovered := recoverPanic(func() {
+		km.(KeyUpgrader).DowngradeKey("fakeid")
+	})
+
+	// Assert
+	if recovered == nil {
+		t.Fatalf("Expected DowngradeKey to panic without the write lock.")
+	}
+}
+
+func Test_RLockKeyWithContext_WriteHeld(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		locker := km.(RWContextLocker)
+		km.LockKey(key)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+
+		// Act
+		acquired := locker.RLockKeyWithContext(ctx, key)
+		cancel()
+
+		// Assert
+		if acquired {
+			t.Fatalf("Expected RLockKeyWithContext to time out while the write lock is held.")
+		}
+		km.UnlockKey(key)
+		if !locker.RLockKeyWithContext(context.Background(), key) {
+			t.Fatalf("Expected RLockKeyWithContext to succeed once the write lock is released.")
+		}
+		km.RUnlockKey(key)
+	}
+}
+
+func Test_LockKeyWithContext_RW_ReadHeld(t *testing.T) {
+	for _, km := range newRWKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		locker := km.(RWContextLocker)
+		callbackCh := make(chan interface{})
+		km.RLockKey(key)
+		ctx, cancel := context.WithCancel(context.Background())
+		acquiredCh := make(chan bool, 1)
+		go func() {
+			acquiredCh <- locker.LockKeyWithContext(ctx, key)
+		}()
+		verifyEventually(t, func() bool {
+			return atomic.LoadInt32(&km.(*rwHashedKeyMutex).mutex(key).waiting) == 1
+		})
+		go rLockAndCallback(km, key, callbackCh)
+		verifyCallbackDoesntHappens(t, callbackCh)
+
+		// Act
+		cancel()
+
+		// Assert
+		if <-acquiredCh {
+			t.Fatalf("Expected LockKeyWithContext to give up while a read lock is held.")
+		}
+		verifyCallbackHappens(t, callbackCh)
+		km.RUnlockKey(key)
+		km.RUnlockKey(key)
+		if !locker.LockKeyWithContext(context.Background(), key) {
+			t.Fatalf("Expected LockKeyWithContext to succeed once the read locks are released.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_RWContext_AlreadyCancelled(t *testing.T) {
+	// Arrange
+	locker := NewRWHashed(1).(RWContextLocker)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act & Assert
+	if locker.RLockKeyWithContext(ctx, "fakeid") {
+		t.Fatalf("Expected RLockKeyWithContext not to acquire a free lock with a done context.")
+	}
+	if locker.LockKeyWithContext(ctx, "fakeid") {
+		t.Fatalf("Expected LockKeyWithContext not to acquire a free lock with a done context.")
+	}
+}
+
+func rLockAndCallback(km RWKeyMutex, id string, callbackCh chan<- interface{}) {
+	km.RLockKey(id)
+	callbackCh <- true
+}
+
+func rwLockAndCallback(km RWKeyMutex, id string, callbackCh chan<- interface{}) {
+	km.LockKey(id)
+	callbackCh <- true
+}
+
+func BenchmarkRWHashed_RLockRUnlock(b *testing.B) {
+	km := NewRWHashed(64)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			km.RLockKey("fakeid")
+			km.RUnlockKey("fakeid")
+		}
+	})
+}
+
+func BenchmarkRWHashed_LockUnlock(b *testing.B) {
+	km := NewRWHashed(64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		km.LockKey("fakeid")
+		km.UnlockKey("fakeid")
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// LockKeyFunc acquires the lock associated with id and returns a function
+// which releases it, so callers can simply `defer unlock()`. The returned
+// function always releases id, and calling it more than once is a no-op.
+func LockKeyFunc(km KeyMutex, id string) (unlock func()) {
+	km.LockKey(id)
+	return unlockKeyFunc(km, id)
+}
+
+// LockKeyFuncWithContext is like LockKeyFunc, but gives up once ctx is done.
+// If the lock was not acquired, ok is false and unlock is nil.
+func LockKeyFuncWithContext(ctx context.Context, km KeyMutex, id string) (unlock func(), ok bool) {
+	if !LockKeyWithContext(ctx, km, id) {
+		return nil, false
+	}
+	return unlockKeyFunc(km, id), true
+}
+
+// WithContextLock acquires the lock associated with key, giving up once ctx
+// is done, and calls fn while holding it, releasing it once fn returns or
+// panics. It returns the error from fn, or if the lock was not acquired, the
+// error from LockKeyWithContextErr without calling fn.
+func WithContextLock(ctx context.Context, km KeyMutex, key string, fn func() error) error {
+	if err := LockKeyWithContextErr(ctx, km, key); err != nil {
+		return err
+	}
+	defer km.UnlockKey(key)
+	return fn()
+}
+
+// GuardKey acquires the lock associated with key and calls fn while holding
+// it. The lock is released once fn returns, and also if fn panics, in which
+// case the panic propagates to the caller after the lock is released, so a
+// panic recovered further up the stack can't leave the key locked forever.
+func GuardKey(km KeyMutex, key string, fn func()) {
+	km.LockKey(key)
+	defer km.UnlockKey(key)
+	fn()
+}
+
+// KeyLock is a lock acquired by LockKeyHandleWithContext.
+type KeyLock struct {
+	ctx    context.Context
+	unlock func()
+}
+
+// Unlock releases the lock, and reports whether the context it was acquired
+// with was already done, so that callers can tell which critical sections
+// overran their deadline. The context is only observed: the lock is never
+// released because of it. Calling Unlock more than once does not release the
+// lock again.
+func (l *KeyLock) Unlock() (expired bool) {
+	expired = l.ctx.Err() != nil
+	l.unlock()
+	return expired
+}
+
+// LockKeyHandleWithContext is like LockKeyFuncWithContext, but returns a
+// KeyLock whose Unlock reports whether ctx was done by the time the lock was
+// released. If the lock was not acquired, ok is false and the KeyLock is nil.
+func LockKeyHandleWithContext(ctx context.Context, km KeyMutex, id string) (lock *KeyLock, ok bool) {
+	if !LockKeyWithContext(ctx, km, id) {
+		return nil, false
+	}
+	return &KeyLock{ctx: ctx, unlock: unlockKeyFunc(km, id)}, true
+}
+
+// WithKeyLock acquires the lock associated with key, giving up once ctx is
+// done, and returns a context derived from ctx which records that key is
+// held, along with a function which releases it. Code deeper in the call tree
+// can check with HoldsKey that the lock it relies on is held. Releasing more
+// than once is a no-op. If the lock was not acquired, ctx is returned
+// unchanged along with a nil release and the error from
+// LockKeyWithContextErr.
+func WithKeyLock(ctx context.Context, km KeyMutex, key string) (context.Context, func(), error) {
+	if err := LockKeyWithContextErr(ctx, km, key); err != nil {
+		return ctx, nil, err
+	}
+	hold := &contextHold{key: key}
+	hold.parent, _ = ctx.Value(contextHoldKey{}).(*contextHold)
+	unlock := unlockKeyFunc(km, key)
+	release := func() {
+		atomic.StoreInt32(&hold.released, 1)
+		unlock()
+	}
+	return context.WithValue(ctx, contextHoldKey{}, hold), release, nil
+}
+
+// HoldsKey reports whether ctx was derived from a context returned by
+// WithKeyLock for key, and the key hasn't been released since. Keys are
+// compared by value, whichever KeyMutex they were locked on.
+func HoldsKey(ctx context.Context, key string) bool {
+	hold, _ := ctx.Value(contextHoldKey{}).(*contextHold)
+	for ; hold != nil; hold = hold.parent {
+		if hold.key == key && atomic.LoadInt32(&hold.released) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestScope tracks the keys locked on behalf of a request, and releases
+// them once the request's context is done, so that a handler which runs away
+// can't hold them forever. Create one with NewRequestScope.
+//
+// Releasing is best-effort: keys are released while the handler may still be
+// working on what they guard, trading that safety for not blocking everyone
+// else, so handlers must not rely on it. Keys are released by another
+// goroutine, so KeyMutex instances which track the holding goroutine, such as
+// those returned by NewReentrantHashed, can't be used.
+type RequestScope struct {
+	ctx context.Context
+	km  KeyMutex
+	// stop is closed by End, stopping the goroutine watching ctx.
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// lock guards the fields below.
+	lock sync.Mutex
+	held map[string]struct{}
+	// released is set once all keys have been released, after which no more
+	// keys are locked.
+	released bool
+}
+
+// NewRequestScope returns a RequestScope which locks keys on km, and releases
+// them once ctx is done. End must be called once the request is over, to
+// release the remaining keys and stop watching ctx.
+func NewRequestScope(ctx context.Context, km KeyMutex) *RequestScope {
+	s := &RequestScope{
+		ctx:  ctx,
+		km:   km,
+		stop: make(chan struct{}),
+		held: make(map[string]struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.releaseAll()
+		case <-s.stop:
+		}
+	}()
+	return s
+}
+
+// Lock acquires the lock associated with key, giving up once the request's
+// context is done. Returns true if the lock was acquired, in which case the
+// scope releases it unless Unlock does first. A key must not be locked again
+// while the scope holds it.
+func (s *RequestScope) Lock(key string) bool {
+	if !LockKeyWithContext(s.ctx, s.km, key) {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.released {
+		// The keys were released while this one was being acquired.
+		s.km.UnlockKey(key)
+		return false
+	}
+	s.held[key] = struct{}{}
+	return true
+}
+
+// Unlock releases the lock associated with key, returning an error, without
+// unlocking it again, if the scope doesn't hold it, for example because the
+// request's context is done and it has already been released.
+func (s *RequestScope) Unlock(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.held[key]; !ok {
+		return fmt.Errorf("keymutex: key %q is not held by the request scope", key)
+	}
+	delete(s.held, key)
+	return s.km.UnlockKey(key)
+}
+
+// End releases the keys the scope still holds, and stops watching the
+// request's context. The scope can no longer lock keys afterwards.
+func (s *RequestScope) End() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.releaseAll()
+}
+
+// releaseAll releases every key still held and stops locking new ones.
+func (s *RequestScope) releaseAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.released = true
+	for key := range s.held {
+		s.km.UnlockKey(key)
+		delete(s.held, key)
+	}
+}
+
+// contextHoldKey is the context key under which WithKeyLock records the
+// innermost key it holds.
+type contextHoldKey struct{}
+
+// contextHold is a key held by WithKeyLock, linked to those held by the
+// enclosing calls.
+type contextHold struct {
+	key      string
+	released int32
+	parent   *contextHold
+}
+
+func unlockKeyFunc(km KeyMutex, id string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			km.UnlockKey(id)
+		})
+	}
+}
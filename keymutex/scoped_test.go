@@ -0,0 +1,365 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func Test_LockKeyFunc(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+
+		// Act
+		unlock := LockKeyFunc(km, key)
+
+		// Assert
+		if TryLockKey(km, key) {
+			t.Fatalf("Expected %q to be held after LockKeyFunc.", key)
+		}
+		unlock()
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected %q to be free after unlock.", key)
+		}
+		// A second call must not release the lock taken by TryLockKey.
+		unlock()
+		if TryLockKey(km, key) {
+			t.Fatalf("Expected second unlock to be a no-op.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockKeyFuncWithContext(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// Act & Assert
+		unlock, ok := LockKeyFuncWithContext(ctx, km, key)
+		if !ok {
+			t.Fatalf("Expected LockKeyFuncWithContext to acquire a free key.")
+		}
+		cancel()
+		if unlock2, ok := LockKeyFuncWithContext(ctx, km, key); ok || unlock2 != nil {
+			t.Fatalf("Expected LockKeyFuncWithContext to give up on a held key.")
+		}
+		unlock()
+		unlock()
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected %q to be free after unlock.", key)
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_LockKeyHandleWithContext(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// Act & Assert
+		lock, ok := LockKeyHandleWithContext(ctx, km, key)
+		if !ok {
+			t.Fatalf("Expected LockKeyHandleWithContext to acquire a free key.")
+		}
+		if lock.Unlock() {
+			t.Fatalf("Expected Unlock before the context is done not to report it expired.")
+		}
+		lock, ok = LockKeyHandleWithContext(ctx, km, key)
+		if !ok {
+			t.Fatalf("Expected LockKeyHandleWithContext to acquire a released key.")
+		}
+		cancel()
+		if TryLockKey(km, key) {
+			t.Fatalf("Expected the lock to stay held after its context is done.")
+		}
+		if !lock.Unlock() {
+			t.Fatalf("Expected Unlock after the context is done to report it expired.")
+		}
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected %q to be free after Unlock.", key)
+		}
+		if _, ok := LockKeyHandleWithContext(ctx, km, key); ok {
+			t.Fatalf("Expected LockKeyHandleWithContext to fail on a held key once ctx is done.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_WithKeyLock(t *testing.T) {
+	// The keys must not share a lock, as they could with NewHashed.
+	for _, km := range []KeyMutex{NewPerKey()} {
+		// Arrange
+		ctx := context.Background()
+
+		// Act
+		outer, releaseOuter, err := WithKeyLock(ctx, km, "a")
+		if err != nil {
+			t.Fatalf("Expected WithKeyLock to acquire a free key, got %v.", err)
+		}
+		inner, releaseInner, err := WithKeyLock(outer, km, "b")
+		if err != nil {
+			t.Fatalf("Expected WithKeyLock to acquire a free key, got %v.", err)
+		}
+
+		// Assert
+		if HoldsKey(ctx, "a") {
+			t.Fatalf("Expected the original context not to hold %q.", "a")
+		}
+		if !HoldsKey(inner, "a") || !HoldsKey(inner, "b") {
+			t.Fatalf("Expected the inner context to hold both keys.")
+		}
+		if HoldsKey(outer, "b") {
+			t.Fatalf("Expected the outer context not to hold %q.", "b")
+		}
+		releaseInner()
+		if HoldsKey(inner, "b") || !HoldsKey(inner, "a") {
+			t.Fatalf("Expected releasing %q to only remove it from the context.", "b")
+		}
+		if !TryLockKey(km, "b") {
+			t.Fatalf("Expected %q to be free after release.", "b")
+		}
+		km.UnlockKey("b")
+		releaseOuter()
+		releaseOuter()
+		if HoldsKey(inner, "a") {
+			t.Fatalf("Expected releasing %q to remove it from the context.", "a")
+		}
+		if !TryLockKey(km, "a") {
+			t.Fatalf("Expected %q to be free after release.", "a")
+		}
+		km.UnlockKey("a")
+	}
+}
+
+func Test_WithKeyLock_Cancelled(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		km.LockKey(key)
+
+		// Act
+		got, release, err := WithKeyLock(ctx, km, key)
+
+		// Assert
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got %v.", err)
+		}
+		if release != nil || got != ctx || HoldsKey(got, key) {
+			t.Fatalf("Expected a failed WithKeyLock to return ctx unchanged and no release.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_WithContextLock(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		fnErr := errors.New("fake error")
+		var held bool
+
+		// Act
+		err := WithContextLock(context.Background(), km, key, func() error {
+			held = km.(LockInspector).IsLocked(key)
+			return fnErr
+		})
+
+		// Assert
+		if err != fnErr {
+			t.Fatalf("Expected the error from fn, got %v.", err)
+		}
+		if !held {
+			t.Fatalf("Expected fn to be called with the key held.")
+		}
+		if km.(LockInspector).IsLocked(key) {
+			t.Fatalf("Expected the key to be released once fn returned.")
+		}
+	}
+}
+
+func Test_WithContextLock_Panic(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+
+		// Act
+		r := recoverPanic(func() {
+			WithContextLock(context.Background(), km, key, func() error { panic("fake panic") })
+		})
+
+		// Assert
+		if r == nil {
+			t.Fatalf("Expected the panic in fn to be propagated.")
+		}
+		if km.(LockInspector).IsLocked(key) {
+			t.Fatalf("Expected the key to be released when fn panics.")
+		}
+	}
+}
+
+func Test_GuardKey(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		var held bool
+
+		// Act
+		GuardKey(km, key, func() {
+			held = km.(LockInspector).IsLocked(key)
+		})
+
+		// Assert
+		if !held {
+			t.Fatalf("Expected the key to be held while fn runs.")
+		}
+		if km.(LockInspector).IsLocked(key) {
+			t.Fatalf("Expected the key to be released once fn returns.")
+		}
+	}
+}
+
+func Test_GuardKey_Panic(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+
+		// Act
+		r := recoverPanic(func() {
+			GuardKey(km, key, func() { panic("fake panic") })
+		})
+
+		// Assert
+		if r != "fake panic" {
+			t.Fatalf("Expected the panic in fn to be propagated, got %v.", r)
+		}
+		if km.(LockInspector).IsLocked(key) {
+			t.Fatalf("Expected the key to be released when fn panics.")
+		}
+	}
+}
+
+func Test_WithContextLock_Cancelled(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		key := "fakeid"
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		km.LockKey(key)
+		called := false
+
+		// Act
+		err := WithContextLock(ctx, km, key, func() error {
+			called = true
+			return nil
+		})
+
+		// Assert
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got %v.", err)
+		}
+		if called {
+			t.Fatalf("Expected fn not to be called without the lock.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_RequestScope_ReleasesOnCancel(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		ctx, cancel := context.WithCancel(context.Background())
+		scope := NewRequestScope(ctx, km)
+		key := "fakeid"
+		if !scope.Lock(key) {
+			t.Fatalf("Expected the scope to lock a free key.")
+		}
+
+		// Act
+		cancel()
+
+		// Assert
+		verifyEventually(t, func() bool { return !km.(LockInspector).IsLocked(key) })
+		if err := scope.Unlock(key); err == nil {
+			t.Fatalf("Expected unlocking a released key to fail.")
+		}
+		if scope.Lock(key) {
+			t.Fatalf("Expected the scope not to lock keys once its context is done.")
+		}
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected the key to be free.")
+		}
+		km.UnlockKey(key)
+		scope.End()
+	}
+}
+
+func Test_RequestScope_Unlock(t *testing.T) {
+	for _, km := range newKeyMutexes() {
+		// Arrange
+		ctx, cancel := context.WithCancel(context.Background())
+		scope := NewRequestScope(ctx, km)
+		key := "fakeid"
+		scope.Lock(key)
+
+		// Act
+		err := scope.Unlock(key)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Unexpected error from Unlock: %v", err)
+		}
+		// Another holder must not be released once the context is done.
+		km.LockKey(key)
+		cancel()
+		scope.End()
+		if TryLockKey(km, key) {
+			t.Fatalf("Expected the scope not to release a key it no longer holds.")
+		}
+		km.UnlockKey(key)
+	}
+}
+
+func Test_RequestScope_End(t *testing.T) {
+	// Arrange
+	km := NewHashed(64)
+	scope := NewRequestScope(context.Background(), km)
+	scope.Lock("a")
+	scope.Lock("b")
+
+	// Act
+	scope.End()
+
+	// Assert
+	for _, key := range []string{"a", "b"} {
+		if !TryLockKey(km, key) {
+			t.Fatalf("Expected End to release %q.", key)
+		}
+		km.UnlockKey(key)
+	}
+	if scope.Lock("a") {
+		t.Fatalf("Expected the scope not to lock keys once ended.")
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// KeyedSemaphore is a thread-safe interface for limiting the number of
+// concurrent holders of arbitrary strings.
+type KeyedSemaphore interface {
+	// Acquires a permit associated with the specified ID, blocking while
+	// all of its permits are held.
+	Acquire(id string)
+
+	// Attempts to acquire a permit associated with the specified ID without
+	// blocking. Returns true if a permit was acquired.
+	TryAcquire(id string) bool
+
+	// Acquires a permit associated with the specified ID, giving up once ctx
+	// is done. Returns true if a permit was acquired, false if ctx was done
+	// first. If ctx is already done, no permit is acquired even if one is
+	// free.
+	AcquireWithContext(ctx context.Context, id string) bool
+
+	// Releases a permit associated with the specified ID.
+	// Returns an error if no permit is held.
+	Release(id string) error
+}
+
+// NewKeyedSemaphore returns a new instance of KeyedSemaphore which hashes
+// arbitrary keys to a fixed set of semaphores, each allowing up to `permits`
+// concurrent holders. `shards` specifies number of semaphores, if
+// shards <= 0, a single semaphore is shared by all keys, as with NewHashed.
+// If permits <= 0, one permit is used, which makes it equivalent to
+// NewHashed.
+// Note that because it uses fixed set of semaphores, different keys may share
+// the same permits.
+func NewKeyedSemaphore(shards, permits int) KeyedSemaphore {
+	if shards <= 0 {
+		shards = 1
+	}
+	if permits <= 0 {
+		permits = 1
+	}
+	semaphores := make([]chan struct{}, shards)
+	for i := range semaphores {
+		semaphores[i] = make(chan struct{}, permits)
+	}
+	return &hashedKeyedSemaphore{
+		semaphores: semaphores,
+	}
+}
+
+var _ KeyedSemaphore = (*hashedKeyedSemaphore)(nil)
+
+type hashedKeyedSemaphore struct {
+	// semaphores hold one element for each permit currently acquired.
+	semaphores []chan struct{}
+}
+
+// Acquires a permit associated with the specified ID.
+func (ks *hashedKeyedSemaphore) Acquire(id string) {
+	ks.semaphore(id) <- struct{}{}
+}
+
+// Attempts to acquire a permit associated with the specified ID without blocking.
+func (ks *hashedKeyedSemaphore) TryAcquire(id string) bool {
+	select {
+	case ks.semaphore(id) <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Acquires a permit associated with the specified ID, giving up when ctx is done.
+func (ks *hashedKeyedSemaphore) AcquireWithContext(ctx context.Context, id string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case ks.semaphore(id) <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Releases a permit associated with the specified ID.
+func (ks *hashedKeyedSemaphore) Release(id string) error {
+	select {
+	case <-ks.semaphore(id):
+		return nil
+	default:
+		return fmt.Errorf("keymutex: release of unacquired key %q", id)
+	}
+}
+
+func (ks *hashedKeyedSemaphore) semaphore(id string) chan struct{} {
+	return ks.semaphores[hash(id)%uint32(len(ks.semaphores))]
+}
+
+// WeightedKeyedSemaphore is like KeyedSemaphore, but each acquisition takes a
+// weight out of a capacity shared by the keys hashing to the same semaphore,
+// so that costly operations can hold more of it than cheap ones.
+type WeightedKeyedSemaphore interface {
+	// Acquires weight out of the capacity associated with the specified ID,
+	// blocking until that much is available.
+	Acquire(id string, weight int)
+
+	// Attempts to acquire weight out of the capacity associated with the
+	// specified ID without blocking. Returns true if it was acquired.
+	TryAcquire(id string, weight int) bool
+
+	// Acquires weight out of the capacity associated with the specified ID,
+	// giving up once ctx is done. Returns true if it was acquired, false if
+	// ctx was done first. If ctx is already done, nothing is acquired even
+	// if enough capacity is available.
+	AcquireWithContext(ctx context.Context, id string, weight int) bool
+
+	// Releases weight back to the capacity associated with the specified ID.
+	// Returns an error if less than weight is held.
+	Release(id string, weight int) error
+}
+
+// NewWeightedKeyedSemaphore returns a new instance of WeightedKeyedSemaphore
+// which hashes arbitrary keys to a fixed set of semaphores, each with the
+// given capacity. `shards` specifies number of semaphores, if shards <= 0, a
+// single semaphore is shared by all keys, as with NewHashed. If capacity <= 0,
+// a capacity of one is used.
+// Waiters are served in the order they started waiting, so a heavy waiter
+// isn't starved by a stream of lighter ones, which in turn wait behind it.
+// Acquiring a weight greater than the capacity, or less than one, panics.
+func NewWeightedKeyedSemaphore(shards, capacity int) WeightedKeyedSemaphore {
+	if shards <= 0 {
+		shards = 1
+	}
+	if capacity <= 0 {
+		capacity = 1
+	}
+	semaphores := make([]weightedSemaphore, shards)
+	for i := range semaphores {
+		semaphores[i].capacity = capacity
+	}
+	return &hashedWeightedKeyedSemaphore{
+		semaphores: semaphores,
+	}
+}
+
+var _ WeightedKeyedSemaphore = (*hashedWeightedKeyedSemaphore)(nil)
+
+type hashedWeightedKeyedSemaphore struct {
+	semaphores []weightedSemaphore
+}
+
+// Acquires weight out of the capacity associated with the specified ID.
+func (ks *hashedWeightedKeyedSemaphore) Acquire(id string, weight int) {
+	ks.semaphore(id).acquire(id, weight, nil)
+}
+
+// Attempts to acquire weight out of the capacity associated with the
+// specified ID without blocking.
+func (ks *hashedWeightedKeyedSemaphore) TryAcquire(id string, weight int) bool {
+	return ks.semaphore(id).tryAcquire(id, weight)
+}
+
+// Acquires weight out of the capacity associated with the specified ID,
+// giving up when ctx is done.
+func (ks *hashedWeightedKeyedSemaphore) AcquireWithContext(ctx context.Context, id string, weight int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return ks.semaphore(id).acquire(id, weight, ctx.Done())
+}
+
+// Releases weight back to the capacity associated with the specified ID.
+func (ks *hashedWeightedKeyedSemaphore) Release(id string, weight int) error {
+	return ks.semaphore(id).release(id, weight)
+}
+
+func (ks *hashedWeightedKeyedSemaphore) semaphore(id string) *weightedSemaphore {
+	return &ks.semaphores[hash(id)%uint32(len(ks.semaphores))]
+}
+
+// weightedSemaphore is a counting semaphore whose holders each take a weight
+// out of its capacity. Waiters are queued in arrival order, and a waiter is
+// only granted its weight once all those ahead of it have been.
+type weightedSemaphore struct {
+	capacity int
+	lock     sync.Mutex
+	// used is the weight currently held.
+	used    int
+	waiters []*weightedWaiter
+}
+
+type weightedWaiter struct {
+	weight int
+	// granted is closed once the weight has been acquired on behalf of the
+	// waiter.
+	granted chan struct{}
+}
+
+func (s *weightedSemaphore) tryAcquire(id string, weight int) bool {
+	s.checkWeight(id, weight)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.waiters) > 0 || s.used+weight > s.capacity {
+		return false
+	}
+	s.used += weight
+	return true
+}
+
+// acquire blocks until weight has been acquired or done is closed. A nil done
+// channel waits forever.
+func (s *weightedSemaphore) acquire(id string, weight int, done <-chan struct{}) bool {
+	return s.acquireOrAbort(id, weight, done, nil)
+}
+
+// acquireOrAbort is like acquire, but also gives up once abort is closed.
+func (s *weightedSemaphore) acquireOrAbort(id string, weight int, done, abort <-chan struct{}) bool {
+	s.checkWeight(id, weight)
+	s.lock.Lock()
+	if len(s.waiters) == 0 && s.used+weight <= s.capacity {
+		s.used += weight
+		s.lock.Unlock()
+		return true
+	}
+	w := &weightedWaiter{weight: weight, granted: make(chan struct{})}
+	s.waiters = append(s.waiters, w)
+	s.lock.Unlock()
+
+	select {
+	case <-w.granted:
+		return true
+	case <-done:
+	case <-abort:
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, other := range s.waiters {
+		if other == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			// Waiters queued behind this one may fit now.
+			s.grantLocked()
+			return false
+		}
+	}
+	// The weight was granted while giving up, so hand it back.
+	s.used -= weight
+	s.grantLocked()
+	return false
+}
+
+func (s *weightedSemaphore) release(id string, weight int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if weight < 1 || weight > s.used {
+		return fmt.Errorf("keymutex: release of unacquired key %q", id)
+	}
+	s.used -= weight
+	s.grantLocked()
+	return nil
+}
+
+// grantLocked grants the available capacity to waiters in order, stopping at
+// the first which doesn't fit. s.lock must be held.
+func (s *weightedSemaphore) grantLocked() {
+	for len(s.waiters) > 0 {
+		w := s.waiters[0]
+		if s.used+w.weight > s.capacity {
+			return
+		}
+		s.used += w.weight
+		s.waiters = s.waiters[1:]
+		close(w.granted)
+	}
+}
+
+func (s *weightedSemaphore) checkWeight(id string, weight int) {
+	if weight < 1 || weight > s.capacity {
+		panic(fmt.Sprintf("keymutex: weight %d for key %q outside of capacity %d", weight, id, s.capacity))
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_KeyedSemaphore_BlocksBeyondPermits(t *testing.T) {
+	for _, shards := range []int{0, 1, 4} {
+		// Arrange
+		const permits = 3
+		ks := NewKeyedSemaphore(shards, permits)
+		key := "fakeid"
+		callbackCh := make(chan interface{})
+
+		// Act & Assert
+		for i := 0; i < permits; i++ {
+			if !ks.TryAcquire(key) {
+				t.Fatalf("Expected permit %d to be acquired.", i+1)
+			}
+		}
+		go func() {
+			ks.Acquire(key)
+			callbackCh <- true
+		}()
+		verifyCallbackDoesntHappens(t, callbackCh)
+		if err := ks.Release(key); err != nil {
+			t.Fatalf("Unexpected error from Release: %v", err)
+		}
+		verifyCallbackHappens(t, callbackCh)
+		if ks.TryAcquire(key) {
+			t.Fatalf("Expected all permits to be held again.")
+		}
+		for i := 0; i < permits; i++ {
+			ks.Release(key)
+		}
+	}
+}
+
+func Test_KeyedSemaphore_Context(t *testing.T) {
+	// Arrange
+	ks := NewKeyedSemaphore(1, 1)
+	key := "fakeid"
+	ks.Acquire(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act & Assert
+	if ks.AcquireWithContext(ctx, key) {
+		t.Fatalf("Expected AcquireWithContext to give up with no permits free.")
+	}
+	ks.Release(key)
+	if !ks.AcquireWithContext(context.Background(), key) {
+		t.Fatalf("Expected AcquireWithContext to acquire a free permit.")
+	}
+	ks.Release(key)
+}
+
+func Test_KeyedSemaphore_DoneContext(t *testing.T) {
+	// Arrange
+	ks := NewKeyedSemaphore(1, 1)
+	ws := NewWeightedKeyedSemaphore(1, 1)
+	key := "fakeid"
+
+	// Act & Assert
+	for i := 0; i < 100; i++ {
+		if ks.AcquireWithContext(expiredContext(), key) {
+			t.Fatalf("Expected AcquireWithContext with a done context not to take a free permit.")
+		}
+		if ws.AcquireWithContext(expiredContext(), key, 1) {
+			t.Fatalf("Expected AcquireWithContext with a done context not to take free capacity.")
+		}
+	}
+}
+
+func Test_KeyedSemaphore_OverRelease(t *testing.T) {
+	// Arrange
+	ks := NewKeyedSemaphore(1, 2)
+
+	// Act
+	err := ks.Release("fakeid")
+
+	// Assert
+	if err == nil {
+		t.Errorf("Expected an error releasing an unacquired key.")
+	}
+}
+
+// queuedWaiters returns the number of waiters queued on the semaphore id
+// hashes to.
+func queuedWaiters(ks WeightedKeyedSemaphore, id string) int {
+	s := ks.(*hashedWeightedKeyedSemaphore).semaphore(id)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.waiters)
+}
+
+func Test_WeightedKeyedSemaphore_HeavyWaiterBlocksLighter(t *testing.T) {
+	// Arrange
+	ks := NewWeightedKeyedSemaphore(1, 4)
+	key := "fakeid"
+	heavyCh := make(chan interface{})
+	lightCh := make(chan interface{})
+	ks.Acquire(key, 1)
+
+	// Act
+	go func() {
+		ks.Acquire(key, 4)
+		heavyCh <- true
+	}()
+	verifyEventually(t, func() bool { return queuedWaiters(ks, key) == 1 })
+	go func() {
+		ks.Acquire(key, 1)
+		lightCh <- true
+	}()
+	verifyEventually(t, func() bool { return queuedWaiters(ks, key) == 2 })
+
+	// Assert
+	if ks.TryAcquire(key, 1) {
+		t.Fatalf("Expected TryAcquire not to overtake queued waiters.")
+	}
+	ks.Release(key, 1)
+	verifyCallbackHappens(t, heavyCh)
+	verifyCallbackDoesntHappens(t, lightCh)
+	ks.Release(key, 4)
+	verifyCallbackHappens(t, lightCh)
+	if err := ks.Release(key, 1); err != nil {
+		t.Fatalf("Unexpected error from Release: %v", err)
+	}
+}
+
+func Test_WeightedKeyedSemaphore_GrantsAllThatFit(t *testing.T) {
+	// Arrange
+	ks := NewWeightedKeyedSemaphore(1, 4)
+	key := "fakeid"
+	callbackCh := make(chan interface{}, 2)
+	ks.Acquire(key, 4)
+	for _, weight := range []int{2, 1} {
+		go func(weight int) {
+			ks.Acquire(key, weight)
+			callbackCh <- weight
+		}(weight)
+	}
+	verifyEventually(t, func() bool { return queuedWaiters(ks, key) == 2 })
+
+	// Act
+	ks.Release(key, 4)
+
+	// Assert
+	verifyCallbackHappens(t, callbackCh)
+	verifyCallbackHappens(t, callbackCh)
+	if !ks.TryAcquire(key, 1) {
+		t.Fatalf("Expected the remaining capacity to be available.")
+	}
+	if ks.TryAcquire(key, 1) {
+		t.Fatalf("Expected the capacity to be used up.")
+	}
+	ks.Release(key, 2)
+	ks.Release(key, 2)
+}
+
+func Test_WeightedKeyedSemaphore_CancelledWaiterUnblocksQueue(t *testing.T) {
+	// Arrange
+	ks := NewWeightedKeyedSemaphore(1, 4)
+	key := "fakeid"
+	ctx, cancel := context.WithCancel(context.Background())
+	resultCh := make(chan bool)
+	lightCh := make(chan interface{})
+	ks.Acquire(key, 3)
+	go func() {
+		resultCh <- ks.AcquireWithContext(ctx, key, 4)
+	}()
+	verifyEventually(t, func() bool { return queuedWaiters(ks, key) == 1 })
+	go func() {
+		ks.Acquire(key, 1)
+		lightCh <- true
+	}()
+	verifyEventually(t, func() bool { return queuedWaiters(ks, key) == 2 })
+
+	// Act
+	cancel()
+
+	// Assert
+	if <-resultCh {
+		t.Fatalf("Expected AcquireWithContext to give up once cancelled.")
+	}
+	verifyCallbackHappens(t, lightCh)
+	ks.Release(key, 3)
+	ks.Release(key, 1)
+}
+
+func Test_WeightedKeyedSemaphore_InvalidWeights(t *testing.T) {
+	// Arrange
+	ks := NewWeightedKeyedSemaphore(1, 4)
+	key := "fakeid"
+
+	// Act & Assert
+	for _, weight := range []int{0, 5} {
+		if recovered := recoverPanic(func() { ks.Acquire(key, weight) }); recovered == nil {
+			t.Errorf("Expected acquiring weight %d to panic.", weight)
+		}
+	}
+	ks.Acquire(key, 2)
+	if err := ks.Release(key, 3); err == nil {
+		t.Errorf("Expected releasing more than was acquired to fail.")
+	}
+	if err := ks.Release(key, 2); err != nil {
+		t.Errorf("Unexpected error from Release: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+// Returns a best-effort snapshot of the state of every lock. While a resize
+// is in progress, the locks being replaced only count towards the totals
+// and held keys, as they do for Locked and HeldKeys.
+func (km *hashedKeyMutex) Snapshot() Snapshot {
+	current := km.current()
+	snapshot := Snapshot{
+		Taken:      km.clock.Now(),
+		ShardCount: len(current.shards),
+		Closed:     km.isClosed(),
+		Shards:     make([]ShardStat, len(current.shards)),
+	}
+	for g := current; g != nil; g = g.older() {
+		for i := range g.shards {
+			s := g.shards[i]
+			stat := s.stat(i)
+			if g == current {
+				snapshot.Shards[i] = stat
+			}
+			if stat.Held {
+				snapshot.Locked++
+			}
+			snapshot.Waiters += stat.Waiters
+			snapshot.Contended += stat.Contended
+			if !km.trackHeld {
+				continue
+			}
+			if h, ok := s.heldBy(); ok {
+				snapshot.HeldKeys = append(snapshot.HeldKeys, h)
+			}
+		}
+	}
+	return snapshot
+}
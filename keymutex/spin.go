@@ -0,0 +1,63 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"runtime"
+)
+
+// NewSpinHashed is like NewHashed, but an acquisition which finds its lock
+// held first spins, retrying up to spins times without blocking, before
+// waiting for the lock as usual. See WithSpinning.
+func NewSpinHashed(n, spins int) KeyMutex {
+	return NewHashedWithOptions(n, WithSpinning(spins))
+}
+
+// WithSpinning retries an acquisition which finds its lock held up to spins
+// times without blocking before waiting for it, as NewSpinHashed does. When
+// locks are only held for a few hundred nanoseconds, the holder often
+// releases the lock while the waiter spins, which saves the waiter being
+// parked and woken by the scheduler.
+//
+// Spinning only pays off for such short holds while other CPUs run the
+// holders: it burns CPU time which could run other goroutines, and with
+// holds much longer than the spin it only adds that cost to each wait. With a
+// single CPU, or GOMAXPROCS set to 1 when the option is applied, the holder
+// can't release the lock while the waiter spins, so no spinning is done.
+// Acquisitions which found their lock free, and TryLockKey, which never
+// waits, are not affected. If spins <= 0, no spinning is done.
+func WithSpinning(spins int) Option {
+	return func(km *hashedKeyMutex) {
+		if runtime.NumCPU() == 1 || runtime.GOMAXPROCS(0) == 1 {
+			spins = 0
+		}
+		km.spins = spins
+	}
+}
+
+// spin retries acquiring s, which was just found to be held, up to km.spins
+// times, reporting whether it was acquired.
+func (km *hashedKeyMutex) spin(s *shard) bool {
+	for i := 0; i < km.spins; i++ {
+		// Only try when the lock looks free, so that spinning waiters
+		// don't keep writing to the lock the holder is about to release.
+		if !s.locked() && s.tryLock() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_SpinHashed_Blocks(t *testing.T) {
+	// Arrange
+	km := NewSpinHashed(1, 1000)
+	key := "fakeid"
+	callbackCh := make(chan interface{}, 1)
+	km.LockKey(key)
+
+	// Act
+	go lockAndCallback(km, key, callbackCh)
+
+	// Assert
+	verifyCallbackDoesntHappens(t, callbackCh)
+	km.UnlockKey(key)
+	verifyCallbackHappens(t, callbackCh)
+	km.UnlockKey(key)
+}
+
+func Test_SpinHashed_MutualExclusion(t *testing.T) {
+	// Arrange
+	km := NewSpinHashed(1, 100)
+	const workers = 8
+	const rounds = 1000
+	counter := 0
+	var wg sync.WaitGroup
+
+	// Act
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				if j%2 == 0 {
+					km.LockKey("a")
+					counter++
+					km.UnlockKey("a")
+				} else {
+					LockKeys(km, "a", "b")
+					counter++
+					UnlockKeys(km, "a", "b")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	if counter != workers*rounds {
+		t.Fatalf("Expected %d increments, got %d.", workers*rounds, counter)
+	}
+}
+
+// benchmarkHolds contends for a single key from all goroutines, holding it
+// for hold each time.
+func benchmarkHolds(b *testing.B, km KeyMutex, hold time.Duration) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			km.LockKey("fakeid")
+			for start := time.Now(); time.Since(start) < hold; {
+			}
+			km.UnlockKey("fakeid")
+		}
+	})
+}
+
+func BenchmarkHashed_ShortHolds(b *testing.B) {
+	benchmarkHolds(b, NewHashed(1), 0)
+}
+
+func BenchmarkSpinHashed_ShortHolds(b *testing.B) {
+	benchmarkHolds(b, NewSpinHashed(1, 100), 0)
+}
+
+func BenchmarkHashed_LongHolds(b *testing.B) {
+	benchmarkHolds(b, NewHashed(1), 20*time.Microsecond)
+}
+
+func BenchmarkSpinHashed_LongHolds(b *testing.B) {
+	benchmarkHolds(b, NewSpinHashed(1, 100), 20*time.Microsecond)
+}
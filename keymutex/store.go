@@ -0,0 +1,194 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// KeyedStore is a thread-safe interface for storing a value for each of
+// arbitrary strings, which is only read and written while holding the lock
+// associated with its key.
+type KeyedStore[V any] interface {
+	// Acquires the lock associated with the specified key and calls fn with
+	// the value stored for it, if any, then stores the value fn returns or,
+	// if fn returns false, deletes the stored value, before releasing the
+	// lock. If fn panics, the stored value is left unchanged.
+	WithLock(key string, fn func(existing V, ok bool) (V, bool))
+}
+
+// StoreOption configures optional behavior of a KeyedStore created by
+// NewKeyedStoreWithOptions.
+type StoreOption func(*storeOptions)
+
+type storeOptions struct {
+	maxKeys int
+	idleTTL time.Duration
+	clock   clock.PassiveClock
+}
+
+// WithMaxKeys bounds the number of values a KeyedStore keeps to n, by
+// evicting the values of the keys used least recently once more are stored.
+// The value of a key is never evicted while WithLock is called for it, so
+// the bound may be exceeded while more than n keys are in use at once.
+// By default values are kept until they are deleted.
+func WithMaxKeys(n int) StoreOption {
+	return func(o *storeOptions) {
+		o.maxKeys = n
+	}
+}
+
+// WithIdleTTL evicts the value of a key once WithLock hasn't been called for
+// it for d. Values are evicted by later calls to WithLock, so they may be
+// kept for longer while the KeyedStore isn't used. The value of a key is
+// never evicted while WithLock is called for it. By default values are kept
+// until they are deleted.
+func WithIdleTTL(d time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		o.idleTTL = d
+	}
+}
+
+// NewKeyedStore returns a new instance of KeyedStore which locks keys as
+// NewHashed does. `shards` specifies number of locks, if shards <= 0, a single
+// lock is shared by all keys, as with NewHashed.
+// Note that because it uses fixed set of locks, different keys may share
+// same lock, so calling WithLock for another key from within fn may
+// deadlock.
+func NewKeyedStore[V any](shards int) KeyedStore[V] {
+	return NewKeyedStoreWithOptions[V](shards)
+}
+
+// NewKeyedStoreWithOptions is like NewKeyedStore, with optional behavior
+// configured by opts.
+func NewKeyedStoreWithOptions[V any](shards int, opts ...StoreOption) KeyedStore[V] {
+	o := storeOptions{clock: clock.RealClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &keyedStore[V]{
+		km:      NewHashed(shards),
+		opts:    o,
+		entries: make(map[string]*storeEntry[V]),
+		active:  make(map[string]int),
+		recent:  list.New(),
+	}
+}
+
+var _ KeyedStore[int] = (*keyedStore[int])(nil)
+
+type keyedStore[V any] struct {
+	km   KeyMutex
+	opts storeOptions
+	// lock guards the fields below. The value for a key is only read or
+	// written while its lock in km is held, so lock itself is only held
+	// briefly.
+	lock    sync.Mutex
+	entries map[string]*storeEntry[V]
+	// active counts the calls to WithLock in progress for each key, whose
+	// values must not be evicted.
+	active map[string]int
+	// recent holds the keys of entries, most recently used first.
+	recent *list.List
+}
+
+type storeEntry[V any] struct {
+	value V
+	// used is when WithLock was last called for the key.
+	used time.Time
+	// elem is the element of the key in recent.
+	elem *list.Element
+}
+
+// Acquires the lock associated with the specified key and updates its value
+// with fn.
+func (ks *keyedStore[V]) WithLock(key string, fn func(existing V, ok bool) (V, bool)) {
+	ks.lock.Lock()
+	ks.active[key]++
+	ks.lock.Unlock()
+	defer func() {
+		ks.lock.Lock()
+		defer ks.lock.Unlock()
+		if ks.active[key]--; ks.active[key] == 0 {
+			delete(ks.active, key)
+		}
+	}()
+
+	ks.km.LockKey(key)
+	defer ks.km.UnlockKey(key)
+
+	ks.lock.Lock()
+	var existing V
+	e, ok := ks.entries[key]
+	if ok {
+		existing = e.value
+	}
+	ks.lock.Unlock()
+
+	value, keep := fn(existing, ok)
+
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if !keep {
+		if ok {
+			ks.recent.Remove(e.elem)
+			delete(ks.entries, key)
+		}
+		ks.evict()
+		return
+	}
+	if !ok {
+		e = &storeEntry[V]{elem: ks.recent.PushFront(key)}
+		ks.entries[key] = e
+	} else {
+		ks.recent.MoveToFront(e.elem)
+	}
+	e.value = value
+	e.used = ks.opts.clock.Now()
+	ks.evict()
+}
+
+// evict drops the least recently used values which exceed the configured
+// number of keys or have been idle for too long. ks.lock must be held.
+func (ks *keyedStore[V]) evict() {
+	if ks.opts.maxKeys <= 0 && ks.opts.idleTTL <= 0 {
+		return
+	}
+	var expiry time.Time
+	if ks.opts.idleTTL > 0 {
+		expiry = ks.opts.clock.Now().Add(-ks.opts.idleTTL)
+	}
+	for elem := ks.recent.Back(); elem != nil; {
+		key := elem.Value.(string)
+		e := ks.entries[key]
+		tooMany := ks.opts.maxKeys > 0 && len(ks.entries) > ks.opts.maxKeys
+		expired := ks.opts.idleTTL > 0 && e.used.Before(expiry)
+		if !tooMany && !expired {
+			return
+		}
+		prev := elem.Prev()
+		if ks.active[key] == 0 {
+			ks.recent.Remove(elem)
+			delete(ks.entries, key)
+		}
+		elem = prev
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func Test_KeyedStore_SerializesWithLock(t *testing.T) {
+	for _, shards := range []int{0, 1, 4} {
+		// Arrange
+		ks := NewKeyedStore[int](shards)
+		key := "fakeid"
+		const goroutines = 50
+		var wg sync.WaitGroup
+
+		// Act
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ks.WithLock(key, func(existing int, ok bool) (int, bool) {
+					return existing + 1, true
+				})
+			}()
+		}
+		wg.Wait()
+
+		// Assert
+		ks.WithLock(key, func(existing int, ok bool) (int, bool) {
+			if !ok || existing != goroutines {
+				t.Errorf("Expected %d increments to be stored, got %d (ok=%v).", goroutines, existing, ok)
+			}
+			return existing, ok
+		})
+	}
+}
+
+func Test_KeyedStore_Delete(t *testing.T) {
+	// Arrange
+	ks := NewKeyedStore[string](4)
+	key := "fakeid"
+	ks.WithLock(key, func(string, bool) (string, bool) {
+		return "value", true
+	})
+
+	// Act
+	ks.WithLock(key, func(existing string, ok bool) (string, bool) {
+		if !ok || existing != "value" {
+			t.Errorf("Expected the stored value, got %q (ok=%v).", existing, ok)
+		}
+		return "", false
+	})
+
+	// Assert
+	ks.WithLock(key, func(existing string, ok bool) (string, bool) {
+		if ok {
+			t.Errorf("Expected the value to be deleted, got %q.", existing)
+		}
+		return existing, ok
+	})
+}
+
+func Test_KeyedStore_PanicKeepsValue(t *testing.T) {
+	// Arrange
+	ks := NewKeyedStore[int](1)
+	key := "fakeid"
+	ks.WithLock(key, func(int, bool) (int, bool) {
+		return 1, true
+	})
+
+	// Act
+	recovered := recoverPanic(func() {
+		ks.WithLock(key, func(int, bool) (int, bool) {
+			panic("fake panic")
+		})
+	})
+
+	// Assert
+	if recovered == nil {
+		t.Fatalf("Expected the panic to propagate.")
+	}
+	ks.WithLock(key, func(existing int, ok bool) (int, bool) {
+		if !ok || existing != 1 {
+			t.Errorf("Expected the value to be unchanged, got %d (ok=%v).", existing, ok)
+		}
+		return existing, ok
+	})
+}
+
+func storedKeys[V any](ks KeyedStore[V]) int {
+	s := ks.(*keyedStore[V])
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.entries)
+}
+
+func Test_KeyedStore_MaxKeys(t *testing.T) {
+	// Arrange
+	ks := NewKeyedStoreWithOptions[int](4, WithMaxKeys(10))
+	store := func(key string) {
+		ks.WithLock(key, func(int, bool) (int, bool) { return 1, true })
+	}
+	// The oldest key is kept since it is in use throughout.
+	store("active")
+	inUse := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ks.WithLock("active", func(existing int, ok bool) (int, bool) {
+			close(inUse)
+			<-release
+			return existing, ok
+		})
+	}()
+	<-inUse
+
+	// Act
+	// Keys sharing the lock of the key in use would wait for it.
+	indexer := ks.(*keyedStore[int]).km.(ShardIndexer)
+	last := ""
+	for i := 0; i < 1000; i++ {
+		if key := fmt.Sprint("transient-", i); indexer.ShardIndex(key) != indexer.ShardIndex("active") {
+			store(key)
+			last = key
+		}
+	}
+
+	// Assert
+	if got := storedKeys(ks); got > 10 {
+		t.Errorf("Expected at most 10 stored keys, got %d.", got)
+	}
+	close(release)
+	<-done
+	ks.WithLock("active", func(existing int, ok bool) (int, bool) {
+		if !ok {
+			t.Errorf("Expected the value of a key in use not to be evicted.")
+		}
+		return existing, ok
+	})
+	ks.WithLock(last, func(existing int, ok bool) (int, bool) {
+		if !ok {
+			t.Errorf("Expected the most recently used value to be kept.")
+		}
+		return existing, ok
+	})
+}
+
+func Test_KeyedStore_IdleTTL(t *testing.T) {
+	// Arrange
+	clk := testingclock.NewFakePassiveClock(time.Now())
+	ks := NewKeyedStoreWithOptions[int](4, WithIdleTTL(time.Minute), func(o *storeOptions) { o.clock = clk })
+	store := func(key string) {
+		ks.WithLock(key, func(int, bool) (int, bool) { return 1, true })
+	}
+	store("idle")
+	store("busy")
+
+	// Act
+	clk.SetTime(clk.Now().Add(30 * time.Second))
+	store("busy")
+	clk.SetTime(clk.Now().Add(31 * time.Second))
+	store("other")
+
+	// Assert
+	if got := storedKeys(ks); got != 2 {
+		t.Errorf("Expected the idle key to be evicted, got %d stored keys.", got)
+	}
+	ks.WithLock("busy", func(existing int, ok bool) (int, bool) {
+		if !ok {
+			t.Errorf("Expected the value of a recently used key to be kept.")
+		}
+		return existing, ok
+	})
+}
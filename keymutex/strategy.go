@@ -0,0 +1,148 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// Strategy selects the kind of lock each lock of a hashed KeyMutex is built
+// on. Every strategy provides the same guarantees; they only differ in how
+// they perform under different contention, so that deployments can compare
+// them without recompiling.
+type Strategy int
+
+const (
+	// DefaultStrategy builds each lock on a sync.Mutex, as NewHashed does.
+	DefaultStrategy Strategy = iota
+	// MutexStrategy builds each lock on a sync.Mutex, which spins briefly
+	// and then parks waiters in the runtime's semaphore queue. Waits which
+	// can give up, such as LockKeyWithContext, retry the mutex each time it
+	// is released instead, competing with the parked waiters.
+	MutexStrategy
+	// SpinStrategy builds each lock on a single atomic flag, which waiters
+	// keep retrying, yielding the processor between attempts. It suits
+	// locks which are only held very briefly, and wastes CPU time otherwise.
+	SpinStrategy
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case DefaultStrategy:
+		return "default"
+	case MutexStrategy:
+		return "mutex"
+	case SpinStrategy:
+		return "spin"
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// NewHashedWithStrategy is like NewHashed, but builds each lock as strategy
+// selects. See WithStrategy.
+func NewHashedWithStrategy(n int, strategy Strategy) KeyMutex {
+	return NewHashedWithOptions(n, WithStrategy(strategy))
+}
+
+// WithStrategy builds each lock as strategy selects, as NewHashedWithStrategy
+// does. WithFairness takes precedence over it. Panics if strategy is not one
+// of the strategies defined by this package.
+func WithStrategy(strategy Strategy) Option {
+	switch strategy {
+	case DefaultStrategy, MutexStrategy, SpinStrategy:
+	default:
+		panic(fmt.Sprintf("keymutex: unknown lock strategy %v", strategy))
+	}
+	return func(km *hashedKeyMutex) {
+		km.strategy = strategy
+		km.rebuild()
+	}
+}
+
+// newStrategyLocker returns a lock of the kind strategy selects, other than
+// the default one.
+func newStrategyLocker(strategy Strategy) shardLocker {
+	if strategy == SpinStrategy {
+		return &spinMutex{}
+	}
+	return &syncMutex{}
+}
+
+// syncMutex is a sync.Mutex which can be waited for with a way to give up.
+// Such waits retry the mutex each time it is released, as with mutex, rather
+// than leaving a goroutine behind to wait for it.
+type syncMutex struct {
+	mutex trackedMutex
+}
+
+func (m *syncMutex) tryLock() bool {
+	return m.mutex.tryLock()
+}
+
+// lockOrAbort blocks until the lock is acquired or either done or abort is
+// closed.
+func (m *syncMutex) lockOrAbort(_ int, done, abort <-chan struct{}) bool {
+	return m.mutex.lockOrAbort(done, abort)
+}
+
+func (m *syncMutex) unlock() {
+	if !m.mutex.tryUnlock() {
+		panic("keymutex: unlock of unlocked mutex")
+	}
+}
+
+func (m *syncMutex) locked() bool {
+	return m.mutex.locked()
+}
+
+// spinMutex is a mutual exclusion lock which waiters acquire by retrying a
+// compare-and-swap of a single flag.
+type spinMutex struct {
+	held int32
+}
+
+func (m *spinMutex) tryLock() bool {
+	return atomic.CompareAndSwapInt32(&m.held, 0, 1)
+}
+
+// lockOrAbort retries the lock until it is acquired or either done or abort
+// is closed, yielding the processor between attempts.
+func (m *spinMutex) lockOrAbort(_ int, done, abort <-chan struct{}) bool {
+	for {
+		// Only try when the lock looks free, so that waiters don't keep
+		// writing to the lock the holder is about to release.
+		if !m.locked() && m.tryLock() {
+			return true
+		}
+		if isStopped(done) || isStopped(abort) {
+			return false
+		}
+		runtime.Gosched()
+	}
+}
+
+func (m *spinMutex) unlock() {
+	if !atomic.CompareAndSwapInt32(&m.held, 1, 0) {
+		panic("keymutex: unlock of unlocked mutex")
+	}
+}
+
+func (m *spinMutex) locked() bool {
+	return atomic.LoadInt32(&m.held) == 1
+}
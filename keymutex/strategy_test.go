@@ -0,0 +1,106 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+var strategies = []Strategy{DefaultStrategy, MutexStrategy, SpinStrategy}
+
+func Test_Strategy_MutualExclusion(t *testing.T) {
+	for _, strategy := range strategies {
+		t.Run(strategy.String(), func(t *testing.T) {
+			// Arrange
+			km := NewHashedWithStrategy(2, strategy)
+			const workers = 8
+			const rounds = 500
+			counter := 0
+			var wg sync.WaitGroup
+
+			// Act
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < rounds; j++ {
+						switch j % 3 {
+						case 0:
+							km.LockKey("a")
+							counter++
+							km.UnlockKey("a")
+						case 1:
+							if !LockKeyWithContext(context.Background(), km, "a") {
+								t.Error("Expected LockKeyWithContext to acquire the lock.")
+								return
+							}
+							counter++
+							km.UnlockKey("a")
+						default:
+							LockKeys(km, "a", "b")
+							counter++
+							UnlockKeys(km, "a", "b")
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			// Assert
+			if counter != workers*rounds {
+				t.Fatalf("Expected %d increments, got %d.", workers*rounds, counter)
+			}
+		})
+	}
+}
+
+func Test_Strategy_GivesUp(t *testing.T) {
+	for _, strategy := range strategies {
+		t.Run(strategy.String(), func(t *testing.T) {
+			// Arrange
+			km := NewHashedWithStrategy(1, strategy)
+			km.LockKey("a")
+
+			// Act
+			acquired := LockKeyWithTimeout(km, "a", 10*time.Millisecond)
+
+			// Assert
+			if acquired {
+				t.Fatal("Expected LockKeyWithTimeout to give up on a held lock.")
+			}
+			km.UnlockKey("a")
+			// Nothing may be left holding the lock on behalf of the waiter
+			// which gave up.
+			if !TryLockKey(km, "a") {
+				t.Fatal("Expected the lock to be free once released after a waiter gave up.")
+			}
+			km.UnlockKey("a")
+		})
+	}
+}
+
+func Test_Strategy_Unknown(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected an unknown strategy to panic.")
+		}
+	}()
+	NewHashedWithStrategy(1, Strategy(42))
+}
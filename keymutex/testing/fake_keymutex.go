@@ -0,0 +1,251 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/utils/keymutex"
+)
+
+var (
+	_ = keymutex.KeyMutex(&RecordingKeyMutex{})
+	_ = keymutex.TryLocker(&RecordingKeyMutex{})
+	_ = keymutex.ContextLocker(&RecordingKeyMutex{})
+	_ = keymutex.TimeoutLocker(&RecordingKeyMutex{})
+	_ = keymutex.BatchLocker(&RecordingKeyMutex{})
+	_ = keymutex.ContextErrLocker(&RecordingKeyMutex{})
+	_ = keymutex.StopLocker(&RecordingKeyMutex{})
+)
+
+// Op identifies a method of KeyMutex or of the optional interfaces
+// RecordingKeyMutex implements.
+type Op string
+
+// The operations recorded by RecordingKeyMutex.
+const (
+	OpLockKey               Op = "LockKey"
+	OpTryLockKey            Op = "TryLockKey"
+	OpLockKeyWithContext    Op = "LockKeyWithContext"
+	OpLockKeyWithContextErr Op = "LockKeyWithContextErr"
+	OpLockKeyWithStop       Op = "LockKeyWithStop"
+	OpLockKeyWithTimeout    Op = "LockKeyWithTimeout"
+	OpUnlockKey             Op = "UnlockKey"
+	OpLockKeys              Op = "LockKeys"
+	OpUnlockKeys            Op = "UnlockKeys"
+)
+
+// Event is a call recorded by RecordingKeyMutex. LockKeys and UnlockKeys
+// record one event per distinct key, in the order the keys are locked.
+type Event struct {
+	Op  Op
+	Key string
+	// Acquired reports whether a locking call acquired the key. For unlocking
+	// calls it reports whether the key was held.
+	Acquired bool
+}
+
+// RecordingKeyMutex implements keymutex.KeyMutex without ever blocking, and
+// records every call in order so that tests can check how code under test
+// locks keys. A key can be locked again while it is held, which would block
+// with a real KeyMutex, and each lock must be matched by an unlock before
+// AssertBalanced passes. TryLockKey and LockKeyWithTimeout fail on keys
+// which are held.
+type RecordingKeyMutex struct {
+	// LockKeyWithContextFunc, if set, decides the result of
+	// LockKeyWithContext and LockKeyWithContextErr: the key is acquired if it
+	// returns nil. By default the key is always acquired. Either way, as
+	// with a real KeyMutex, the key is not acquired if ctx is already done,
+	// and LockKeyWithContextFunc isn't called.
+	LockKeyWithContextFunc func(ctx context.Context, id string) error
+
+	lock   sync.Mutex
+	events []Event
+	held   map[string]int
+}
+
+// NewRecording returns a new RecordingKeyMutex with no keys held.
+func NewRecording() *RecordingKeyMutex {
+	return &RecordingKeyMutex{
+		held: map[string]int{},
+	}
+}
+
+// LockKey records id as locked.
+func (km *RecordingKeyMutex) LockKey(id string) {
+	km.record(OpLockKey, id, true)
+}
+
+// TryLockKey locks id unless it is held.
+func (km *RecordingKeyMutex) TryLockKey(id string) bool {
+	return km.recordUnlessHeld(OpTryLockKey, id)
+}
+
+// LockKeyWithContext locks id unless ctx is done or LockKeyWithContextFunc
+// fails.
+func (km *RecordingKeyMutex) LockKeyWithContext(ctx context.Context, id string) bool {
+	return km.lockWithContext(ctx, OpLockKeyWithContext, id) == nil
+}
+
+// LockKeyWithContextErr locks id unless ctx is done or LockKeyWithContextFunc
+// fails, in which case ctx.Err() or its error is returned.
+func (km *RecordingKeyMutex) LockKeyWithContextErr(ctx context.Context, id string) error {
+	return km.lockWithContext(ctx, OpLockKeyWithContextErr, id)
+}
+
+// LockKeyWithStop locks id unless stop is closed.
+func (km *RecordingKeyMutex) LockKeyWithStop(id string, stop <-chan struct{}) bool {
+	acquired := true
+	select {
+	case <-stop:
+		acquired = false
+	default:
+	}
+	km.record(OpLockKeyWithStop, id, acquired)
+	return acquired
+}
+
+// LockKeyWithTimeout locks id unless it is held, without waiting.
+func (km *RecordingKeyMutex) LockKeyWithTimeout(id string, d time.Duration) bool {
+	return km.recordUnlessHeld(OpLockKeyWithTimeout, id)
+}
+
+// UnlockKey records id as unlocked, returning an error if it isn't held.
+func (km *RecordingKeyMutex) UnlockKey(id string) error {
+	return km.unlock(OpUnlockKey, id)
+}
+
+// LockKeys records each distinct ID as locked, in ascending order.
+func (km *RecordingKeyMutex) LockKeys(ids ...string) {
+	for _, id := range sortedUnique(ids) {
+		km.record(OpLockKeys, id, true)
+	}
+}
+
+// UnlockKeys records each distinct ID as unlocked, returning an error if any
+// of them isn't held.
+func (km *RecordingKeyMutex) UnlockKeys(ids ...string) error {
+	var firstErr error
+	for _, id := range sortedUnique(ids) {
+		if err := km.unlock(OpUnlockKeys, id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Events returns the calls recorded so far, in order.
+func (km *RecordingKeyMutex) Events() []Event {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	return append([]Event(nil), km.events...)
+}
+
+// Held returns the keys currently held, in ascending order.
+func (km *RecordingKeyMutex) Held() []string {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	var held []string
+	for id := range km.held {
+		held = append(held, id)
+	}
+	sort.Strings(held)
+	return held
+}
+
+// AssertBalanced fails t if any key is still held, or if a key was unlocked
+// while it wasn't held.
+func (km *RecordingKeyMutex) AssertBalanced(t testing.TB) {
+	t.Helper()
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	for _, e := range km.events {
+		if (e.Op == OpUnlockKey || e.Op == OpUnlockKeys) && !e.Acquired {
+			t.Errorf("Key %q was unlocked by %s while it wasn't held.", e.Key, e.Op)
+		}
+	}
+	ids := make([]string, 0, len(km.held))
+	for id := range km.held {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		t.Errorf("Key %q is still held, %d lock(s) were never unlocked.", id, km.held[id])
+	}
+}
+
+func (km *RecordingKeyMutex) lockWithContext(ctx context.Context, op Op, id string) error {
+	err := ctx.Err()
+	if err == nil && km.LockKeyWithContextFunc != nil {
+		err = km.LockKeyWithContextFunc(ctx, id)
+	}
+	km.record(op, id, err == nil)
+	return err
+}
+
+func (km *RecordingKeyMutex) recordUnlessHeld(op Op, id string) bool {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	acquired := km.held[id] == 0
+	km.recordLocked(op, id, acquired)
+	return acquired
+}
+
+func (km *RecordingKeyMutex) record(op Op, id string, acquired bool) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	km.recordLocked(op, id, acquired)
+}
+
+func (km *RecordingKeyMutex) recordLocked(op Op, id string, acquired bool) {
+	km.events = append(km.events, Event{Op: op, Key: id, Acquired: acquired})
+	if acquired {
+		km.held[id]++
+	}
+}
+
+func (km *RecordingKeyMutex) unlock(op Op, id string) error {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	held := km.held[id] > 0
+	km.events = append(km.events, Event{Op: op, Key: id, Acquired: held})
+	if !held {
+		return fmt.Errorf("keymutex: unlock of unlocked key %q", id)
+	}
+	km.held[id]--
+	if km.held[id] == 0 {
+		delete(km.held, id)
+	}
+	return nil
+}
+
+func sortedUnique(ids []string) []string {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, id := range sorted {
+		if i == 0 || id != sorted[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
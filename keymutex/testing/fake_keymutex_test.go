@@ -0,0 +1,135 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recordingT captures the failures reported by AssertBalanced.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecordingKeyMutex_Events(t *testing.T) {
+	km := NewRecording()
+
+	km.LockKeys("b", "a", "b")
+	if km.TryLockKey("a") {
+		t.Errorf("Expected TryLockKey to fail on a held key.")
+	}
+	km.UnlockKeys("a", "b")
+	km.LockKey("a")
+	km.UnlockKey("a")
+
+	expected := []Event{
+		{Op: OpLockKeys, Key: "a", Acquired: true},
+		{Op: OpLockKeys, Key: "b", Acquired: true},
+		{Op: OpTryLockKey, Key: "a", Acquired: false},
+		{Op: OpUnlockKeys, Key: "a", Acquired: true},
+		{Op: OpUnlockKeys, Key: "b", Acquired: true},
+		{Op: OpLockKey, Key: "a", Acquired: true},
+		{Op: OpUnlockKey, Key: "a", Acquired: true},
+	}
+	if events := km.Events(); !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v.", expected, events)
+	}
+	km.AssertBalanced(t)
+}
+
+func TestRecordingKeyMutex_LockKeyWithContextFunc(t *testing.T) {
+	km := NewRecording()
+	km.LockKeyWithContextFunc = func(ctx context.Context, id string) error {
+		if id == "timeout" {
+			return context.DeadlineExceeded
+		}
+		return nil
+	}
+
+	if err := km.LockKeyWithContextErr(context.Background(), "timeout"); err != context.DeadlineExceeded {
+		t.Errorf("Expected the canned error, got %v.", err)
+	}
+	if !km.LockKeyWithContext(context.Background(), "ok") {
+		t.Errorf("Expected LockKeyWithContext to acquire the key.")
+	}
+	stop := make(chan struct{})
+	close(stop)
+	if km.LockKeyWithStop("stopped", stop) {
+		t.Errorf("Expected LockKeyWithStop to fail once stopped.")
+	}
+	if held := km.Held(); !reflect.DeepEqual(held, []string{"ok"}) {
+		t.Errorf("Expected only %q to be held, got %v.", "ok", held)
+	}
+	km.UnlockKey("ok")
+	km.AssertBalanced(t)
+}
+
+func TestRecordingKeyMutex_DoneContext(t *testing.T) {
+	km := NewRecording()
+	km.LockKeyWithContextFunc = func(ctx context.Context, id string) error {
+		t.Errorf("Expected LockKeyWithContextFunc not to be called with a done context.")
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if km.LockKeyWithContext(ctx, "a") {
+		t.Errorf("Expected LockKeyWithContext to fail with a done context.")
+	}
+	if err := km.LockKeyWithContextErr(ctx, "a"); err != context.Canceled {
+		t.Errorf("Expected ctx.Err(), got %v.", err)
+	}
+	expected := []Event{
+		{Op: OpLockKeyWithContext, Key: "a", Acquired: false},
+		{Op: OpLockKeyWithContextErr, Key: "a", Acquired: false},
+	}
+	if events := km.Events(); !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v.", expected, events)
+	}
+	km.AssertBalanced(t)
+}
+
+func TestRecordingKeyMutex_AssertBalanced(t *testing.T) {
+	km := NewRecording()
+	km.LockKey("a")
+	km.LockKey("a")
+	km.UnlockKey("a")
+	if err := km.UnlockKey("b"); err == nil {
+		t.Errorf("Expected unlocking a key which isn't held to fail.")
+	}
+
+	rt := &recordingT{}
+	km.AssertBalanced(rt)
+
+	expected := []string{
+		`Key "b" was unlocked by UnlockKey while it wasn't held.`,
+		`Key "a" is still held, 1 lock(s) were never unlocked.`,
+	}
+	if !reflect.DeepEqual(rt.errors, expected) {
+		t.Errorf("Expected failures %q, got %q.", expected, rt.errors)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrStaleToken is returned by UnlockKeyToken when the Token no longer owns
+// the lock, because it has been unlocked already.
+var ErrStaleToken = errors.New("keymutex: token no longer owns the lock")
+
+// TokenLocker is implemented by KeyMutex instances which can hand out a
+// Token for each acquisition, such as those returned by NewHashed, so that
+// code paths which may both try to release the same acquisition can't
+// release a later one by mistake.
+type TokenLocker interface {
+	// Acquires a lock associated with the specified ID, as LockKey does,
+	// returning a token which can be passed to UnlockKeyToken.
+	LockKeyToken(id string) Token
+
+	// Releases the lock associated with the specified ID if token still owns
+	// it. Returns ErrStaleToken, and releases nothing, if the acquisition
+	// token was issued for has been released already, even if the key has
+	// been locked again since.
+	UnlockKeyToken(id string, token Token) error
+}
+
+// Acquires a lock associated with the specified ID, returning a token which
+// owns the lock until it is released, whether by UnlockKeyToken or UnlockKey.
+// Panics if the KeyMutex has been closed, or if it lets goroutines reenter
+// their locks, since a reentered lock is owned by its outermost acquisition.
+func (km *hashedKeyMutex) LockKeyToken(id string) Token {
+	if km.owners == ownerReentrant {
+		panic("keymutex: tokens are not supported by a reentrant KeyMutex")
+	}
+	km.LockKey(id)
+	token := atomic.AddUint64(&km.lastToken, 1)
+	_, s := km.heldShard(km.normalized(id))
+	atomic.StoreUint64(&s.token, token)
+	return Token(token)
+}
+
+// Releases the lock associated with the specified ID if token still owns it.
+func (km *hashedKeyMutex) UnlockKeyToken(id string, token Token) error {
+	if token == 0 {
+		return ErrStaleToken
+	}
+	id = km.normalized(id)
+	var buf [4]*generation
+	for _, g := range km.generations(buf[:0]) {
+		s := km.shardOf(g, id)
+		// Releasing clears the token, so only one caller can claim it, and
+		// only while the acquisition it was issued for still holds s.
+		if atomic.CompareAndSwapUint64(&s.token, uint64(token), 0) {
+			km.releaseShard(g, s)
+			return nil
+		}
+	}
+	return ErrStaleToken
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"sync"
+	"testing"
+)
+
+func Test_LockKeyToken_StaleTokenIsIgnored(t *testing.T) {
+	// Arrange
+	km := NewHashed(2).(*hashedKeyMutex)
+	stale := km.LockKeyToken("a")
+	if err := km.UnlockKeyToken("a", stale); err != nil {
+		t.Fatalf("Unexpected error unlocking with the current token: %v", err)
+	}
+	current := km.LockKeyToken("a")
+
+	// Act
+	err := km.UnlockKeyToken("a", stale)
+
+	// Assert
+	if err != ErrStaleToken {
+		t.Fatalf("Expected ErrStaleToken, got %v.", err)
+	}
+	if !km.IsLocked("a") {
+		t.Fatalf("Expected a stale token not to release the current holder's lock.")
+	}
+	if err := km.UnlockKeyToken("a", current); err != nil {
+		t.Fatalf("Unexpected error unlocking with the current token: %v", err)
+	}
+	if km.IsLocked("a") {
+		t.Fatalf("Expected the current token to release the lock.")
+	}
+}
+
+func Test_LockKeyToken_UnlockKeyInvalidatesToken(t *testing.T) {
+	// Arrange
+	km := NewHashed(2).(*hashedKeyMutex)
+	token := km.LockKeyToken("a")
+	km.UnlockKey("a")
+	km.LockKey("a")
+
+	// Act
+	err := km.UnlockKeyToken("a", token)
+
+	// Assert
+	if err != ErrStaleToken {
+		t.Fatalf("Expected ErrStaleToken once the key was unlocked by ID, got %v.", err)
+	}
+	if !km.IsLocked("a") {
+		t.Fatalf("Expected the stale token not to release a lock acquired without a token.")
+	}
+	km.UnlockKey("a")
+}
+
+func Test_LockKeyToken_ConcurrentUnlocks(t *testing.T) {
+	// Arrange
+	km := NewHashed(2).(*hashedKeyMutex)
+	token := km.LockKeyToken("a")
+	const unlockers = 8
+	errs := make(chan error, unlockers)
+	var wg sync.WaitGroup
+
+	// Act
+	for i := 0; i < unlockers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- km.UnlockKeyToken("a", token)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Assert
+	released := 0
+	for err := range errs {
+		if err == nil {
+			released++
+		} else if err != ErrStaleToken {
+			t.Fatalf("Expected ErrStaleToken, got %v.", err)
+		}
+	}
+	if released != 1 {
+		t.Fatalf("Expected exactly one unlock to release the lock, got %d.", released)
+	}
+	if km.IsLocked("a") {
+		t.Fatalf("Expected the lock to be released.")
+	}
+}
+
+func Test_LockKeyToken_Reentrant(t *testing.T) {
+	km := NewReentrantHashed(1).(*hashedKeyMutex)
+	if recoverPanic(func() { km.LockKeyToken("a") }) == nil {
+		t.Fatalf("Expected LockKeyToken to panic on a reentrant KeyMutex.")
+	}
+}
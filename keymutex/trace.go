@@ -0,0 +1,38 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"time"
+)
+
+// lockSpanName is the name passed to TracerHook for lock acquisitions.
+const lockSpanName = "keymutex.LockKey"
+
+// TracerHook lets callers trace the time spent acquiring locks, for example as
+// an OpenTelemetry span, without this package depending on a tracing library.
+type TracerHook interface {
+	// StartSpan is called when a context-aware lock acquisition begins, with
+	// the caller's context, a span name such as "keymutex.LockKey" and the ID
+	// being locked. The returned function is called exactly once when the
+	// acquisition completes or ctx is done, with whether the lock was
+	// acquired and how long it took.
+	// IDs may be of high cardinality, so implementations may prefer not to
+	// record them as span attributes.
+	StartSpan(ctx context.Context, name, id string) (end func(acquired bool, waited time.Duration))
+}
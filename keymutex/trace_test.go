@@ -0,0 +1,83 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSpan struct {
+	name, id string
+	ended    bool
+	acquired bool
+	waited   time.Duration
+}
+
+type fakeTracer struct {
+	lock  sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) StartSpan(ctx context.Context, name, id string) func(bool, time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	span := &fakeSpan{name: name, id: id}
+	t.spans = append(t.spans, span)
+	return func(acquired bool, waited time.Duration) {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		span.ended = true
+		span.acquired = acquired
+		span.waited = waited
+	}
+}
+
+func Test_Tracer(t *testing.T) {
+	// Arrange
+	tracer := &fakeTracer{}
+	km := NewHashedWithOptions(4, WithTracer(tracer))
+	key := "fakeid"
+
+	// Act
+	km.LockKey(key)
+	timedOut := LockKeyWithTimeout(km, key, 10*time.Millisecond)
+	km.UnlockKey(key)
+	acquired := LockKeyWithContext(context.Background(), km, key)
+	km.UnlockKey(key)
+
+	// Assert
+	if timedOut || !acquired {
+		t.Fatalf("Expected the first acquisition to time out and the second to succeed.")
+	}
+	if len(tracer.spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d.", len(tracer.spans))
+	}
+	for i, span := range tracer.spans {
+		if span.name != lockSpanName || span.id != key || !span.ended {
+			t.Errorf("Unexpected span %d: %+v", i, span)
+		}
+	}
+	if span := tracer.spans[0]; span.acquired || span.waited < 10*time.Millisecond {
+		t.Errorf("Expected the first span to record a failed wait of at least 10ms, got %+v.", span)
+	}
+	if span := tracer.spans[1]; !span.acquired {
+		t.Errorf("Expected the second span to record an acquisition, got %+v.", span)
+	}
+}
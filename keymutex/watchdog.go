@@ -0,0 +1,116 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// NewWatchdogHashed is like NewHashed, but calls onExceeded whenever a key
+// has been held for longer than maxHold, to help find stuck holders. The key
+// stays locked until it is unlocked as usual.
+func NewWatchdogHashed(n int, maxHold time.Duration, onExceeded func(key string, heldFor time.Duration)) KeyMutex {
+	return NewHashedWithOptions(n, WithWatchdog(maxHold, onExceeded))
+}
+
+// WithWatchdog calls onExceeded once for each hold of a key which lasts longer
+// than maxHold, as NewWatchdogHashed does. It implies WithHeldKeyTracking.
+//
+// Rather than a timer per lock, a single timer is armed for the earliest
+// moment a held key could exceed maxHold, and only while keys are held.
+// onExceeded is called from that timer's goroutine, one key after another,
+// so it should return quickly.
+func WithWatchdog(maxHold time.Duration, onExceeded func(key string, heldFor time.Duration)) Option {
+	return func(km *hashedKeyMutex) {
+		km.trackHeld = true
+		km.watchdog = &watchdog{
+			maxHold:    maxHold,
+			onExceeded: onExceeded,
+		}
+	}
+}
+
+// watchdog reports the keys of a hashed KeyMutex which are held too long.
+type watchdog struct {
+	maxHold    time.Duration
+	onExceeded func(key string, heldFor time.Duration)
+	// armed is set while a check is scheduled or running.
+	armed int32
+}
+
+// armWatchdog schedules a check for a key which has just been locked, unless
+// one is already scheduled. Any scheduled check is due no later than the new
+// key could exceed maxHold, since the keys held already were locked earlier.
+func (km *hashedKeyMutex) armWatchdog() {
+	w := km.watchdog
+	if atomic.LoadInt32(&w.armed) == 0 && atomic.CompareAndSwapInt32(&w.armed, 0, 1) {
+		km.clock.AfterFunc(w.maxHold, km.checkHolds)
+	}
+}
+
+// checkHolds reports the keys which have exceeded maxHold and schedules the
+// next check, if any key remains to be reported.
+func (km *hashedKeyMutex) checkHolds() {
+	w := km.watchdog
+	for {
+		if wait, ok := km.reportHolds(); ok {
+			km.clock.AfterFunc(wait, km.checkHolds)
+			return
+		}
+		// A key locked while disarming either finds the watchdog disarmed
+		// and arms it, or is found by checking again.
+		atomic.StoreInt32(&w.armed, 0)
+		if _, ok := km.reportHolds(); !ok {
+			return
+		}
+		if !atomic.CompareAndSwapInt32(&w.armed, 0, 1) {
+			return
+		}
+	}
+}
+
+// reportHolds calls onExceeded for the holds which have lasted at least
+// maxHold and haven't been reported yet. It returns how long until the next
+// unreported hold will have, or false if there is none.
+func (km *hashedKeyMutex) reportHolds() (time.Duration, bool) {
+	w := km.watchdog
+	var exceeded []HeldKey
+	var next time.Duration
+	pending := false
+	now := km.clock.Now()
+	for g := km.current(); g != nil; g = g.older() {
+		for i := range g.shards {
+			s := g.shards[i]
+			s.meta.Lock()
+			if s.held && !s.reported {
+				if heldFor := now.Sub(s.heldSince); heldFor >= w.maxHold {
+					s.reported = true
+					exceeded = append(exceeded, HeldKey{Key: string(s.heldKey), Acquired: s.heldSince})
+				} else if wait := w.maxHold - heldFor; !pending || wait < next {
+					next = wait
+					pending = true
+				}
+			}
+			s.meta.Unlock()
+		}
+	}
+	for _, h := range exceeded {
+		w.onExceeded(h.Key, now.Sub(h.Acquired))
+	}
+	return next, pending
+}
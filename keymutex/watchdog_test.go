@@ -0,0 +1,129 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymutex
+
+import (
+	"testing"
+	"time"
+)
+
+type exceededHold struct {
+	key     string
+	heldFor time.Duration
+}
+
+func newWatchdogForTest(maxHold time.Duration) (KeyMutex, chan exceededHold) {
+	exceeded := make(chan exceededHold, 10)
+	km := NewWatchdogHashed(4, maxHold, func(key string, heldFor time.Duration) {
+		exceeded <- exceededHold{key, heldFor}
+	})
+	return km, exceeded
+}
+
+func Test_Watchdog_ReportsOnce(t *testing.T) {
+	// Arrange
+	maxHold := 20 * time.Millisecond
+	km, exceeded := newWatchdogForTest(maxHold)
+	key := "fakeid"
+
+	// Act
+	km.LockKey(key)
+
+	// Assert
+	select {
+	case got := <-exceeded:
+		if got.key != key {
+			t.Fatalf("Expected %q to be reported, got %q.", key, got.key)
+		}
+		if got.heldFor < maxHold {
+			t.Fatalf("Expected the key to be reported after %v, got %v.", maxHold, got.heldFor)
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for the watchdog to report %q.", key)
+	}
+	select {
+	case got := <-exceeded:
+		t.Fatalf("Expected a hold to be reported once, got a second report for %q.", got.key)
+	case <-time.After(5 * maxHold):
+	}
+	if err := km.UnlockKey(key); err != nil {
+		t.Fatalf("Expected the reported key to unlock normally, got %v.", err)
+	}
+
+	// A new hold of the same key is reported again.
+	km.LockKey(key)
+	select {
+	case <-exceeded:
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for the watchdog to report %q again.", key)
+	}
+	km.UnlockKey(key)
+}
+
+func Test_Watchdog_ShortHolds(t *testing.T) {
+	// Arrange
+	maxHold := 50 * time.Millisecond
+	km, exceeded := newWatchdogForTest(maxHold)
+
+	// Act
+	for start := time.Now(); time.Since(start) < 3*maxHold; {
+		km.LockKey("a")
+		km.LockKey("b")
+		time.Sleep(maxHold / 10)
+		km.UnlockKey("a")
+		km.UnlockKey("b")
+	}
+
+	// Assert
+	select {
+	case got := <-exceeded:
+		t.Fatalf("Expected no short hold to be reported, got %q after %v.", got.key, got.heldFor)
+	case <-time.After(2 * maxHold):
+	}
+}
+
+func Test_Watchdog_WithClock(t *testing.T) {
+	// Arrange
+	clk := newFakeClock()
+	maxHold := time.Minute
+	exceeded := make(chan exceededHold, 10)
+	km := NewHashedWithOptions(1, WithClock(clk), WithWatchdog(maxHold, func(key string, heldFor time.Duration) {
+		exceeded <- exceededHold{key, heldFor}
+	}))
+	key := "fakeid"
+	km.LockKey(key)
+	verifyEventually(t, clk.HasWaiters)
+
+	// Act & Assert
+	clk.Step(maxHold - time.Second)
+	select {
+	case got := <-exceeded:
+		t.Fatalf("Expected no report before %v, got %q after %v.", maxHold, got.key, got.heldFor)
+	case <-time.After(10 * time.Millisecond):
+	}
+	verifyEventually(t, clk.HasWaiters)
+	clk.Step(time.Second)
+	select {
+	case got := <-exceeded:
+		if got.key != key || got.heldFor != maxHold {
+			t.Fatalf("Expected %q to be reported after %v, got %q after %v.", key, maxHold, got.key, got.heldFor)
+		}
+	case <-time.After(callbackTimeout):
+		t.Fatalf("Timed out waiting for the watchdog to report %q.", key)
+	}
+	km.UnlockKey(key)
+}
@@ -24,6 +24,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"k8s.io/klog/v2"
@@ -51,7 +52,7 @@ func New(mounterPath string) Interface {
 }
 
 // acquire lock for smb mount
-var getSMBMountMutex = keymutex.NewHashed(0)
+var getSMBMountMutex = keymutex.NewHashed(runtime.NumCPU())
 
 // Mount : mounts source to target with given options.
 // currently only supports cifs(smb), bind mount(for disk)